@@ -0,0 +1,11 @@
+package main
+
+import (
+	"github.com/docker/docker/client"
+	"github.com/rmasp98/go-compose/compose"
+)
+
+// newDockerClient connects to the daemon configured by DOCKER_HOST and the other docker environment variables
+func newDockerClient() (compose.Client, error) {
+	return client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+}
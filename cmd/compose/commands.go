@@ -0,0 +1,521 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rmasp98/go-compose/compose"
+	"gopkg.in/yaml.v3"
+)
+
+// runConfig prints the stack as it was understood after loading
+func runConfig(ctx context.Context, c *cli, args []string) int {
+	flags := c.newFlagSet("config", "")
+	format := flags.String("format", "yaml", "output format (yaml or json)")
+	services := flags.Bool("services", false, "print the service names, one per line")
+	quiet := flags.Bool("quiet", false, "only validate the files, don't print anything")
+	if err := flags.Parse(args); err != nil {
+		return usageExitCode(err)
+	}
+	if flags.NArg() > 0 || (*format != "yaml" && *format != "json") {
+		flags.Usage()
+		return exitUsage
+	}
+
+	stack, exitCode := c.load()
+	if exitCode != exitOK || *quiet {
+		return exitCode
+	}
+
+	if *services {
+		for _, name := range stack.ServiceNames() {
+			fmt.Fprintln(c.stdout, name)
+		}
+		return exitOK
+	}
+	return c.printStack(stack, *format)
+}
+
+// printStack prints the stack in the compose format as yaml or json
+func (c *cli) printStack(stack compose.Stack, format string) int {
+	if format == "json" {
+		encoder := json.NewEncoder(c.stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(stack); err != nil {
+			return c.fail(err)
+		}
+		return exitOK
+	}
+	encoder := yaml.NewEncoder(c.stdout)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(stack); err != nil {
+		return c.fail(err)
+	}
+	if err := encoder.Close(); err != nil {
+		return c.fail(err)
+	}
+	return exitOK
+}
+
+// runConvert prints the stack like config or as Kubernetes manifests. The options that could not be
+// converted to Kubernetes are listed on stderr
+func runConvert(ctx context.Context, c *cli, args []string) int {
+	flags := c.newFlagSet("convert", "")
+	format := flags.String("format", "yaml", "output format (yaml, json or kubernetes)")
+	namespace := flags.String("namespace", "", "namespace of the Kubernetes objects")
+	storageClass := flags.String("storage-class", "", "storage class of the Kubernetes volume claims")
+	volumeSize := flags.String("volume-size", "1Gi", "storage requested by each Kubernetes volume claim")
+	if err := flags.Parse(args); err != nil {
+		return usageExitCode(err)
+	}
+	if flags.NArg() > 0 || (*format != "yaml" && *format != "json" && *format != "kubernetes") {
+		flags.Usage()
+		return exitUsage
+	}
+
+	stack, exitCode := c.load()
+	if exitCode != exitOK {
+		return exitCode
+	}
+	if *format != "kubernetes" {
+		return c.printStack(stack, *format)
+	}
+
+	manifests, err := stack.ToKubernetes(compose.KubernetesOptions{Namespace: *namespace, StorageClass: *storageClass, VolumeSize: *volumeSize})
+	if err != nil {
+		return c.fail(err)
+	}
+	output, err := manifests.YAML()
+	if err != nil {
+		return c.fail(err)
+	}
+	c.stdout.Write(output)
+	if report := manifests.Report(); report != "" {
+		fmt.Fprintln(c.stderr, report)
+	}
+	return exitOK
+}
+
+// runValidate loads the stack and prints every issue found. The exit code is exitInvalidFile if any
+// issue is an error and exitWarnings if there are only warnings
+func runValidate(ctx context.Context, c *cli, args []string) int {
+	flags := c.newFlagSet("validate", "")
+	format := flags.String("format", "text", "output format (text, json or sarif)")
+	if err := flags.Parse(args); err != nil {
+		return usageExitCode(err)
+	}
+	if flags.NArg() > 0 || (*format != "text" && *format != "json" && *format != "sarif") {
+		flags.Usage()
+		return exitUsage
+	}
+
+	findings, err := compose.Validate(c.loadOptions)
+	if err != nil {
+		fmt.Fprintln(c.stderr, err.Error())
+		return exitInvalidFile
+	}
+	if directory, err := os.Getwd(); err == nil {
+		findings = findings.RelativeTo(directory)
+	}
+
+	switch *format {
+	case "json":
+		if err := json.NewEncoder(c.stdout).Encode(findings); err != nil {
+			return c.fail(err)
+		}
+	case "sarif":
+		output, err := findings.SARIF()
+		if err != nil {
+			return c.fail(err)
+		}
+		fmt.Fprintln(c.stdout, string(output))
+	default:
+		if len(findings.Issues) > 0 {
+			fmt.Fprintln(c.stdout, findings.String())
+		}
+	}
+
+	if findings.HasErrors() {
+		return exitInvalidFile
+	}
+	if len(findings.Issues) > 0 {
+		return exitWarnings
+	}
+	return exitOK
+}
+
+// runUp creates and starts the stack. Unless detached it then follows the logs and stops the
+// containers once interrupted
+func runUp(ctx context.Context, c *cli, args []string) int {
+	flags := c.newFlagSet("up", "")
+	detach := flags.Bool("d", false, "start the containers and exit")
+	flags.BoolVar(detach, "detach", false, "alias of -d")
+	forceRecreate := flags.Bool("force-recreate", false, "recreate containers even if their config has not changed")
+	noRecreate := flags.Bool("no-recreate", false, "don't recreate containers that already exist")
+	dryRun := flags.Bool("dry-run", false, "print the changes that would be made without making them")
+	var scale stringList
+	flags.Var(&scale, "scale", "set the number of replicas of a service as SERVICE=NUM, can be repeated")
+	if err := flags.Parse(args); err != nil {
+		return usageExitCode(err)
+	}
+	if flags.NArg() > 0 || (*forceRecreate && *noRecreate) {
+		flags.Usage()
+		return exitUsage
+	}
+
+	var stackOptions []compose.StackOption
+	for _, value := range scale {
+		parts := strings.SplitN(value, "=", 2)
+		replicas, err := strconv.Atoi(parts[len(parts)-1])
+		if len(parts) != 2 || err != nil {
+			fmt.Fprintf(c.stderr, "invalid scale %s, should be SERVICE=NUM\n", value)
+			return exitUsage
+		}
+		stackOptions = append(stackOptions, compose.WithScale(parts[0], replicas))
+	}
+
+	stack, exitCode := c.load(stackOptions...)
+	if exitCode != exitOK {
+		return exitCode
+	}
+	client, exitCode := c.client()
+	if exitCode != exitOK {
+		return exitCode
+	}
+
+	options := compose.UpOptions{
+		ForceRecreate: *forceRecreate,
+		NoRecreate:    *noRecreate,
+		Progress: func(event compose.WaitEvent) {
+			fmt.Fprintf(c.stderr, "%s waiting for %s to be %s: %s\n", event.Service, event.Dependency, event.Condition, event.Status)
+		},
+	}
+	if *dryRun {
+		plan, err := stack.Plan(ctx, client, options)
+		if err != nil {
+			return c.fail(err)
+		}
+		fmt.Fprintln(c.stdout, plan.String())
+		return exitOK
+	}
+
+	report, err := stack.Up(ctx, client, options)
+	c.printReport(report)
+	if err != nil {
+		return c.fail(err)
+	}
+	if *detach {
+		return exitOK
+	}
+
+	err = stack.Logs(ctx, client, c.stdout, compose.LogOptions{Follow: true, NoColor: !isTerminal(c.stdout)})
+	if ctx.Err() == nil {
+		if err != nil {
+			return c.fail(err)
+		}
+		return exitOK
+	}
+	fmt.Fprintln(c.stderr, "stopping")
+	report, err = stack.Stop(context.Background(), client, compose.LifecycleOptions{})
+	c.printReport(report)
+	if err != nil {
+		return c.fail(err)
+	}
+	return exitOK
+}
+
+// runDown stops and removes the stack
+func runDown(ctx context.Context, c *cli, args []string) int {
+	flags := c.newFlagSet("down", "")
+	removeOrphans := flags.Bool("remove-orphans", false, "remove containers of services not in the compose files")
+	volumes := flags.Bool("v", false, "remove the named volumes and anonymous volumes of the containers")
+	flags.BoolVar(volumes, "volumes", false, "alias of -v")
+	images := flags.Bool("rmi", false, "remove the images used by the services")
+	timeout := flags.Int("t", -1, "seconds to wait for containers to stop (default: stop_grace_period of the service)")
+	flags.IntVar(timeout, "timeout", -1, "alias of -t")
+	if err := flags.Parse(args); err != nil {
+		return usageExitCode(err)
+	}
+	if flags.NArg() > 0 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	stack, exitCode := c.load()
+	if exitCode != exitOK {
+		return exitCode
+	}
+	client, exitCode := c.client()
+	if exitCode != exitOK {
+		return exitCode
+	}
+
+	options := compose.DownOptions{RemoveOrphans: *removeOrphans, RemoveVolumes: *volumes, RemoveImages: *images}
+	if *timeout >= 0 {
+		duration := time.Duration(*timeout) * time.Second
+		options.Timeout = &duration
+	}
+	report, err := stack.Down(ctx, client, options)
+	c.printReport(report)
+	if err != nil {
+		return c.fail(err)
+	}
+	return exitOK
+}
+
+// runPs prints the status of the containers
+func runPs(ctx context.Context, c *cli, args []string) int {
+	flags := c.newFlagSet("ps", "[SERVICE...]")
+	format := flags.String("format", "table", "output format (table or json)")
+	oneOff := flags.Bool("one-off", false, "include the containers created by run")
+	if err := flags.Parse(args); err != nil {
+		return usageExitCode(err)
+	}
+	if *format != "table" && *format != "json" {
+		flags.Usage()
+		return exitUsage
+	}
+
+	stack, exitCode := c.load()
+	if exitCode != exitOK {
+		return exitCode
+	}
+	client, exitCode := c.client()
+	if exitCode != exitOK {
+		return exitCode
+	}
+
+	status, err := stack.Status(ctx, client, compose.StatusOptions{Services: flags.Args(), OneOff: *oneOff})
+	if err != nil {
+		return c.fail(err)
+	}
+	if *format == "json" {
+		if err := json.NewEncoder(c.stdout).Encode(status); err != nil {
+			return c.fail(err)
+		}
+		return exitOK
+	}
+	fmt.Fprint(c.stdout, status.String())
+	return exitOK
+}
+
+// runLogs prints the logs of the containers
+func runLogs(ctx context.Context, c *cli, args []string) int {
+	flags := c.newFlagSet("logs", "[SERVICE...]")
+	follow := flags.Bool("f", false, "keep following the output until interrupted")
+	flags.BoolVar(follow, "follow", false, "alias of -f")
+	since := flags.String("since", "", "only show logs since a timestamp (e.g. 2021-01-02T13:23:37Z) or relative time (e.g. 10m)")
+	tail := flags.String("tail", "all", "number of lines to show from the end of each container's log")
+	timestamps := flags.Bool("t", false, "show timestamps")
+	flags.BoolVar(timestamps, "timestamps", false, "alias of -t")
+	noColor := flags.Bool("no-color", false, "don't colour the prefixes, which are only coloured on a terminal")
+	if err := flags.Parse(args); err != nil {
+		return usageExitCode(err)
+	}
+
+	stack, exitCode := c.load()
+	if exitCode != exitOK {
+		return exitCode
+	}
+	client, exitCode := c.client()
+	if exitCode != exitOK {
+		return exitCode
+	}
+
+	err := stack.Logs(ctx, client, c.stdout, compose.LogOptions{
+		Services:   flags.Args(),
+		Follow:     *follow,
+		Since:      *since,
+		Tail:       *tail,
+		Timestamps: *timestamps,
+		NoColor:    *noColor || !isTerminal(c.stdout),
+	})
+	if err != nil && ctx.Err() == nil {
+		return c.fail(err)
+	}
+	return exitOK
+}
+
+// runEvents prints the events of the project as they happen, one per line
+func runEvents(ctx context.Context, c *cli, args []string) int {
+	flags := c.newFlagSet("events", "[SERVICE...]")
+	jsonFormat := flags.Bool("json", false, "print each event as a JSON object")
+	since := flags.String("since", "", "show events since a timestamp (e.g. 2021-01-02T13:23:37Z) or relative time (e.g. 10m)")
+	until := flags.String("until", "", "stop once a timestamp or relative time is reached (default: until interrupted)")
+	if err := flags.Parse(args); err != nil {
+		return usageExitCode(err)
+	}
+
+	stack, exitCode := c.load()
+	if exitCode != exitOK {
+		return exitCode
+	}
+	client, exitCode := c.client()
+	if exitCode != exitOK {
+		return exitCode
+	}
+
+	encoder := json.NewEncoder(c.stdout)
+	handler := func(event compose.Event) error {
+		if *jsonFormat {
+			return encoder.Encode(event)
+		}
+		action := event.Action
+		if event.Detail != "" {
+			action += ": " + event.Detail
+		}
+		_, err := fmt.Fprintf(c.stdout, "%s %s %s %s\n", event.Time.Format(time.RFC3339Nano), event.Kind, action, event.Name)
+		return err
+	}
+	err := stack.Events(ctx, client, handler, compose.EventOptions{
+		Services: flags.Args(),
+		Since:    *since,
+		Until:    *until,
+		OnDisconnect: func(err error) {
+			fmt.Fprintf(c.stderr, "reconnecting after losing the event stream: %s\n", err.Error())
+		},
+	})
+	if err != nil {
+		return c.fail(err)
+	}
+	return exitOK
+}
+
+// runRun runs a one-off container and exits with its exit code
+func runRun(ctx context.Context, c *cli, args []string) int {
+	flags := c.newFlagSet("run", "SERVICE [COMMAND] [ARGS...]")
+	remove := flags.Bool("rm", false, "remove the container once it exits")
+	noDeps := flags.Bool("no-deps", false, "don't start the services the service depends on")
+	detach := flags.Bool("d", false, "run the container in the background and print its name")
+	flags.BoolVar(detach, "detach", false, "alias of -d")
+	var env stringList
+	flags.Var(&env, "e", "set an environment variable as KEY=VALUE, can be repeated")
+	entrypoint := flags.String("entrypoint", "", "override the entrypoint of the service")
+	workingDir := flags.String("w", "", "working directory of the command")
+	flags.StringVar(workingDir, "workdir", "", "alias of -w")
+	user := flags.String("u", "", "user to run the command as")
+	flags.StringVar(user, "user", "", "alias of -u")
+	name := flags.String("name", "", "name of the container")
+	servicePorts := flags.Bool("service-ports", false, "publish the ports of the service")
+	tty := flags.Bool("t", false, "allocate a pseudo-TTY. The local terminal is not switched to raw mode")
+	flags.BoolVar(tty, "tty", false, "alias of -t")
+	if err := flags.Parse(args); err != nil {
+		return usageExitCode(err)
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	options := compose.RunOptions{
+		Name:         *name,
+		Env:          env,
+		WorkingDir:   *workingDir,
+		User:         *user,
+		Remove:       *remove,
+		ServicePorts: *servicePorts,
+		NoDeps:       *noDeps,
+		Detach:       *detach,
+		Tty:          *tty,
+		Stdout:       c.stdout,
+		Stderr:       c.stderr,
+	}
+	if *entrypoint != "" {
+		var err error
+		if options.Entrypoint, err = compose.SplitCommand(*entrypoint); err != nil {
+			fmt.Fprintf(c.stderr, "invalid entrypoint: %s\n", err.Error())
+			return exitUsage
+		}
+	}
+	if !*detach {
+		options.Stdin = c.stdin
+	}
+
+	stack, exitCode := c.load()
+	if exitCode != exitOK {
+		return exitCode
+	}
+	client, exitCode := c.client()
+	if exitCode != exitOK {
+		return exitCode
+	}
+
+	result, err := stack.Run(ctx, client, flags.Arg(0), flags.Args()[1:], options)
+	if err != nil {
+		return c.fail(err)
+	}
+	if *detach {
+		fmt.Fprintln(c.stdout, result.Name)
+	}
+	return result.ExitCode
+}
+
+// runExec runs a command in a running container of a service and exits with its exit code
+func runExec(ctx context.Context, c *cli, args []string) int {
+	flags := c.newFlagSet("exec", "SERVICE COMMAND [ARGS...]")
+	detach := flags.Bool("d", false, "run the command in the background")
+	flags.BoolVar(detach, "detach", false, "alias of -d")
+	var env stringList
+	flags.Var(&env, "e", "set an environment variable as KEY=VALUE, can be repeated")
+	workingDir := flags.String("w", "", "working directory of the command")
+	flags.StringVar(workingDir, "workdir", "", "alias of -w")
+	user := flags.String("u", "", "user to run the command as")
+	flags.StringVar(user, "user", "", "alias of -u")
+	privileged := flags.Bool("privileged", false, "give the command extended privileges")
+	tty := flags.Bool("t", false, "allocate a pseudo-TTY. The local terminal is not switched to raw mode")
+	flags.BoolVar(tty, "tty", false, "alias of -t")
+	index := flags.Int("index", 1, "replica of the service to run the command in")
+	if err := flags.Parse(args); err != nil {
+		return usageExitCode(err)
+	}
+	if flags.NArg() < 2 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	options := compose.ExecOptions{
+		Replica:    *index,
+		Env:        env,
+		WorkingDir: *workingDir,
+		User:       *user,
+		Privileged: *privileged,
+		Detach:     *detach,
+		Tty:        *tty,
+		Stdout:     c.stdout,
+		Stderr:     c.stderr,
+	}
+	if !*detach {
+		options.Stdin = c.stdin
+	}
+
+	stack, exitCode := c.load()
+	if exitCode != exitOK {
+		return exitCode
+	}
+	client, exitCode := c.client()
+	if exitCode != exitOK {
+		return exitCode
+	}
+
+	exitCode, err := stack.Exec(ctx, client, flags.Arg(0), flags.Args()[1:], options)
+	if err != nil {
+		return c.fail(err)
+	}
+	return exitCode
+}
+
+// printReport writes what happened to each resource, e.g. "container app-web-1 started"
+func (c *cli) printReport(report compose.Report) {
+	for _, result := range report.Results {
+		if result.Err != nil {
+			fmt.Fprintf(c.stderr, "%s %s %s: %s\n", result.Kind, result.Name, result.Action, result.Err.Error())
+			continue
+		}
+		fmt.Fprintf(c.stderr, "%s %s %s\n", result.Kind, result.Name, result.Action)
+	}
+}
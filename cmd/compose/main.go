@@ -1,28 +1,205 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
 
 	"github.com/rmasp98/go-compose/compose"
-	"gopkg.in/yaml.v3"
 )
 
+// Exit codes returned by the commands. Commands that run a process in a container exit with its exit code instead
+const (
+	exitOK = iota
+	exitFailure
+	exitUsage
+	exitInvalidFile
+	// exitWarnings is returned by validate when the compose files only have warnings
+	exitWarnings
+)
+
+// cli holds the streams, environment and daemon connection used by the commands so they can be replaced in tests
+type cli struct {
+	stdin          io.Reader
+	stdout, stderr io.Writer
+	environment    map[string]string
+	newClient      func() (compose.Client, error)
+
+	loadOptions compose.LoadOptions
+}
+
+// command is a subcommand run with the arguments that follow its name
+type command struct {
+	description string
+	run         func(ctx context.Context, c *cli, args []string) int
+}
+
+var commands = map[string]command{
+	"config":   {"Parse, merge and print the compose files", runConfig},
+	"convert":  {"Print the stack as compose files or Kubernetes manifests", runConvert},
+	"validate": {"Check the compose files for errors and warnings", runValidate},
+	"up":       {"Create and start the containers", runUp},
+	"down":     {"Stop and remove the containers and networks", runDown},
+	"ps":       {"List the containers of the project", runPs},
+	"logs":     {"Output the logs of the containers", runLogs},
+	"events":   {"Stream the events of the containers, networks and volumes", runEvents},
+	"run":      {"Run a one-off command in a new container of a service", runRun},
+	"exec":     {"Run a command in a running container of a service", runExec},
+}
+
 func main() {
-	fmt.Println("go-compose")
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	exitCode := run(ctx, os.Args[1:], environ(), os.Stdin, os.Stdout, os.Stderr, newDockerClient)
+	stop()
+	os.Exit(exitCode)
+}
+
+// environ returns the environment of the process as a map
+func environ() map[string]string {
+	environment := make(map[string]string)
+	for _, variable := range os.Environ() {
+		parts := strings.SplitN(variable, "=", 2)
+		environment[parts[0]] = parts[1]
+	}
+	return environment
+}
+
+// run parses the global flags and runs the command, returning the exit code
+func run(ctx context.Context, args []string, environment map[string]string, stdin io.Reader, stdout, stderr io.Writer, newClient func() (compose.Client, error)) int {
+	c := &cli{stdin: stdin, stdout: stdout, stderr: stderr, environment: environment, newClient: newClient}
+
+	flags := flag.NewFlagSet("compose", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() { c.usage(flags) }
+	var files, envFiles, profiles stringList
+	flags.Var(&files, "f", "compose file, can be repeated to merge files (env COMPOSE_FILE)")
+	flags.Var(&files, "file", "alias of -f")
+	flags.StringVar(&c.loadOptions.ProjectName, "p", environment["COMPOSE_PROJECT_NAME"], "project name (env COMPOSE_PROJECT_NAME)")
+	flags.StringVar(&c.loadOptions.ProjectName, "project-name", environment["COMPOSE_PROJECT_NAME"], "alias of -p")
+	flags.StringVar(&c.loadOptions.ProjectDirectory, "project-directory", "", "directory relative paths are resolved against (default: directory of the first file)")
+	flags.Var(&envFiles, "env-file", "file of variables used for interpolation, can be repeated (default: .env)")
+	flags.Var(&profiles, "profile", "enable the services with the profile, can be repeated (env COMPOSE_PROFILES)")
+	if err := flags.Parse(args); err != nil {
+		return usageExitCode(err)
+	}
+
+	if len(files) == 0 && environment["COMPOSE_FILE"] != "" {
+		files = splitList(environment["COMPOSE_FILE"], string(os.PathListSeparator))
+	}
+	if len(profiles) == 0 && environment["COMPOSE_PROFILES"] != "" {
+		profiles = splitList(environment["COMPOSE_PROFILES"], ",")
+	}
+	c.loadOptions.Files, c.loadOptions.EnvFiles, c.loadOptions.Profiles = files, envFiles, profiles
+	c.loadOptions.Environment = environment
+
+	if flags.NArg() == 0 {
+		c.usage(flags)
+		return exitUsage
+	}
+	cmd, exists := commands[flags.Arg(0)]
+	if !exists {
+		fmt.Fprintf(stderr, "unknown command %s\n", flags.Arg(0))
+		c.usage(flags)
+		return exitUsage
+	}
+	return cmd.run(ctx, c, flags.Args()[1:])
+}
 
-	fileContents, readErr := ioutil.ReadFile("compose.yaml")
-	if readErr != nil {
-		fmt.Println(readErr.Error())
-		os.Exit(1)
+func (c *cli) usage(flags *flag.FlagSet) {
+	fmt.Fprintf(c.stderr, "Usage: compose [options] COMMAND [args]\n\nOptions:\n")
+	flags.PrintDefaults()
+	fmt.Fprintf(c.stderr, "\nCommands:\n")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
 	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(c.stderr, "  %-10s%s\n", name, commands[name].description)
+	}
+}
+
+// load loads the Stack from the compose files. The returned exit code is non-zero if it could not be loaded
+func (c *cli) load(stackOptions ...compose.StackOption) (compose.Stack, int) {
+	stack, err := compose.Load(c.loadOptions, stackOptions...)
+	if err != nil {
+		fmt.Fprintln(c.stderr, err.Error())
+		return compose.Stack{}, exitInvalidFile
+	}
+	return stack, exitOK
+}
+
+// client connects to the daemon. The returned exit code is non-zero if it could not connect
+func (c *cli) client() (compose.Client, int) {
+	client, err := c.newClient()
+	if err != nil {
+		fmt.Fprintln(c.stderr, err.Error())
+		return nil, exitFailure
+	}
+	return client, exitOK
+}
+
+// fail writes the error and returns the exit code for a failed command
+func (c *cli) fail(err error) int {
+	fmt.Fprintln(c.stderr, err.Error())
+	return exitFailure
+}
 
-	var data interface{}
-	if err := yaml.Unmarshal(fileContents, &data); err != nil {
-		fmt.Println(err.Error())
-		os.Exit(2)
+// newFlagSet creates the flags of a command that write errors to stderr instead of exiting
+func (c *cli) newFlagSet(name, arguments string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(c.stderr, "Usage: compose %s [options] %s\n\nOptions:\n", name, arguments)
+		flags.PrintDefaults()
 	}
+	return flags
+}
 
-	compose.NewStack(data)
+// usageExitCode returns the exit code for an error from parsing flags. Asking for help is not a failure
+func usageExitCode(err error) int {
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	return exitUsage
+}
+
+// stringList is a flag that can be repeated, collecting every value in order
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// splitList splits the value on the separator, dropping empty elements
+func splitList(value, separator string) []string {
+	var list []string
+	for _, element := range strings.Split(value, separator) {
+		if element = strings.TrimSpace(element); element != "" {
+			list = append(list, element)
+		}
+	}
+	return list
+}
+
+// isTerminal returns whether the stdin, stdout or stderr stream is a terminal
+func isTerminal(stream interface{}) bool {
+	file, isFile := stream.(*os.File)
+	if !isFile {
+		return false
+	}
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
 }
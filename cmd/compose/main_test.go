@@ -0,0 +1,339 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rmasp98/go-compose/compose"
+	"github.com/rmasp98/go-compose/compose/composetest"
+)
+
+const testCompose = `
+services:
+  web:
+    image: nginx:${TAG:-latest}
+    depends_on: [db]
+  db:
+    image: postgres
+  debug:
+    image: busybox
+    profiles: [debug]
+`
+
+const testOverride = `
+services:
+  web:
+    image: nginx:${TAG}
+`
+
+// testCLI runs the CLI against a fake client in a directory containing the files
+type testCLI struct {
+	t         *testing.T
+	directory string
+	client    *composetest.FakeClient
+	stdin     io.Reader
+}
+
+func newTestCLI(t *testing.T, files map[string]string) *testCLI {
+	directory := filepath.Join(t.TempDir(), "project")
+	for name, contents := range files {
+		if err := writeFile(filepath.Join(directory, name), contents); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &testCLI{t: t, directory: directory, client: composetest.NewFakeClient(), stdin: strings.NewReader("")}
+}
+
+func writeFile(path, contents string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, []byte(contents), 0644)
+}
+
+// run runs the CLI with the environment and returns the exit code, stdout and stderr
+func (c *testCLI) run(environment map[string]string, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	if environment == nil {
+		environment = map[string]string{}
+	}
+	args = append([]string{"--project-directory", c.directory}, args...)
+	exitCode := run(context.Background(), args, environment, c.stdin, &stdout, &stderr, func() (compose.Client, error) {
+		return c.client, nil
+	})
+	return exitCode, stdout.String(), stderr.String()
+}
+
+func TestConfigMergesFilesFromFlagsAndEnvironment(t *testing.T) {
+	cli := newTestCLI(t, map[string]string{"compose.yaml": testCompose, "override.yaml": testOverride})
+	base, override := filepath.Join(cli.directory, "compose.yaml"), filepath.Join(cli.directory, "override.yaml")
+
+	testData := map[string]struct {
+		environment map[string]string
+		args        []string
+		expected    string
+	}{
+		"default":     {nil, []string{"config", "--format", "json"}, `"image": "nginx:latest"`},
+		"flags":       {map[string]string{"TAG": "1.21"}, []string{"-f", base, "--file", override, "config", "--format", "json"}, `"image": "nginx:1.21"`},
+		"composeFile": {map[string]string{"TAG": "1.22", "COMPOSE_FILE": base + ":" + override}, []string{"config"}, "image: nginx:1.22"},
+		"projectName": {map[string]string{"COMPOSE_PROJECT_NAME": "fromenv"}, []string{"config"}, "name: fromenv"},
+		"nameFlag":    {map[string]string{"COMPOSE_PROJECT_NAME": "fromenv"}, []string{"-p", "fromflag", "config"}, "name: fromflag"},
+		"directory":   {nil, []string{"config"}, "name: project"},
+	}
+
+	for name, data := range testData {
+		t.Run(name, func(t *testing.T) {
+			exitCode, stdout, stderr := cli.run(data.environment, data.args...)
+			if exitCode != exitOK || !strings.Contains(stdout, data.expected) {
+				t.Errorf("Expected %q in output but got exit code %d with:\n%s%s", data.expected, exitCode, stdout, stderr)
+			}
+		})
+	}
+}
+
+func TestConfigListsServicesWithProfiles(t *testing.T) {
+	cli := newTestCLI(t, map[string]string{"compose.yaml": testCompose})
+
+	testData := map[string]struct {
+		environment map[string]string
+		args        []string
+		expected    string
+	}{
+		"default":     {nil, []string{"config", "--services"}, "db\nweb\n"},
+		"flag":        {nil, []string{"--profile", "debug", "config", "--services"}, "db\ndebug\nweb\n"},
+		"environment": {map[string]string{"COMPOSE_PROFILES": "debug,other"}, []string{"config", "--services"}, "db\ndebug\nweb\n"},
+		"quiet":       {nil, []string{"config", "--quiet"}, ""},
+	}
+
+	for name, data := range testData {
+		t.Run(name, func(t *testing.T) {
+			if exitCode, stdout, _ := cli.run(data.environment, data.args...); exitCode != exitOK || stdout != data.expected {
+				t.Errorf("Expected %q but got %q with exit code %d", data.expected, stdout, exitCode)
+			}
+		})
+	}
+}
+
+func TestExitCodes(t *testing.T) {
+	cli := newTestCLI(t, map[string]string{
+		"compose.yaml": testCompose,
+		"invalid.yaml": "services:\n  web:\n    depends_on: [missing]\n    image: nginx",
+	})
+	invalid := filepath.Join(cli.directory, "invalid.yaml")
+
+	testData := map[string]struct {
+		args     []string
+		expected int
+	}{
+		"noCommand":      {nil, exitUsage},
+		"unknownCommand": {[]string{"build"}, exitUsage},
+		"unknownFlag":    {[]string{"--unknown", "config"}, exitUsage},
+		"commandFlag":    {[]string{"config", "--format", "xml"}, exitUsage},
+		"help":           {[]string{"ps", "-h"}, exitOK},
+		"missingService": {[]string{"run"}, exitUsage},
+		"invalidScale":   {[]string{"up", "--scale", "web"}, exitUsage},
+		"invalidFile":    {[]string{"-f", invalid, "validate"}, exitInvalidFile},
+		"missingFile":    {[]string{"-f", "missing.yaml", "ps"}, exitInvalidFile},
+		"valid":          {[]string{"validate"}, exitOK},
+		"unknownService": {[]string{"ps", "cache"}, exitFailure},
+	}
+
+	for name, data := range testData {
+		t.Run(name, func(t *testing.T) {
+			if exitCode, _, stderr := cli.run(nil, data.args...); exitCode != data.expected {
+				t.Errorf("Expected exit code %d but got %d with:\n%s", data.expected, exitCode, stderr)
+			}
+		})
+	}
+}
+
+func TestClientErrorFailsCommand(t *testing.T) {
+	cli := newTestCLI(t, map[string]string{"compose.yaml": testCompose})
+	var stderr bytes.Buffer
+	exitCode := run(context.Background(), []string{"--project-directory", cli.directory, "up", "-d"}, map[string]string{}, cli.stdin, ioutil.Discard, &stderr, func() (compose.Client, error) {
+		return nil, fmt.Errorf("cannot connect")
+	})
+	if exitCode != exitFailure || stderr.String() != "cannot connect\n" {
+		t.Errorf("Unexpected exit code %d with %q", exitCode, stderr.String())
+	}
+}
+
+func TestUpPsAndDown(t *testing.T) {
+	cli := newTestCLI(t, map[string]string{"compose.yaml": testCompose})
+	cli.client.AddImage("postgres")
+
+	if exitCode, stdout, stderr := cli.run(nil, "up", "--dry-run"); exitCode != exitOK || !strings.Contains(stdout, "container project-web-1 will be created") {
+		t.Fatalf("Unexpected dry run with exit code %d:\n%s%s", exitCode, stdout, stderr)
+	}
+	if len(cli.client.Calls()) != 0 {
+		t.Fatalf("Dry run should not change anything but got %v", cli.client.Calls())
+	}
+
+	if exitCode, _, stderr := cli.run(nil, "up", "-d", "--scale", "web=2"); exitCode != exitOK || !strings.Contains(stderr, "container project-web-2 created") {
+		t.Fatalf("Unexpected up with exit code %d:\n%s", exitCode, stderr)
+	}
+
+	exitCode, stdout, _ := cli.run(nil, "ps", "--format", "json", "web")
+	if exitCode != exitOK || strings.Count(stdout, `"state":"running"`) != 2 {
+		t.Errorf("Unexpected ps with exit code %d:\n%s", exitCode, stdout)
+	}
+
+	if exitCode, _, stderr := cli.run(nil, "down", "-t", "0"); exitCode != exitOK || !strings.Contains(stderr, "container project-db-1 removed") {
+		t.Errorf("Unexpected down with exit code %d:\n%s", exitCode, stderr)
+	}
+	if !contains(cli.client.Calls(), "ContainerStop project-web-1 0s") {
+		t.Errorf("Timeout should be passed to stop but got %v", cli.client.Calls())
+	}
+}
+
+func TestRunAndExecReturnExitCode(t *testing.T) {
+	cli := newTestCLI(t, map[string]string{"compose.yaml": testCompose})
+	cli.client.AddImage("postgres")
+	cli.client.SetProcess(func(name string, command []string, stdin io.Reader, stdout, stderr io.Writer) int {
+		input, _ := ioutil.ReadAll(stdin)
+		fmt.Fprintf(stdout, "%s %s %s", name, strings.Join(command, " "), input)
+		return 4
+	})
+	cli.stdin = strings.NewReader("input")
+
+	exitCode, stdout, stderr := cli.run(nil, "run", "--rm", "--name", "migrate", "--entrypoint", "sh -c", "db", "echo", "hi")
+	if exitCode != 4 || stdout != "migrate sh -c echo hi input" {
+		t.Errorf("Unexpected run with exit code %d: %q %q", exitCode, stdout, stderr)
+	}
+
+	cli.stdin = strings.NewReader("")
+	exitCode, stdout, stderr = cli.run(nil, "exec", "-t", "db", "ls")
+	if exitCode != exitFailure || !strings.Contains(stderr, "does not have a container") {
+		t.Errorf("Exec should fail before up but got exit code %d: %q", exitCode, stderr)
+	}
+
+	cli.run(nil, "up", "-d")
+	exitCode, stdout, stderr = cli.run(nil, "exec", "-e", "A=B", "db", "ls", "-l")
+	if exitCode != 4 || stdout != "project-db-1 ls -l " {
+		t.Errorf("Unexpected exec with exit code %d: %q %q", exitCode, stdout, stderr)
+	}
+}
+
+func TestRunOnlyAllocatesATtyWhenAsked(t *testing.T) {
+	cli := newTestCLI(t, map[string]string{"compose.yaml": testCompose})
+	cli.client.AddImage("postgres")
+
+	for name, args := range map[string][]string{"plain": {"run", "-d", "--name", "plain", "db"}, "shell": {"run", "-d", "-t", "--name", "shell", "db"}} {
+		if exitCode, _, stderr := cli.run(nil, args...); exitCode != exitOK {
+			t.Fatalf("Unexpected run with exit code %d: %q", exitCode, stderr)
+		}
+		inspect, err := cli.client.ContainerInspect(context.Background(), name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if inspect.Config.Tty != (name == "shell") {
+			t.Errorf("%s should have tty %v but was %v", name, name == "shell", inspect.Config.Tty)
+		}
+	}
+}
+
+func contains(list []string, value string) bool {
+	for _, element := range list {
+		if element == value {
+			return true
+		}
+	}
+	return false
+}
+
+func TestLogsAreOnlyColouredOnATerminal(t *testing.T) {
+	cli := newTestCLI(t, map[string]string{"compose.yaml": testCompose})
+	cli.client.AddImage("postgres")
+	cli.run(nil, "up", "-d")
+	cli.client.WriteLogs("project-db-1", false, "ready")
+
+	exitCode, stdout, stderr := cli.run(nil, "logs", "db")
+	if exitCode != exitOK || stdout != "db-1 | ready\n" {
+		t.Errorf("Logs written to a pipe should not be coloured but got exit code %d with %q %q", exitCode, stdout, stderr)
+	}
+}
+
+func TestEventsCanBePrintedAsJSONLines(t *testing.T) {
+	cli := newTestCLI(t, map[string]string{"compose.yaml": testCompose})
+	cli.client.AddImage("postgres")
+	cli.run(nil, "up", "-d")
+	until := time.Now().Add(100 * time.Millisecond).Format(time.RFC3339Nano)
+
+	exitCode, stdout, stderr := cli.run(nil, "events", "--json", "--since", "0", "--until", until, "db")
+	if exitCode != exitOK {
+		t.Fatalf("Unexpected exit code %d: %q", exitCode, stderr)
+	}
+	var actions []string
+	for _, line := range strings.Split(strings.TrimSpace(stdout), "\n") {
+		var event compose.Event
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("Each line should be a JSON object but got %q: %v", line, err)
+		}
+		if event.Service != "db" || event.Name != "project-db-1" || event.Replica != 1 {
+			t.Errorf("Unexpected event %+v", event)
+		}
+		actions = append(actions, event.Action)
+	}
+	if !contains(actions, "create") || !contains(actions, "start") {
+		t.Errorf("Expected create and start events but got %v", actions)
+	}
+
+	exitCode, stdout, _ = cli.run(nil, "events", "--since", "0", "--until", until, "db")
+	if exitCode != exitOK || !strings.Contains(stdout, " container start project-db-1\n") {
+		t.Errorf("Unexpected events with exit code %d:\n%s", exitCode, stdout)
+	}
+}
+
+func TestValidateExitCodeDistinguishesErrorsFromWarnings(t *testing.T) {
+	cli := newTestCLI(t, map[string]string{
+		"compose.yaml": testCompose,
+		"warning.yaml": "services:\n  web:\n    image: nginx\nvolumes:\n  unused:\n",
+		"error.yaml":   "services:\n  web:\n    image: nginx\n    depends_on: [missing]\n",
+	})
+
+	testData := map[string]struct {
+		file     string
+		format   string
+		expected int
+		output   string
+	}{
+		"valid":   {"compose.yaml", "text", exitOK, ""},
+		"warning": {"warning.yaml", "text", exitWarnings, "warning.yaml:5:3: warning: volumes.unused: volume unused is not used by any service [unused-volume]\n"},
+		"error":   {"error.yaml", "json", exitInvalidFile, `"line":4,"column":5,"rule":"undefined-service","severity":"error"`},
+		"sarif":   {"error.yaml", "sarif", exitInvalidFile, `"ruleId": "undefined-service"`},
+	}
+
+	for name, data := range testData {
+		t.Run(name, func(t *testing.T) {
+			exitCode, stdout, stderr := cli.run(nil, "-f", filepath.Join(cli.directory, data.file), "validate", "--format", data.format)
+			if exitCode != data.expected || !strings.Contains(stdout, data.output) || (data.output == "" && stdout != "") {
+				t.Errorf("Expected exit code %d and %q but got %d with:\n%s%s", data.expected, data.output, exitCode, stdout, stderr)
+			}
+		})
+	}
+}
+
+func TestConvertToKubernetes(t *testing.T) {
+	cli := newTestCLI(t, map[string]string{"compose.yaml": testCompose + "  cache:\n    image: redis\n    ports: [\"6379:6379\"]\n"})
+
+	exitCode, stdout, stderr := cli.run(nil, "convert", "--format", "kubernetes", "--namespace", "test")
+	if exitCode != exitOK || strings.Count(stdout, "kind: Deployment") != 3 || !strings.Contains(stdout, "namespace: test") || !strings.Contains(stdout, "type: LoadBalancer") {
+		t.Errorf("Unexpected manifests with exit code %d:\n%s", exitCode, stdout)
+	}
+	if !strings.Contains(stderr, "services.web.depends_on:") {
+		t.Errorf("Unsupported options should be reported but got %q", stderr)
+	}
+
+	if exitCode, stdout, _ := cli.run(nil, "convert", "--format", "json"); exitCode != exitOK || !strings.Contains(stdout, `"image": "postgres"`) {
+		t.Errorf("Convert should print the stack but got exit code %d with:\n%s", exitCode, stdout)
+	}
+}
@@ -0,0 +1,374 @@
+package compose
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Builder creates a Stack in code. It builds the same structure as a parsed compose
+// file so the resulting Stack goes through exactly the same parsing and validation
+type Builder struct {
+	config  map[string]interface{}
+	options []StackOption
+}
+
+// NewBuilder creates an empty Builder
+func NewBuilder() *Builder {
+	return &Builder{config: map[string]interface{}{}}
+}
+
+// Builder returns a Builder containing the compose file the Stack was created
+// from. Changes made to the Builder do not affect the Stack
+func (s Stack) Builder() *Builder {
+	return &Builder{config: copyTree(s.source).(map[string]interface{}), options: s.options}
+}
+
+// Build creates the Stack. The options are applied after any options of the Stack the Builder came from
+func (b *Builder) Build(options ...StackOption) (Stack, error) {
+	return NewStack(copyTree(b.config), append(append([]StackOption{}, b.options...), options...)...)
+}
+
+// WithProjectName sets the name of the project
+func (b *Builder) WithProjectName(name string) *Builder {
+	b.config["name"] = name
+	return b
+}
+
+// AddService returns the builder for the service, adding the service if it does not exist
+func (b *Builder) AddService(name string) *ServiceBuilder {
+	return &ServiceBuilder{getSection(b.config, "services", name)}
+}
+
+// RemoveService removes the service if it exists
+func (b *Builder) RemoveService(name string) *Builder {
+	if services, isMap := b.config["services"].(map[string]interface{}); isMap {
+		delete(services, name)
+	}
+	return b
+}
+
+// AddNetwork returns the builder for the network, adding the network if it does not exist
+func (b *Builder) AddNetwork(name string) *NetworkBuilder {
+	return &NetworkBuilder{getSection(b.config, "networks", name)}
+}
+
+// AddVolume returns the builder for the volume, adding the volume if it does not exist
+func (b *Builder) AddVolume(name string) *VolumeBuilder {
+	return &VolumeBuilder{getSection(b.config, "volumes", name)}
+}
+
+// AddSecretFile adds a secret that is read from a file
+func (b *Builder) AddSecretFile(name, file string) *Builder {
+	getSection(b.config, "secrets", name)["file"] = file
+	return b
+}
+
+// ServiceBuilder sets the options of a single service
+type ServiceBuilder struct {
+	config map[string]interface{}
+}
+
+// With sets a service option that has no dedicated method, e.g. With("stop_signal", "SIGINT").
+// The value is given as it would be written in the compose file
+func (s *ServiceBuilder) With(option string, value interface{}) *ServiceBuilder {
+	s.config[option] = value
+	return s
+}
+
+func (s *ServiceBuilder) WithImage(image string) *ServiceBuilder {
+	return s.With("image", image)
+}
+
+func (s *ServiceBuilder) WithCommand(command ...string) *ServiceBuilder {
+	return s.With("command", toList(command))
+}
+
+func (s *ServiceBuilder) WithEntrypoint(entrypoint ...string) *ServiceBuilder {
+	return s.With("entrypoint", toList(entrypoint))
+}
+
+func (s *ServiceBuilder) WithContainerName(name string) *ServiceBuilder {
+	return s.With("container_name", name)
+}
+
+func (s *ServiceBuilder) WithPlatform(platform string) *ServiceBuilder {
+	return s.With("platform", platform)
+}
+
+func (s *ServiceBuilder) WithRestart(policy string) *ServiceBuilder {
+	return s.With("restart", policy)
+}
+
+func (s *ServiceBuilder) WithReplicas(replicas int) *ServiceBuilder {
+	return s.With("scale", replicas)
+}
+
+func (s *ServiceBuilder) WithNetworkMode(mode string) *ServiceBuilder {
+	return s.With("network_mode", mode)
+}
+
+// WithEnv sets the environment variable, replacing any existing value
+func (s *ServiceBuilder) WithEnv(key, value string) *ServiceBuilder {
+	switch environment := s.config["environment"].(type) {
+	case []interface{}:
+		updated := []interface{}{}
+		for _, variable := range environment {
+			if name, isStr := variable.(string); !isStr || strings.SplitN(name, "=", 2)[0] != key {
+				updated = append(updated, variable)
+			}
+		}
+		s.config["environment"] = append(updated, key+"="+value)
+	case map[string]interface{}:
+		environment[key] = value
+	default:
+		s.config["environment"] = map[string]interface{}{key: value}
+	}
+	return s
+}
+
+// WithLabel sets the label, replacing any existing value
+func (s *ServiceBuilder) WithLabel(key, value string) *ServiceBuilder {
+	setMapOption(s.config, "labels", key, value)
+	return s
+}
+
+// WithPort publishes a port using the short syntax, e.g. 127.0.0.1:8080:80/tcp
+func (s *ServiceBuilder) WithPort(port string) *ServiceBuilder {
+	appendOption(s.config, "ports", port)
+	return s
+}
+
+// WithVolume mounts a volume using the short syntax, e.g. data:/var/lib/data:ro
+func (s *ServiceBuilder) WithVolume(volume string) *ServiceBuilder {
+	appendOption(s.config, "volumes", volume)
+	return s
+}
+
+// WithNetwork connects the service to the network with optional aliases
+func (s *ServiceBuilder) WithNetwork(name string, aliases ...string) *ServiceBuilder {
+	var config interface{}
+	if len(aliases) > 0 {
+		config = map[string]interface{}{"aliases": toList(aliases)}
+	}
+	setMapOption(s.config, "networks", name, config)
+	return s
+}
+
+// DependsOn adds a dependency on another service that must meet the condition before this service starts
+func (s *ServiceBuilder) DependsOn(service, condition string) *ServiceBuilder {
+	setMapOption(s.config, "depends_on", service, map[string]interface{}{"condition": condition})
+	return s
+}
+
+// WithHealthCheck sets the healthcheck. A single test is run by the shell, e.g. "pg_isready -U app",
+// otherwise test is the list form starting with CMD, CMD-SHELL or NONE
+func (s *ServiceBuilder) WithHealthCheck(interval, timeout time.Duration, retries int, test ...string) *ServiceBuilder {
+	healthcheck := map[string]interface{}{"test": toList(test), "retries": retries}
+	if len(test) == 1 && test[0] != "NONE" {
+		healthcheck["test"] = test[0]
+	}
+	if interval != 0 {
+		healthcheck["interval"] = interval.String()
+	}
+	if timeout != 0 {
+		healthcheck["timeout"] = timeout.String()
+	}
+	return s.With("healthcheck", healthcheck)
+}
+
+// NetworkBuilder sets the options of a single network
+type NetworkBuilder struct {
+	config map[string]interface{}
+}
+
+// With sets a network option, e.g. With("attachable", true), as it would be in the networks section
+func (n *NetworkBuilder) With(option string, value interface{}) *NetworkBuilder {
+	n.config[option] = value
+	return n
+}
+
+func (n *NetworkBuilder) WithDriver(driver string) *NetworkBuilder {
+	return n.With("driver", driver)
+}
+
+func (n *NetworkBuilder) Internal() *NetworkBuilder {
+	return n.With("internal", true)
+}
+
+func (n *NetworkBuilder) EnableIPv6() *NetworkBuilder {
+	return n.With("enable_ipv6", true)
+}
+
+// External marks the network as created outside of compose. Name can be empty to use the key
+func (n *NetworkBuilder) External(name string) *NetworkBuilder {
+	n.With("external", true)
+	if name != "" {
+		n.With("name", name)
+	}
+	return n
+}
+
+func (n *NetworkBuilder) WithLabel(key, value string) *NetworkBuilder {
+	setMapOption(n.config, "labels", key, value)
+	return n
+}
+
+// WithSubnet adds an IPAM pool. Gateway can be empty
+func (n *NetworkBuilder) WithSubnet(subnet, gateway string) *NetworkBuilder {
+	ipam, isMap := n.config["ipam"].(map[string]interface{})
+	if !isMap {
+		ipam = map[string]interface{}{}
+		n.config["ipam"] = ipam
+	}
+	pool := map[string]interface{}{"subnet": subnet}
+	if gateway != "" {
+		pool["gateway"] = gateway
+	}
+	appendOption(ipam, "config", pool)
+	return n
+}
+
+// VolumeBuilder sets the options of a single volume
+type VolumeBuilder struct {
+	config map[string]interface{}
+}
+
+// With sets a volume option such as labels or name. The value is parsed like the volumes section
+func (v *VolumeBuilder) With(option string, value interface{}) *VolumeBuilder {
+	v.config[option] = value
+	return v
+}
+
+func (v *VolumeBuilder) WithDriver(driver string) *VolumeBuilder {
+	return v.With("driver", driver)
+}
+
+func (v *VolumeBuilder) WithDriverOpt(key, value string) *VolumeBuilder {
+	setMapOption(v.config, "driver_opts", key, value)
+	return v
+}
+
+// External marks the volume as created outside of compose. Name can be empty to use the key
+func (v *VolumeBuilder) External(name string) *VolumeBuilder {
+	v.With("external", true)
+	if name != "" {
+		v.With("name", name)
+	}
+	return v
+}
+
+func (v *VolumeBuilder) WithLabel(key, value string) *VolumeBuilder {
+	setMapOption(v.config, "labels", key, value)
+	return v
+}
+
+// WithImage returns a copy of the Stack with the image of the service replaced
+func (s Stack) WithImage(service, image string) (Stack, error) {
+	if _, err := s.getService(service); err != nil {
+		return Stack{}, err
+	}
+	builder := s.Builder()
+	builder.AddService(service).WithImage(image)
+	return builder.Build()
+}
+
+// WithImageTag returns a copy of the Stack with the tag (or digest) of the service image replaced
+func (s Stack) WithImageTag(service, tag string) (Stack, error) {
+	config, err := s.GetServiceContainerConfig(service)
+	if err != nil {
+		return Stack{}, err
+	}
+	if config.Image == "" {
+		return Stack{}, fmt.Errorf("service %s does not have an image", service)
+	}
+	return s.WithImage(service, replaceImageTag(config.Image, tag))
+}
+
+// WithoutService returns a copy of the Stack with the service removed. This fails
+// validation if other services still reference it
+func (s Stack) WithoutService(service string) (Stack, error) {
+	if _, err := s.getService(service); err != nil {
+		return Stack{}, err
+	}
+	return s.Builder().RemoveService(service).Build()
+}
+
+// WithEnvironment returns a copy of the Stack with the environment variable set on the
+// service. An empty service name sets the variable on every service
+func (s Stack) WithEnvironment(service, key, value string) (Stack, error) {
+	services := []string{service}
+	if service == "" {
+		services = s.ServiceNames()
+	} else if _, err := s.getService(service); err != nil {
+		return Stack{}, err
+	}
+
+	builder := s.Builder()
+	for _, name := range services {
+		builder.AddService(name).WithEnv(key, value)
+	}
+	return builder.Build()
+}
+
+// replaceImageTag removes any tag or digest from the image and adds the new tag
+func replaceImageTag(image, tag string) string {
+	image = strings.SplitN(image, "@", 2)[0]
+	if colon := strings.LastIndex(image, ":"); colon > strings.LastIndex(image, "/") {
+		image = image[:colon]
+	}
+	if strings.HasPrefix(tag, "sha256:") {
+		return image + "@" + tag
+	}
+	return image + ":" + tag
+}
+
+// getSection returns the named element of a top-level section, creating both if they do not exist
+func getSection(config map[string]interface{}, section, name string) map[string]interface{} {
+	elements, isMap := config[section].(map[string]interface{})
+	if !isMap {
+		elements = map[string]interface{}{}
+		config[section] = elements
+	}
+	element, isMap := elements[name].(map[string]interface{})
+	if !isMap {
+		element = map[string]interface{}{}
+		elements[name] = element
+	}
+	return element
+}
+
+// setMapOption sets a key of an option in the map form, converting the list form if required
+func setMapOption(config map[string]interface{}, option, key string, value interface{}) {
+	values, isMap := config[option].(map[string]interface{})
+	if !isMap {
+		values = map[string]interface{}{}
+		if list, isList := config[option].([]interface{}); isList {
+			for _, element := range list {
+				if element, isStr := element.(string); isStr {
+					parts := strings.SplitN(element, "=", 2)
+					if len(parts) == 2 {
+						values[parts[0]] = parts[1]
+					} else {
+						values[parts[0]] = nil
+					}
+				}
+			}
+		}
+		config[option] = values
+	}
+	values[key] = value
+}
+
+func appendOption(config map[string]interface{}, option string, value interface{}) {
+	list, _ := config[option].([]interface{})
+	config[option] = append(list, value)
+}
+
+func toList(values []string) []interface{} {
+	list := []interface{}{}
+	for _, value := range values {
+		list = append(list, value)
+	}
+	return list
+}
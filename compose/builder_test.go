@@ -0,0 +1,183 @@
+package compose_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rmasp98/go-compose/compose"
+)
+
+func newBuiltStack(t *testing.T) compose.Stack {
+	builder := compose.NewBuilder().WithProjectName("app")
+	builder.AddService("web").
+		WithImage("nginx:1.19").
+		WithCommand("nginx", "-g", "daemon off;").
+		WithPort("8080:80").
+		WithVolume("data:/data").
+		WithNetwork("frontend", "www").
+		WithEnv("MODE", "prod").
+		DependsOn("db", compose.DependencyHealthy)
+	builder.AddService("db").
+		WithImage("postgres@sha256:abc").
+		WithEnv("POSTGRES_DB", "app").
+		WithHealthCheck(time.Second, 0, 3, "CMD", "pg_isready")
+	builder.AddNetwork("frontend").WithSubnet("172.30.0.0/16", "172.30.0.1")
+	builder.AddVolume("data").WithDriver("local")
+
+	stack, err := builder.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stack
+}
+
+func TestBuilderCreatesStack(t *testing.T) {
+	stack := newBuiltStack(t)
+
+	if stack.GetProjectName() != "app" {
+		t.Errorf("Project name should be \"app\" but got \"%s\"", stack.GetProjectName())
+	}
+	config, _ := stack.GetServiceContainerConfig("web")
+	if err := verifyValue([]string{"nginx", "-g", "daemon off;"}, []string(config.Cmd)); err != nil {
+		t.Errorf("command: %s", err.Error())
+	}
+	hostConfig, _ := stack.GetServiceHostConfig("web")
+	if _, published := hostConfig.PortBindings["80/tcp"]; !published {
+		t.Errorf("Port was not published: %v", hostConfig.PortBindings)
+	}
+	service, _ := stack.GetService("web")
+	if service.GetDependencies()["db"] != compose.DependencyHealthy {
+		t.Errorf("Dependency was not set: %v", service.GetDependencies())
+	}
+	if ipam := stack.GetNetworkCreate("frontend").IPAM; ipam == nil || len(ipam.Config) != 1 || ipam.Config[0].Gateway != "172.30.0.1" {
+		t.Errorf("IPAM was not set: %v", ipam)
+	}
+}
+
+func TestBuilderHealthCheckAcceptsShellAndListForms(t *testing.T) {
+	tests := map[string]struct {
+		test     []string
+		expected []string
+	}{
+		"shell":   {[]string{"pg_isready -U app"}, []string{"CMD-SHELL", "pg_isready -U app"}},
+		"exec":    {[]string{"CMD", "pg_isready", "-U", "app"}, []string{"CMD", "pg_isready", "-U", "app"}},
+		"disable": {[]string{"NONE"}, []string{"NONE"}},
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			builder := compose.NewBuilder()
+			builder.AddService("db").WithImage("postgres").WithHealthCheck(time.Second, 0, 3, data.test...)
+			stack, err := builder.Build()
+			if err != nil {
+				t.Fatal(err)
+			}
+			config, _ := stack.GetServiceContainerConfig("db")
+			if err := verifyValue(data.expected, config.Healthcheck.Test); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestBuilderIsValidatedLikeComposeFile(t *testing.T) {
+	builder := compose.NewBuilder()
+	builder.AddService("web").WithImage("nginx").WithNetwork("missing")
+	_, err := builder.Build()
+
+	var validationErr compose.ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Issues[0].Rule != compose.RuleUndefinedNetwork {
+		t.Errorf("Expected undefined network error but got %v", err)
+	}
+
+	builder = compose.NewBuilder()
+	builder.AddService("web").WithImage("nginx").WithPort("not a port")
+	if _, err := builder.Build(); err == nil {
+		t.Error("Expected invalid port to be rejected")
+	}
+}
+
+func TestStackCanOverrideImageTag(t *testing.T) {
+	stack := newBuiltStack(t)
+	testData := map[string]struct {
+		service  string
+		tag      string
+		expected string
+	}{
+		"tag":           {"web", "1.21", "nginx:1.21"},
+		"replaceDigest": {"db", "13", "postgres:13"},
+		"digest":        {"web", "sha256:def", "nginx@sha256:def"},
+	}
+
+	for name, data := range testData {
+		t.Run(name, func(t *testing.T) {
+			updated, err := stack.WithImageTag(data.service, data.tag)
+			if err != nil {
+				t.Fatal(err)
+			}
+			config, _ := updated.GetServiceContainerConfig(data.service)
+			if config.Image != data.expected {
+				t.Errorf("Image should be \"%s\" but got \"%s\"", data.expected, config.Image)
+			}
+		})
+	}
+
+	if _, err := stack.WithImageTag("missing", "latest"); err == nil {
+		t.Error("Expected error for unknown service")
+	}
+}
+
+func TestMutationsDoNotChangeOriginalStack(t *testing.T) {
+	stack := newBuiltStack(t)
+	if _, err := stack.WithEnvironment("", "DEBUG", "1"); err != nil {
+		t.Fatal(err)
+	}
+	config, _ := stack.GetServiceContainerConfig("web")
+	if err := verifyValue([]string{"MODE=prod"}, config.Env); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestStackCanInjectEnvironment(t *testing.T) {
+	stack, err := compose.NewStack(parseYaml("services:\n  web:\n    image: nginx\n    environment:\n      - MODE=dev\n      - KEEP"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	updated, err := stack.WithEnvironment("web", "MODE", "prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, _ := updated.GetServiceContainerConfig("web")
+	if err := verifyValue([]string{"KEEP", "MODE=prod"}, config.Env); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRemovingServiceIsValidated(t *testing.T) {
+	stack := newBuiltStack(t)
+	if _, err := stack.WithoutService("db"); err == nil {
+		t.Error("Expected error when removing a service that is depended on")
+	}
+
+	updated, err := stack.WithoutService("web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyValue([]string{"db"}, updated.ServiceNames()); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBuilderKeepsStackOptions(t *testing.T) {
+	stack, err := compose.NewStack(parseYaml("services:\n  web:\n    image: nginx"), compose.WithProjectName("override"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	updated, err := stack.WithImage("web", "httpd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.GetProjectName() != "override" {
+		t.Errorf("Project name should be \"override\" but got \"%s\"", updated.GetProjectName())
+	}
+}
@@ -0,0 +1,101 @@
+package compose
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	networktypes "github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/volume"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// Client contains the methods of the docker client used to manage a Stack. The
+// signatures match github.com/docker/docker/client so *client.Client can be used directly
+type Client interface {
+	ContainerAttach(ctx context.Context, container string, options types.ContainerAttachOptions) (types.HijackedResponse, error)
+	ContainerCreate(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *networktypes.NetworkingConfig, platform *v1.Platform, containerName string) (container.ContainerCreateCreatedBody, error)
+	ContainerExecAttach(ctx context.Context, execID string, config types.ExecStartCheck) (types.HijackedResponse, error)
+	ContainerExecCreate(ctx context.Context, container string, config types.ExecConfig) (types.IDResponse, error)
+	ContainerExecInspect(ctx context.Context, execID string) (types.ContainerExecInspect, error)
+	ContainerExecStart(ctx context.Context, execID string, config types.ExecStartCheck) error
+	ContainerInspect(ctx context.Context, container string) (types.ContainerJSON, error)
+	ContainerKill(ctx context.Context, container, signal string) error
+	ContainerList(ctx context.Context, options types.ContainerListOptions) ([]types.Container, error)
+	ContainerLogs(ctx context.Context, container string, options types.ContainerLogsOptions) (io.ReadCloser, error)
+	ContainerPause(ctx context.Context, container string) error
+	ContainerRemove(ctx context.Context, container string, options types.ContainerRemoveOptions) error
+	ContainerRestart(ctx context.Context, container string, timeout *time.Duration) error
+	ContainerStart(ctx context.Context, container string, options types.ContainerStartOptions) error
+	ContainerStop(ctx context.Context, container string, timeout *time.Duration) error
+	ContainerUnpause(ctx context.Context, container string) error
+	ContainerWait(ctx context.Context, container string, condition container.WaitCondition) (<-chan container.ContainerWaitOKBody, <-chan error)
+
+	Events(ctx context.Context, options types.EventsOptions) (<-chan events.Message, <-chan error)
+
+	ImageList(ctx context.Context, options types.ImageListOptions) ([]types.ImageSummary, error)
+	ImagePull(ctx context.Context, ref string, options types.ImagePullOptions) (io.ReadCloser, error)
+	ImageRemove(ctx context.Context, image string, options types.ImageRemoveOptions) ([]types.ImageDeleteResponseItem, error)
+
+	NetworkConnect(ctx context.Context, network, container string, config *networktypes.EndpointSettings) error
+	NetworkCreate(ctx context.Context, name string, options types.NetworkCreate) (types.NetworkCreateResponse, error)
+	NetworkList(ctx context.Context, options types.NetworkListOptions) ([]types.NetworkResource, error)
+	NetworkRemove(ctx context.Context, network string) error
+
+	VolumeCreate(ctx context.Context, options volume.VolumeCreateBody) (types.Volume, error)
+	VolumeList(ctx context.Context, filter filters.Args) (volume.VolumeListOKBody, error)
+	VolumeRemove(ctx context.Context, volumeID string, force bool) error
+}
+
+// projectFilter returns the filter matching all resources with the project label
+func (s Stack) projectFilter() filters.Args {
+	return filters.NewArgs(filters.Arg("label", ProjectLabel+"="+s.projectName))
+}
+
+// networkExists checks for a network with exactly the name. The name filter of the daemon also matches substrings
+func networkExists(ctx context.Context, client Client, name string) (bool, error) {
+	networks, err := client.NetworkList(ctx, types.NetworkListOptions{Filters: filters.NewArgs(filters.Arg("name", name))})
+	if err != nil {
+		return false, err
+	}
+	for _, network := range networks {
+		if network.Name == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// volumeExists checks for a volume with exactly the name. The name filter of the daemon also matches substrings
+func volumeExists(ctx context.Context, client Client, name string) (bool, error) {
+	volumes, err := client.VolumeList(ctx, filters.NewArgs(filters.Arg("name", name)))
+	if err != nil {
+		return false, err
+	}
+	for _, volume := range volumes.Volumes {
+		if volume != nil && volume.Name == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// imageID returns the ID of the image or an empty string if it is not present
+func imageID(ctx context.Context, client Client, image string) (string, error) {
+	images, err := client.ImageList(ctx, types.ImageListOptions{Filters: filters.NewArgs(filters.Arg("reference", image))})
+	if err != nil || len(images) == 0 {
+		return "", err
+	}
+	return images[0].ID, nil
+}
+
+// isNotFound returns whether the error is the daemon reporting that the object does not exist
+func isNotFound(err error) bool {
+	var notFound interface{ NotFound() }
+	return errors.As(err, &notFound)
+}
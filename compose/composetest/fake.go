@@ -0,0 +1,1016 @@
+// Package composetest provides an in-memory fake of the docker daemon so code built on
+// compose.Stack can be tested without docker
+package composetest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	networktypes "github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/docker/go-connections/nat"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/rmasp98/go-compose/compose"
+)
+
+var _ compose.Client = (*FakeClient)(nil)
+
+// FakeClient implements compose.Client by keeping the state of a daemon in memory. Every
+// call that changes the state is recorded. It is safe for concurrent use
+type FakeClient struct {
+	mu         sync.Mutex
+	containers []*fakeContainer
+	networks   map[string]types.NetworkResource
+	volumes    map[string]types.Volume
+	images     map[string]string
+	pullErrors map[string]string
+	failures   map[string]error
+	calls      []string
+	nextID     int
+
+	// Scripted states returned by successive calls to ContainerInspect keyed by container name
+	healthScripts map[string][]string
+	stateScripts  map[string][]string
+	exitCodes     map[string]int
+
+	execs   map[string]*fakeExec
+	process Process
+
+	events          []events.Message
+	eventsLost      error
+	eventGeneration int
+}
+
+type fakeContainer struct {
+	summary    types.Container
+	config     container.Config
+	hostConfig container.HostConfig
+	health     string
+	logs       []logEntry
+	startedAt  time.Time
+	finishedAt time.Time
+	// attached is set by ContainerAttach and runs the process when the container starts
+	attached *hijackedConn
+}
+
+type logEntry struct {
+	stderr bool
+	time   time.Time
+	line   string
+}
+
+// NewFakeClient creates a FakeClient with no resources
+func NewFakeClient() *FakeClient {
+	return &FakeClient{
+		networks:   map[string]types.NetworkResource{},
+		volumes:    map[string]types.Volume{},
+		images:     map[string]string{},
+		pullErrors: map[string]string{},
+		failures:   map[string]error{},
+
+		healthScripts: map[string][]string{},
+		stateScripts:  map[string][]string{},
+		exitCodes:     map[string]int{},
+		execs:         map[string]*fakeExec{},
+	}
+}
+
+// Calls returns every call that changed the state in the form "Method name [args]", e.g. "ContainerStop app-web-1 10s"
+func (f *FakeClient) Calls() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string{}, f.calls...)
+}
+
+// ClearCalls forgets the calls made so far
+func (f *FakeClient) ClearCalls() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = nil
+}
+
+// FailOn makes every call to the method return the error. A nil error removes the failure
+func (f *FakeClient) FailOn(method string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err == nil {
+		delete(f.failures, method)
+	} else {
+		f.failures[method] = err
+	}
+}
+
+// AddImage makes the image available without pulling it. References without a tag are given the latest tag
+func (f *FakeClient) AddImage(reference string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	reference = normaliseImage(reference)
+	f.images[reference] = "sha256:" + reference
+}
+
+// SetImageID changes the ID of the image, as if a newer version of it had been pulled
+func (f *FakeClient) SetImageID(reference, id string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.images[normaliseImage(reference)] = id
+}
+
+// HasImage returns true if the image has been added or pulled
+func (f *FakeClient) HasImage(reference string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.images[normaliseImage(reference)] != ""
+}
+
+// SetPullError makes pulling the image report the message in the progress stream
+func (f *FakeClient) SetPullError(reference, message string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.pullErrors[normaliseImage(reference)] = message
+}
+
+// AddNetwork creates a network without recording a call, e.g. to simulate an external network
+func (f *FakeClient) AddNetwork(name string, labels map[string]string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.networks[name] = types.NetworkResource{ID: f.newID(), Name: name, Labels: labels}
+}
+
+// Network returns the network and whether it exists
+func (f *FakeClient) Network(name string) (types.NetworkResource, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	network, exists := f.networks[name]
+	return network, exists
+}
+
+// AddVolume creates a volume without recording a call, e.g. to simulate an external volume
+func (f *FakeClient) AddVolume(name string, labels map[string]string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.volumes[name] = types.Volume{Name: name, Labels: labels}
+}
+
+// Volume returns the volume and whether it exists
+func (f *FakeClient) Volume(name string) (types.Volume, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	volume, exists := f.volumes[name]
+	return volume, exists
+}
+
+// AddContainer creates a container in the given state without recording a call and returns its ID
+func (f *FakeClient) AddContainer(name, image, state string, labels map[string]string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c := f.addContainer(name, container.Config{Image: image, Labels: labels}, container.HostConfig{})
+	c.summary.State = state
+	return c.summary.ID
+}
+
+// Container returns the summary of the container with the name and whether it exists
+func (f *FakeClient) Container(name string) (types.Container, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if c := f.findContainer(name); c != nil {
+		return c.summary, true
+	}
+	return types.Container{}, false
+}
+
+// SetContainerState changes the state of the container, e.g. to simulate it exiting
+func (f *FakeClient) SetContainerState(name, state string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c := f.findContainer(name)
+	if c == nil {
+		return notFoundError{fmt.Errorf("no such container: %s", name)}
+	}
+	f.setState(c, state)
+	return nil
+}
+
+// SetExitCode sets the exit code reported for the container with the name once it has exited.
+// The container does not need to exist yet but if it does it is set to exited
+func (f *FakeClient) SetExitCode(name string, code int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.exitCodes[name] = code
+	if c := f.findContainer(name); c != nil {
+		f.setState(c, "exited")
+	}
+}
+
+// ScriptHealth sets the health status returned by each call to ContainerInspect for the container
+// with the name, which does not need to exist yet. The last status is kept once the script has been
+// used. A status of "exited" makes the container exit with the code set by SetExitCode. Containers with a healthcheck that are
+// not scripted are healthy as soon as they start
+func (f *FakeClient) ScriptHealth(name string, statuses ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.healthScripts[name] = statuses
+}
+
+// ScriptState sets the container state returned by each call to ContainerInspect for the container
+// with the name, which does not need to exist yet. Use SetExitCode to set the code of an exited state
+func (f *FakeClient) ScriptState(name string, states ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.stateScripts[name] = states
+}
+
+// WriteLogs adds the lines to the stdout or stderr log of the container
+func (f *FakeClient) WriteLogs(name string, stderr bool, lines ...string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c := f.findContainer(name)
+	if c == nil {
+		return notFoundError{fmt.Errorf("no such container: %s", name)}
+	}
+	for _, line := range lines {
+		c.logs = append(c.logs, logEntry{stderr, time.Now().UTC(), line})
+	}
+	return nil
+}
+
+func (f *FakeClient) ContainerCreate(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *networktypes.NetworkingConfig, platform *v1.Platform, name string) (container.ContainerCreateCreatedBody, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("ContainerCreate", name); err != nil {
+		return container.ContainerCreateCreatedBody{}, err
+	}
+	if f.findContainer(name) != nil {
+		return container.ContainerCreateCreatedBody{}, fmt.Errorf("container name %s is already in use", name)
+	}
+	if f.images[normaliseImage(config.Image)] == "" {
+		return container.ContainerCreateCreatedBody{}, notFoundError{fmt.Errorf("no such image: %s", config.Image)}
+	}
+	if hostConfig == nil {
+		hostConfig = &container.HostConfig{}
+	}
+
+	c := f.addContainer(name, *config, *hostConfig)
+	if networkingConfig != nil {
+		for network, endpoint := range networkingConfig.EndpointsConfig {
+			if _, exists := f.networks[network]; !exists {
+				f.removeContainer(c)
+				return container.ContainerCreateCreatedBody{}, fmt.Errorf("network %s not found", network)
+			}
+			c.summary.NetworkSettings.Networks[network] = endpoint
+		}
+	}
+	f.emitContainer(c, "create", nil)
+	return container.ContainerCreateCreatedBody{ID: c.summary.ID}, nil
+}
+
+func (f *FakeClient) ContainerInspect(ctx context.Context, id string) (types.ContainerJSON, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failures["ContainerInspect"]; err != nil {
+		return types.ContainerJSON{}, err
+	}
+	c := f.findContainer(id)
+	if c == nil {
+		return types.ContainerJSON{}, notFoundError{fmt.Errorf("no such container: %s", id)}
+	}
+
+	name := f.nameOf(c, id)
+	if status := nextScripted(f.healthScripts, name); status == "exited" {
+		f.setState(c, "exited")
+	} else if status != "" && status != c.health {
+		c.health = status
+		f.emitContainer(c, "health_status: "+status, nil)
+	}
+	if state := nextScripted(f.stateScripts, name); state != "" {
+		f.setState(c, state)
+	}
+	return f.inspect(c), nil
+}
+
+func (f *FakeClient) ContainerKill(ctx context.Context, id, signal string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c := f.findContainer(id)
+	if err := f.call("ContainerKill", f.nameOf(c, id), signal); err != nil {
+		return err
+	}
+	if c == nil {
+		return notFoundError{fmt.Errorf("no such container: %s", id)}
+	}
+	if c.summary.State != "running" {
+		return fmt.Errorf("container %s is not running", f.nameOf(c, id))
+	}
+	f.emitContainer(c, "kill", map[string]string{"signal": signal})
+	f.setState(c, "exited")
+	return nil
+}
+
+func (f *FakeClient) ContainerList(ctx context.Context, options types.ContainerListOptions) ([]types.Container, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failures["ContainerList"]; err != nil {
+		return nil, err
+	}
+	var containers []types.Container
+	for _, c := range f.containers {
+		if (options.All || c.summary.State == "running") && matchesLabels(c.summary.Labels, options.Filters) &&
+			matchesName(strings.TrimPrefix(c.summary.Names[0], "/"), options.Filters) {
+			containers = append(containers, c.summary)
+		}
+	}
+	return containers, nil
+}
+
+// ContainerLogs returns the logs in the multiplexed format unless the container uses a TTY. When
+// following, the stream stays open until the container stops, is removed or the context is cancelled
+func (f *FakeClient) ContainerLogs(ctx context.Context, id string, options types.ContainerLogsOptions) (io.ReadCloser, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failures["ContainerLogs"]; err != nil {
+		return nil, err
+	}
+	c := f.findContainer(id)
+	if c == nil {
+		return nil, notFoundError{fmt.Errorf("no such container: %s", id)}
+	}
+
+	start := 0
+	if tail, err := strconv.Atoi(options.Tail); err == nil && tail < len(c.logs) {
+		start = len(c.logs) - tail
+	}
+	reader, writer := io.Pipe()
+	go f.streamLogs(ctx, c, start, options, writer)
+	return reader, nil
+}
+
+func (f *FakeClient) streamLogs(ctx context.Context, c *fakeContainer, next int, options types.ContainerLogsOptions, output *io.PipeWriter) {
+	stdout, stderr := io.Writer(output), io.Writer(output)
+	if !c.config.Tty {
+		stdout, stderr = stdcopy.NewStdWriter(output, stdcopy.Stdout), stdcopy.NewStdWriter(output, stdcopy.Stderr)
+	}
+	for {
+		f.mu.Lock()
+		entries := c.logs[next:]
+		next = len(c.logs)
+		finished := !options.Follow || c.summary.State != "running" || f.findContainer(c.summary.ID) != c
+		f.mu.Unlock()
+
+		for _, entry := range entries {
+			if (entry.stderr && !options.ShowStderr) || (!entry.stderr && !options.ShowStdout) {
+				continue
+			}
+			line := entry.line + "\n"
+			if options.Timestamps {
+				line = entry.time.Format(time.RFC3339Nano) + " " + line
+			}
+			writer := stdout
+			if entry.stderr {
+				writer = stderr
+			}
+			if _, err := writer.Write([]byte(line)); err != nil {
+				return
+			}
+		}
+		if finished {
+			output.Close()
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			output.CloseWithError(ctx.Err())
+			return
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+}
+
+func (f *FakeClient) ContainerPause(ctx context.Context, id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c := f.findContainer(id)
+	if err := f.call("ContainerPause", f.nameOf(c, id)); err != nil {
+		return err
+	}
+	if c == nil {
+		return notFoundError{fmt.Errorf("no such container: %s", id)}
+	}
+	if c.summary.State != "running" {
+		return fmt.Errorf("container %s is not running", f.nameOf(c, id))
+	}
+	f.setState(c, "paused")
+	return nil
+}
+
+func (f *FakeClient) ContainerRemove(ctx context.Context, id string, options types.ContainerRemoveOptions) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c := f.findContainer(id)
+	if err := f.call("ContainerRemove", f.nameOf(c, id)); err != nil {
+		return err
+	}
+	if c == nil {
+		return notFoundError{fmt.Errorf("no such container: %s", id)}
+	}
+	if c.summary.State == "running" && !options.Force {
+		return fmt.Errorf("cannot remove running container %s", f.nameOf(c, id))
+	}
+	f.setState(c, "exited")
+	f.emitContainer(c, "destroy", nil)
+	f.removeContainer(c)
+	return nil
+}
+
+func (f *FakeClient) ContainerRestart(ctx context.Context, id string, timeout *time.Duration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c := f.findContainer(id)
+	args := []string{f.nameOf(c, id)}
+	if timeout != nil {
+		args = append(args, timeout.String())
+	}
+	if err := f.call("ContainerRestart", args...); err != nil {
+		return err
+	}
+	if c == nil {
+		return notFoundError{fmt.Errorf("no such container: %s", id)}
+	}
+	f.setState(c, "exited")
+	f.setState(c, "running")
+	f.emitContainer(c, "restart", nil)
+	return nil
+}
+
+func (f *FakeClient) ContainerStart(ctx context.Context, id string, options types.ContainerStartOptions) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c := f.findContainer(id)
+	if err := f.call("ContainerStart", f.nameOf(c, id)); err != nil {
+		return err
+	}
+	if c == nil {
+		return notFoundError{fmt.Errorf("no such container: %s", id)}
+	}
+	f.setState(c, "running")
+	if attached := c.attached; attached != nil {
+		c.attached = nil
+		go f.runAttached(c, attached)
+	}
+	return nil
+}
+
+func (f *FakeClient) ContainerStop(ctx context.Context, id string, timeout *time.Duration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c := f.findContainer(id)
+	args := []string{f.nameOf(c, id)}
+	if timeout != nil {
+		args = append(args, timeout.String())
+	}
+	if err := f.call("ContainerStop", args...); err != nil {
+		return err
+	}
+	if c == nil {
+		return notFoundError{fmt.Errorf("no such container: %s", id)}
+	}
+	f.setState(c, "exited")
+	f.emitContainer(c, "stop", nil)
+	return nil
+}
+
+func (f *FakeClient) ContainerUnpause(ctx context.Context, id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c := f.findContainer(id)
+	if err := f.call("ContainerUnpause", f.nameOf(c, id)); err != nil {
+		return err
+	}
+	if c == nil {
+		return notFoundError{fmt.Errorf("no such container: %s", id)}
+	}
+	if c.summary.State != "paused" {
+		return fmt.Errorf("container %s is not paused", f.nameOf(c, id))
+	}
+	f.setState(c, "running")
+	return nil
+}
+
+func (f *FakeClient) ImageList(ctx context.Context, options types.ImageListOptions) ([]types.ImageSummary, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failures["ImageList"]; err != nil {
+		return nil, err
+	}
+	var images []types.ImageSummary
+	for _, reference := range sortedKeys(f.images) {
+		matches := true
+		for _, filter := range options.Filters.Get("reference") {
+			matches = matches && reference == normaliseImage(filter)
+		}
+		if matches {
+			images = append(images, types.ImageSummary{ID: f.images[reference], RepoTags: []string{reference}})
+		}
+	}
+	return images, nil
+}
+
+func (f *FakeClient) ImagePull(ctx context.Context, ref string, options types.ImagePullOptions) (io.ReadCloser, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ref = normaliseImage(ref)
+	if err := f.call("ImagePull", ref); err != nil {
+		return nil, err
+	}
+	if message, fails := f.pullErrors[ref]; fails {
+		return ioutil.NopCloser(strings.NewReader(fmt.Sprintf(`{"status":"Pulling from %s"}{"error":%q}`, ref, message))), nil
+	}
+	f.images[ref] = "sha256:" + ref
+	return ioutil.NopCloser(strings.NewReader(fmt.Sprintf(`{"status":"Pulling from %s"}{"status":"Downloaded newer image for %s"}`, ref, ref))), nil
+}
+
+func (f *FakeClient) ImageRemove(ctx context.Context, image string, options types.ImageRemoveOptions) ([]types.ImageDeleteResponseItem, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	image = normaliseImage(image)
+	if err := f.call("ImageRemove", image); err != nil {
+		return nil, err
+	}
+	if f.images[image] == "" {
+		return nil, notFoundError{fmt.Errorf("no such image: %s", image)}
+	}
+	delete(f.images, image)
+	return []types.ImageDeleteResponseItem{{Untagged: image}}, nil
+}
+
+func (f *FakeClient) NetworkConnect(ctx context.Context, network, id string, config *networktypes.EndpointSettings) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c := f.findContainer(id)
+	if err := f.call("NetworkConnect", network, f.nameOf(c, id)); err != nil {
+		return err
+	}
+	if c == nil {
+		return notFoundError{fmt.Errorf("no such container: %s", id)}
+	}
+	if _, exists := f.networks[network]; !exists {
+		return fmt.Errorf("network %s not found", network)
+	}
+	c.summary.NetworkSettings.Networks[network] = config
+	f.emit(events.NetworkEventType, "connect", f.networks[network].ID, map[string]string{"name": network, "container": c.summary.ID})
+	return nil
+}
+
+func (f *FakeClient) NetworkCreate(ctx context.Context, name string, options types.NetworkCreate) (types.NetworkCreateResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("NetworkCreate", name); err != nil {
+		return types.NetworkCreateResponse{}, err
+	}
+	if _, exists := f.networks[name]; exists && options.CheckDuplicate {
+		return types.NetworkCreateResponse{}, fmt.Errorf("network with name %s already exists", name)
+	}
+	network := types.NetworkResource{
+		ID:         f.newID(),
+		Name:       name,
+		Driver:     options.Driver,
+		EnableIPv6: options.EnableIPv6,
+		Internal:   options.Internal,
+		Attachable: options.Attachable,
+		Options:    options.Options,
+		Labels:     options.Labels,
+	}
+	if options.IPAM != nil {
+		network.IPAM = *options.IPAM
+	}
+	f.networks[name] = network
+	f.emit(events.NetworkEventType, "create", network.ID, map[string]string{"name": name, "type": options.Driver})
+	return types.NetworkCreateResponse{ID: network.ID}, nil
+}
+
+func (f *FakeClient) NetworkList(ctx context.Context, options types.NetworkListOptions) ([]types.NetworkResource, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failures["NetworkList"]; err != nil {
+		return nil, err
+	}
+	var networks []types.NetworkResource
+	for _, name := range sortedKeys(f.networks) {
+		if network := f.networks[name]; matchesName(name, options.Filters) && matchesLabels(network.Labels, options.Filters) {
+			networks = append(networks, network)
+		}
+	}
+	return networks, nil
+}
+
+func (f *FakeClient) NetworkRemove(ctx context.Context, id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for name, network := range f.networks {
+		if network.ID == id || name == id {
+			if err := f.call("NetworkRemove", name); err != nil {
+				return err
+			}
+			for _, c := range f.containers {
+				if _, connected := c.summary.NetworkSettings.Networks[name]; connected {
+					return fmt.Errorf("network %s has active endpoints", name)
+				}
+			}
+			delete(f.networks, name)
+			f.emit(events.NetworkEventType, "destroy", network.ID, map[string]string{"name": name, "type": network.Driver})
+			return nil
+		}
+	}
+	if err := f.call("NetworkRemove", id); err != nil {
+		return err
+	}
+	return fmt.Errorf("network %s not found", id)
+}
+
+func (f *FakeClient) VolumeCreate(ctx context.Context, options volume.VolumeCreateBody) (types.Volume, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("VolumeCreate", options.Name); err != nil {
+		return types.Volume{}, err
+	}
+	if options.Name == "" {
+		options.Name = f.newID()
+	}
+	volume := types.Volume{Name: options.Name, Driver: options.Driver, Labels: options.Labels, Options: options.DriverOpts}
+	f.volumes[options.Name] = volume
+	f.emit(events.VolumeEventType, "create", volume.Name, map[string]string{"driver": volume.Driver})
+	return volume, nil
+}
+
+func (f *FakeClient) VolumeList(ctx context.Context, filter filters.Args) (volume.VolumeListOKBody, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failures["VolumeList"]; err != nil {
+		return volume.VolumeListOKBody{}, err
+	}
+	body := volume.VolumeListOKBody{Volumes: []*types.Volume{}}
+	for _, name := range sortedKeys(f.volumes) {
+		if volume := f.volumes[name]; matchesName(name, filter) && matchesLabels(volume.Labels, filter) {
+			body.Volumes = append(body.Volumes, &volume)
+		}
+	}
+	return body, nil
+}
+
+func (f *FakeClient) VolumeRemove(ctx context.Context, id string, force bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("VolumeRemove", id); err != nil {
+		return err
+	}
+	if _, exists := f.volumes[id]; !exists {
+		return notFoundError{fmt.Errorf("no such volume: %s", id)}
+	}
+	driver := f.volumes[id].Driver
+	delete(f.volumes, id)
+	f.emit(events.VolumeEventType, "destroy", id, map[string]string{"driver": driver})
+	return nil
+}
+
+func (f *FakeClient) inspect(c *fakeContainer) types.ContainerJSON {
+	config, hostConfig := c.config, c.hostConfig
+	state := &types.ContainerState{
+		Status:     c.summary.State,
+		Running:    c.summary.State == "running",
+		Paused:     c.summary.State == "paused",
+		Restarting: c.summary.State == "restarting",
+		Dead:       c.summary.State == "dead",
+		StartedAt:  c.startedAt.Format(time.RFC3339Nano),
+		FinishedAt: c.finishedAt.Format(time.RFC3339Nano),
+	}
+	// Ports are only bound while the container is running
+	ports := nat.PortMap{}
+	if state.Running || state.Paused {
+		for port, bindings := range hostConfig.PortBindings {
+			for _, binding := range bindings {
+				if binding.HostIP == "" {
+					binding.HostIP = "0.0.0.0"
+				}
+				ports[port] = append(ports[port], binding)
+			}
+		}
+	}
+	if state.Status == "exited" || state.Status == "dead" {
+		state.ExitCode = f.exitCodes[f.nameOf(c, "")]
+	}
+	if healthcheck := config.Healthcheck; healthcheck != nil && len(healthcheck.Test) > 0 && healthcheck.Test[0] != "NONE" {
+		state.Health = &types.Health{Status: c.health}
+		if c.health == "" {
+			state.Health.Status = types.Healthy
+		}
+	}
+	return types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{
+			ID:         c.summary.ID,
+			Name:       c.summary.Names[0],
+			Image:      c.summary.ImageID,
+			State:      state,
+			HostConfig: &hostConfig,
+		},
+		Config: &config,
+		NetworkSettings: &types.NetworkSettings{
+			NetworkSettingsBase: types.NetworkSettingsBase{Ports: ports},
+			Networks:            c.summary.NetworkSettings.Networks,
+		},
+	}
+}
+
+// nextScripted removes and returns the next value of the script, keeping the last value
+func nextScripted(scripts map[string][]string, name string) string {
+	script := scripts[name]
+	if len(script) == 0 {
+		return ""
+	}
+	if len(script) > 1 {
+		scripts[name] = script[1:]
+	}
+	return script[0]
+}
+
+// DisconnectEvents makes every open event stream fail with the error, simulating the daemon going away
+func (f *FakeClient) DisconnectEvents(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.eventGeneration++
+	f.eventsLost = err
+}
+
+// Events streams the events of every change made through the fake. Since and Until accept unix
+// timestamps with optional nanoseconds or RFC3339 times. The type, event and label filters are supported
+func (f *FakeClient) Events(ctx context.Context, options types.EventsOptions) (<-chan events.Message, <-chan error) {
+	messages, errs := make(chan events.Message), make(chan error, 1)
+	since, err := parseEventTime(options.Since)
+	if err != nil {
+		errs <- err
+		return messages, errs
+	}
+	until, err := parseEventTime(options.Until)
+	if err != nil {
+		errs <- err
+		return messages, errs
+	}
+
+	f.mu.Lock()
+	generation := f.eventGeneration
+	f.mu.Unlock()
+
+	go func() {
+		next := 0
+		for {
+			f.mu.Lock()
+			pending := f.events[next:]
+			next = len(f.events)
+			disconnected := f.eventGeneration != generation
+			lost := f.eventsLost
+			f.mu.Unlock()
+
+			for _, message := range pending {
+				if until != 0 && message.TimeNano > until {
+					errs <- io.EOF
+					return
+				}
+				if message.TimeNano < since || !matchesEvent(message, options.Filters) {
+					continue
+				}
+				select {
+				case messages <- message:
+				case <-ctx.Done():
+					errs <- ctx.Err()
+					return
+				}
+			}
+			if disconnected {
+				errs <- lost
+				return
+			}
+			if until != 0 && time.Now().UnixNano() > until {
+				errs <- io.EOF
+				return
+			}
+
+			select {
+			case <-ctx.Done():
+				errs <- ctx.Err()
+				return
+			case <-time.After(2 * time.Millisecond):
+			}
+		}
+	}()
+	return messages, errs
+}
+
+// emit records an event. Event times always increase so since can be used to resume a stream
+func (f *FakeClient) emit(eventType, action, id string, attributes map[string]string) {
+	timeNano := time.Now().UnixNano()
+	if count := len(f.events); count > 0 && timeNano <= f.events[count-1].TimeNano {
+		timeNano = f.events[count-1].TimeNano + 1
+	}
+	f.events = append(f.events, events.Message{
+		Type:     eventType,
+		Action:   action,
+		Actor:    events.Actor{ID: id, Attributes: attributes},
+		Time:     timeNano / int64(time.Second),
+		TimeNano: timeNano,
+	})
+}
+
+// emitContainer records an event for the container with its labels, name and image as attributes
+func (f *FakeClient) emitContainer(c *fakeContainer, action string, extra map[string]string) {
+	attributes := map[string]string{"name": strings.TrimPrefix(c.summary.Names[0], "/"), "image": c.summary.Image}
+	for key, value := range c.summary.Labels {
+		attributes[key] = value
+	}
+	for key, value := range extra {
+		attributes[key] = value
+	}
+	f.emit(events.ContainerEventType, action, c.summary.ID, attributes)
+}
+
+// setState changes the state of the container and records the events the daemon would send
+func (f *FakeClient) setState(c *fakeContainer, state string) {
+	if c.summary.State == state {
+		return
+	}
+	previous := c.summary.State
+	c.summary.State = state
+	switch state {
+	case "running":
+		if previous != "paused" {
+			c.startedAt = time.Now().UTC()
+		}
+		if previous == "paused" {
+			f.emitContainer(c, "unpause", nil)
+		} else {
+			f.emitContainer(c, "start", nil)
+		}
+	case "paused":
+		f.emitContainer(c, "pause", nil)
+	case "exited", "dead":
+		c.finishedAt = time.Now().UTC()
+		f.emitContainer(c, "die", map[string]string{"exitCode": strconv.Itoa(f.exitCodes[f.nameOf(c, "")])})
+	}
+}
+
+func matchesEvent(message events.Message, filter filters.Args) bool {
+	if types := filter.Get("type"); len(types) > 0 {
+		matches := false
+		for _, eventType := range types {
+			matches = matches || eventType == message.Type
+		}
+		if !matches {
+			return false
+		}
+	}
+	if actions := filter.Get("event"); len(actions) > 0 {
+		matches := false
+		for _, action := range actions {
+			matches = matches || action == message.Action
+		}
+		if !matches {
+			return false
+		}
+	}
+	return matchesLabels(message.Actor.Attributes, filter)
+}
+
+// parseEventTime parses a unix time with optional nanoseconds or an RFC3339 time. Empty returns 0
+func parseEventTime(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	if parsed, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return parsed.UnixNano(), nil
+	}
+	parts := strings.SplitN(value, ".", 2)
+	seconds, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %s", value)
+	}
+	nanos := int64(0)
+	if len(parts) == 2 {
+		if nanos, err = strconv.ParseInt((parts[1] + "000000000")[:9], 10, 64); err != nil {
+			return 0, fmt.Errorf("invalid time %s", value)
+		}
+	}
+	return seconds*int64(time.Second) + nanos, nil
+}
+
+// call records the call and returns the failure set for the method
+func (f *FakeClient) call(method string, args ...string) error {
+	f.calls = append(f.calls, strings.Join(append([]string{method}, args...), " "))
+	return f.failures[method]
+}
+
+func (f *FakeClient) newID() string {
+	f.nextID++
+	return fmt.Sprintf("%064x", f.nextID)
+}
+
+func (f *FakeClient) addContainer(name string, config container.Config, hostConfig container.HostConfig) *fakeContainer {
+	c := &fakeContainer{
+		summary: types.Container{
+			ID:      f.newID(),
+			Names:   []string{"/" + name},
+			Image:   config.Image,
+			ImageID: f.images[normaliseImage(config.Image)],
+			Labels:  config.Labels,
+			State:   "created",
+			Created: time.Now().Unix(),
+		},
+		config:     config,
+		hostConfig: hostConfig,
+	}
+	c.summary.NetworkSettings = &types.SummaryNetworkSettings{Networks: map[string]*networktypes.EndpointSettings{}}
+	f.containers = append(f.containers, c)
+	return c
+}
+
+func (f *FakeClient) removeContainer(target *fakeContainer) {
+	for i, c := range f.containers {
+		if c == target {
+			f.containers = append(f.containers[:i], f.containers[i+1:]...)
+			return
+		}
+	}
+}
+
+// findContainer finds the container by ID, ID prefix or name
+func (f *FakeClient) findContainer(idOrName string) *fakeContainer {
+	for _, c := range f.containers {
+		if c.summary.Names[0] == "/"+idOrName || (idOrName != "" && strings.HasPrefix(c.summary.ID, idOrName)) {
+			return c
+		}
+	}
+	return nil
+}
+
+// nameOf returns the name of the container for recording calls, falling back to the ID used
+func (f *FakeClient) nameOf(c *fakeContainer, id string) string {
+	if c == nil {
+		return id
+	}
+	return strings.TrimPrefix(c.summary.Names[0], "/")
+}
+
+// notFoundError is returned for objects that do not exist, like the not found errors of the docker client
+type notFoundError struct {
+	error
+}
+
+func (notFoundError) NotFound() {}
+
+// normaliseImage adds the latest tag if the image does not have a tag or digest
+func normaliseImage(image string) string {
+	if strings.Contains(image, "@") || strings.LastIndex(image, ":") > strings.LastIndex(image, "/") {
+		return image
+	}
+	return image + ":latest"
+}
+
+// matchesLabels checks every label filter in the form key or key=value
+func matchesLabels(labels map[string]string, filter filters.Args) bool {
+	for _, label := range filter.Get("label") {
+		parts := strings.SplitN(label, "=", 2)
+		if value, exists := labels[parts[0]]; !exists || (len(parts) == 2 && value != parts[1]) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesName checks every name filter. Like the daemon, names only need to contain the filter
+func matchesName(name string, filter filters.Args) bool {
+	for _, value := range filter.Get("name") {
+		if !strings.Contains(name, value) {
+			return false
+		}
+	}
+	return true
+}
+
+// sortedKeys returns the keys of a map with string keys in sorted order
+func sortedKeys(input interface{}) []string {
+	keys := []string{}
+	for _, key := range reflect.ValueOf(input).MapKeys() {
+		keys = append(keys, key.String())
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,148 @@
+package composetest_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	networktypes "github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/rmasp98/go-compose/compose/composetest"
+)
+
+func TestFakeClientSimulatesContainerLifecycle(t *testing.T) {
+	ctx := context.Background()
+	client := composetest.NewFakeClient()
+	client.AddImage("nginx")
+	client.AddNetwork("net", nil)
+
+	config := &container.Config{Image: "nginx", Labels: map[string]string{"app": "web"}}
+	networking := &networktypes.NetworkingConfig{EndpointsConfig: map[string]*networktypes.EndpointSettings{"net": {}}}
+	created, err := client.ContainerCreate(ctx, config, nil, networking, nil, "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.ContainerStart(ctx, created.ID, types.ContainerStartOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	running, _ := client.ContainerList(ctx, types.ContainerListOptions{Filters: filters.NewArgs(filters.Arg("label", "app=web"))})
+	if len(running) != 1 || running[0].State != "running" || running[0].Image != "nginx" {
+		t.Errorf("Expected running container but got %v", running)
+	}
+	if err := client.ContainerRemove(ctx, created.ID, types.ContainerRemoveOptions{}); err == nil {
+		t.Error("Expected error removing a running container")
+	}
+	if err := client.NetworkRemove(ctx, "net"); err == nil {
+		t.Error("Expected error removing a network with containers")
+	}
+
+	expected := []string{"ContainerCreate web", "ContainerStart web", "ContainerRemove web", "NetworkRemove net"}
+	if strings.Join(client.Calls(), ",") != strings.Join(expected, ",") {
+		t.Errorf("Calls should be %v but got %v", expected, client.Calls())
+	}
+}
+
+func TestFakeClientRejectsMissingImagesAndNetworks(t *testing.T) {
+	ctx := context.Background()
+	client := composetest.NewFakeClient()
+	if _, err := client.ContainerCreate(ctx, &container.Config{Image: "nginx"}, nil, nil, nil, "web"); err == nil {
+		t.Error("Expected error for missing image")
+	}
+
+	client.AddImage("nginx:latest")
+	networking := &networktypes.NetworkingConfig{EndpointsConfig: map[string]*networktypes.EndpointSettings{"missing": {}}}
+	if _, err := client.ContainerCreate(ctx, &container.Config{Image: "nginx"}, nil, networking, nil, "web"); err == nil {
+		t.Error("Expected error for missing network")
+	}
+	if _, exists := client.Container("web"); exists {
+		t.Error("Container should not exist after failing to create")
+	}
+}
+
+func TestFakeClientCanFailCallsAndPulls(t *testing.T) {
+	ctx := context.Background()
+	client := composetest.NewFakeClient()
+	client.FailOn("VolumeCreate", errors.New("disk full"))
+	if _, err := client.VolumeCreate(ctx, volume.VolumeCreateBody{Name: "data"}); err == nil || err.Error() != "disk full" {
+		t.Errorf("Expected injected error but got %v", err)
+	}
+
+	client.SetPullError("nginx", "manifest unknown")
+	stream, err := client.ImagePull(ctx, "nginx", types.ImagePullOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := ioutil.ReadAll(stream)
+	if !strings.Contains(string(data), "manifest unknown") || client.HasImage("nginx") {
+		t.Errorf("Expected pull error in stream but got %s", data)
+	}
+}
+
+func TestFakeClientScriptsHealthTransitions(t *testing.T) {
+	ctx := context.Background()
+	client := composetest.NewFakeClient()
+	client.AddImage("nginx")
+	client.ScriptHealth("web", types.Starting, types.Healthy)
+
+	config := &container.Config{Image: "nginx", Healthcheck: &container.HealthConfig{Test: []string{"CMD", "true"}}}
+	created, _ := client.ContainerCreate(ctx, config, nil, nil, nil, "web")
+	client.ContainerStart(ctx, created.ID, types.ContainerStartOptions{})
+
+	var statuses []string
+	for i := 0; i < 3; i++ {
+		inspect, err := client.ContainerInspect(ctx, created.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		statuses = append(statuses, inspect.State.Health.Status)
+	}
+	if strings.Join(statuses, ",") != "starting,healthy,healthy" {
+		t.Errorf("Unexpected health transitions: %v", statuses)
+	}
+
+	client.SetExitCode("web", 2)
+	if inspect, _ := client.ContainerInspect(ctx, "web"); inspect.State.Status != "exited" || inspect.State.ExitCode != 2 {
+		t.Errorf("Container should have exited with code 2 but got %v", inspect.State)
+	}
+}
+
+func TestFakeClientStreamsFilteredEvents(t *testing.T) {
+	ctx := context.Background()
+	client := composetest.NewFakeClient()
+	client.AddImage("nginx")
+	client.VolumeCreate(ctx, volume.VolumeCreateBody{Name: "data"})
+	created, _ := client.ContainerCreate(ctx, &container.Config{Image: "nginx", Labels: map[string]string{"app": "web"}}, nil, nil, nil, "web")
+	client.ContainerStart(ctx, created.ID, types.ContainerStartOptions{})
+	client.ContainerStop(ctx, created.ID, nil)
+
+	filter := filters.NewArgs(filters.Arg("type", "container"), filters.Arg("label", "app=web"))
+	messages, errs := client.Events(ctx, types.EventsOptions{Since: "0", Until: time.Now().Format(time.RFC3339Nano), Filters: filter})
+	var actions []string
+	for {
+		select {
+		case message := <-messages:
+			if message.Actor.Attributes["name"] != "web" {
+				t.Errorf("Event should have the container name but got %v", message.Actor.Attributes)
+			}
+			actions = append(actions, message.Action)
+			continue
+		case err := <-errs:
+			if err != io.EOF {
+				t.Fatalf("Stream should end with EOF but got %v", err)
+			}
+		}
+		break
+	}
+	expected := []string{"create", "start", "die", "stop"}
+	if strings.Join(actions, ",") != strings.Join(expected, ",") {
+		t.Errorf("Events should be %v but got %v", expected, actions)
+	}
+}
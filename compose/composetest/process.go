@@ -0,0 +1,290 @@
+package composetest
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// Process simulates a command run in a container. Name is the name of the container and
+// command is the entrypoint followed by the command, or the command passed to exec. It
+// returns the exit code of the command
+type Process func(name string, command []string, stdin io.Reader, stdout, stderr io.Writer) int
+
+// SetProcess sets the process run when an attached container starts or a command is executed.
+// Without a process nothing is output and the exit code is the one set by SetExitCode
+func (f *FakeClient) SetProcess(process Process) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.process = process
+}
+
+type fakeExec struct {
+	container *fakeContainer
+	config    types.ExecConfig
+	running   bool
+	exitCode  int
+}
+
+// ContainerAttach connects to the streams of a container that has not started yet. The process
+// set by SetProcess is run with the streams once the container starts
+func (f *FakeClient) ContainerAttach(ctx context.Context, id string, options types.ContainerAttachOptions) (types.HijackedResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failures["ContainerAttach"]; err != nil {
+		return types.HijackedResponse{}, err
+	}
+	c := f.findContainer(id)
+	if c == nil {
+		return types.HijackedResponse{}, notFoundError{fmt.Errorf("no such container: %s", id)}
+	}
+	if c.summary.State == "running" {
+		return types.HijackedResponse{}, fmt.Errorf("attaching to a running container is not supported by the fake")
+	}
+	client, server := newHijackedConn()
+	if !options.Stdin {
+		client.CloseWrite()
+	}
+	c.attached = server
+	return types.HijackedResponse{Conn: client, Reader: bufio.NewReader(client)}, nil
+}
+
+// ContainerWait waits for the container to exit, or to be removed if the condition is removed.
+// Only exits after the call are reported unless the condition is not-running
+func (f *FakeClient) ContainerWait(ctx context.Context, id string, condition container.WaitCondition) (<-chan container.ContainerWaitOKBody, <-chan error) {
+	exits, errs := make(chan container.ContainerWaitOKBody, 1), make(chan error, 1)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c := f.findContainer(id)
+	if c == nil {
+		errs <- notFoundError{fmt.Errorf("no such container: %s", id)}
+		return exits, errs
+	}
+	if (condition == "" || condition == container.WaitConditionNotRunning) && c.summary.State != "running" {
+		exits <- container.ContainerWaitOKBody{StatusCode: int64(f.exitCodes[f.nameOf(c, id)])}
+		return exits, errs
+	}
+
+	next, exitCode := len(f.events), 0
+	go func() {
+		for {
+			f.mu.Lock()
+			pending := f.events[next:]
+			next = len(f.events)
+			f.mu.Unlock()
+
+			for _, message := range pending {
+				if message.Actor.ID != c.summary.ID {
+					continue
+				}
+				if message.Action == "die" {
+					exitCode, _ = strconv.Atoi(message.Actor.Attributes["exitCode"])
+				}
+				if (message.Action == "die" && condition != container.WaitConditionRemoved) || message.Action == "destroy" {
+					exits <- container.ContainerWaitOKBody{StatusCode: int64(exitCode)}
+					return
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				errs <- ctx.Err()
+				return
+			case <-time.After(2 * time.Millisecond):
+			}
+		}
+	}()
+	return exits, errs
+}
+
+func (f *FakeClient) ContainerExecCreate(ctx context.Context, id string, config types.ExecConfig) (types.IDResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c := f.findContainer(id)
+	if err := f.call("ContainerExecCreate", append([]string{f.nameOf(c, id)}, config.Cmd...)...); err != nil {
+		return types.IDResponse{}, err
+	}
+	if c == nil {
+		return types.IDResponse{}, notFoundError{fmt.Errorf("no such container: %s", id)}
+	}
+	if c.summary.State != "running" {
+		return types.IDResponse{}, fmt.Errorf("container %s is not running", f.nameOf(c, id))
+	}
+	execID := f.newID()
+	f.execs[execID] = &fakeExec{container: c, config: config}
+	return types.IDResponse{ID: execID}, nil
+}
+
+// ContainerExecAttach starts the command with the process set by SetProcess attached to the returned connection
+func (f *FakeClient) ContainerExecAttach(ctx context.Context, execID string, config types.ExecStartCheck) (types.HijackedResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	exec, err := f.startExec(execID)
+	if err != nil {
+		return types.HijackedResponse{}, err
+	}
+	client, server := newHijackedConn()
+	if !exec.config.AttachStdin {
+		client.CloseWrite()
+	}
+	go func() {
+		exitCode := f.runProcess(exec.container, exec.config.Cmd, config.Tty, server)
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		exec.running, exec.exitCode = false, exitCode
+	}()
+	return types.HijackedResponse{Conn: client, Reader: bufio.NewReader(client)}, nil
+}
+
+// ContainerExecStart runs the command in the background with no input and the output discarded
+func (f *FakeClient) ContainerExecStart(ctx context.Context, execID string, config types.ExecStartCheck) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	exec, err := f.startExec(execID)
+	if err != nil {
+		return err
+	}
+	client, server := newHijackedConn()
+	client.CloseWrite()
+	go io.Copy(ioutil.Discard, client)
+	go func() {
+		exitCode := f.runProcess(exec.container, exec.config.Cmd, config.Tty, server)
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		exec.running, exec.exitCode = false, exitCode
+	}()
+	return nil
+}
+
+func (f *FakeClient) ContainerExecInspect(ctx context.Context, execID string) (types.ContainerExecInspect, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	exec, exists := f.execs[execID]
+	if !exists {
+		return types.ContainerExecInspect{}, notFoundError{fmt.Errorf("no such exec instance: %s", execID)}
+	}
+	return types.ContainerExecInspect{
+		ExecID:      execID,
+		ContainerID: exec.container.summary.ID,
+		Running:     exec.running,
+		ExitCode:    exec.exitCode,
+	}, nil
+}
+
+func (f *FakeClient) startExec(execID string) (*fakeExec, error) {
+	exec, exists := f.execs[execID]
+	if !exists {
+		return nil, notFoundError{fmt.Errorf("no such exec instance: %s", execID)}
+	}
+	if exec.running {
+		return nil, fmt.Errorf("exec %s is already running", execID)
+	}
+	exec.running = true
+	return exec, nil
+}
+
+// runAttached runs the process of a container started after ContainerAttach. The container
+// exits with the exit code of the process and is removed if it was created with AutoRemove
+func (f *FakeClient) runAttached(c *fakeContainer, attached *hijackedConn) {
+	command := append(append([]string{}, c.config.Entrypoint...), c.config.Cmd...)
+	exitCode := f.runProcess(c, command, c.config.Tty, attached)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.exitCodes[f.nameOf(c, "")] = exitCode
+	f.setState(c, "exited")
+	if c.hostConfig.AutoRemove && f.findContainer(c.summary.ID) == c {
+		f.emitContainer(c, "destroy", nil)
+		f.removeContainer(c)
+	}
+}
+
+// runProcess runs the process with the server side of the connection and closes it once the process returns
+func (f *FakeClient) runProcess(c *fakeContainer, command []string, tty bool, server *hijackedConn) int {
+	f.mu.Lock()
+	name, process := f.nameOf(c, ""), f.process
+	exitCode := f.exitCodes[name]
+	f.mu.Unlock()
+
+	stdout, stderr := io.Writer(server.output), io.Writer(server.output)
+	if !tty {
+		stdout, stderr = stdcopy.NewStdWriter(server.output, stdcopy.Stdout), stdcopy.NewStdWriter(server.output, stdcopy.Stderr)
+	}
+	if process != nil {
+		exitCode = process(name, command, server.input, stdout, stderr)
+	}
+	server.Close()
+	return exitCode
+}
+
+// hijackedConn is one end of the connection returned by ContainerAttach and ContainerExecAttach.
+// Unlike net.Pipe it supports CloseWrite so the process can see the end of its input
+type hijackedConn struct {
+	input  *io.PipeReader
+	output *io.PipeWriter
+	read   *io.PipeReader
+	write  *io.PipeWriter
+}
+
+// newHijackedConn returns the end used by the client and the end used by the process. The
+// process reads its stdin from input and writes its output to output
+func newHijackedConn() (*hijackedConn, *hijackedConn) {
+	stdinReader, stdinWriter := io.Pipe()
+	outputReader, outputWriter := io.Pipe()
+	client := &hijackedConn{read: outputReader, write: stdinWriter}
+	server := &hijackedConn{input: stdinReader, output: outputWriter}
+	return client, server
+}
+
+func (h *hijackedConn) Read(data []byte) (int, error) {
+	if h.read == nil {
+		return 0, io.EOF
+	}
+	return h.read.Read(data)
+}
+
+func (h *hijackedConn) Write(data []byte) (int, error) {
+	if h.write == nil {
+		return 0, io.ErrClosedPipe
+	}
+	return h.write.Write(data)
+}
+
+// CloseWrite closes the input of the process
+func (h *hijackedConn) CloseWrite() error {
+	if h.write != nil {
+		return h.write.Close()
+	}
+	return nil
+}
+
+func (h *hijackedConn) Close() error {
+	if h.input != nil {
+		h.input.Close()
+		h.output.Close()
+	} else {
+		h.read.Close()
+		h.write.Close()
+	}
+	return nil
+}
+
+func (h *hijackedConn) LocalAddr() net.Addr                { return fakeAddr{} }
+func (h *hijackedConn) RemoteAddr() net.Addr               { return fakeAddr{} }
+func (h *hijackedConn) SetDeadline(t time.Time) error      { return nil }
+func (h *hijackedConn) SetReadDeadline(t time.Time) error  { return nil }
+func (h *hijackedConn) SetWriteDeadline(t time.Time) error { return nil }
+
+type fakeAddr struct{}
+
+func (fakeAddr) Network() string { return "fake" }
+func (fakeAddr) String() string  { return "fake" }
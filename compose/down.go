@@ -0,0 +1,170 @@
+package compose
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+)
+
+// DownOptions controls what Down removes in addition to the containers and networks of the project
+type DownOptions struct {
+	// RemoveOrphans removes containers of services that are no longer in the Stack
+	RemoveOrphans bool
+	// RemoveVolumes removes the named volumes of the project and the anonymous volumes of the containers
+	RemoveVolumes bool
+	// RemoveImages removes the images used by the services
+	RemoveImages bool
+	// Timeout overrides the stop_grace_period of every service when set
+	Timeout *time.Duration
+}
+
+const (
+	ActionStopped Action = "stopped"
+	ActionRemoved Action = "removed"
+	ActionSkipped Action = "skipped"
+)
+
+// Down stops and removes the containers of the project in reverse dependency order and
+// then removes the networks and optionally the volumes and images. Resources are found by
+// their project label so external networks and volumes are never removed. Down continues
+// after a failure and returns an error if anything could not be removed
+func (s Stack) Down(ctx context.Context, client Client, options DownOptions) (Report, error) {
+	report := Report{}
+	containers, err := client.ContainerList(ctx, types.ContainerListOptions{All: true, Filters: s.projectFilter()})
+	if err != nil {
+		return report, fmt.Errorf("listing containers: %w", err)
+	}
+
+	for _, c := range s.stopOrder(containers) {
+		service := c.Labels[ServiceLabel]
+		result := Result{Kind: KindContainer, Name: containerName(c), Service: service}
+		if _, inStack := s.services[service]; !inStack && !options.RemoveOrphans {
+			report.record(result, ActionSkipped, nil)
+			continue
+		}
+		report.record(result, ActionRemoved, s.removeContainer(ctx, client, c, options))
+	}
+
+	networks, err := client.NetworkList(ctx, types.NetworkListOptions{Filters: s.projectFilter()})
+	if err != nil {
+		return report, fmt.Errorf("listing networks: %w", err)
+	}
+	sort.Slice(networks, func(i, j int) bool { return networks[i].Name < networks[j].Name })
+	for _, network := range networks {
+		if _, external := s.networks[network.Labels[NetworkLabel]].GetExternalName(); external {
+			continue
+		}
+		err := client.NetworkRemove(ctx, network.ID)
+		report.record(Result{Kind: KindNetwork, Name: network.Name}, ActionRemoved, wrapError("network", network.Name, err))
+	}
+
+	if options.RemoveVolumes {
+		volumes, err := client.VolumeList(ctx, s.projectFilter())
+		if err != nil {
+			return report, fmt.Errorf("listing volumes: %w", err)
+		}
+		sort.Slice(volumes.Volumes, func(i, j int) bool { return volumes.Volumes[i].Name < volumes.Volumes[j].Name })
+		for _, volume := range volumes.Volumes {
+			if _, external := s.volumes[volume.Labels[VolumeLabel]].GetExternalName(); external {
+				continue
+			}
+			err := client.VolumeRemove(ctx, volume.Name, false)
+			report.record(Result{Kind: KindVolume, Name: volume.Name}, ActionRemoved, wrapError("volume", volume.Name, err))
+		}
+	}
+
+	if options.RemoveImages {
+		removed := make(map[string]bool)
+		for _, name := range s.ServiceNames() {
+			image := s.services[name].GetContainerConfig().Image
+			if image == "" || removed[imageWithTag(image)] {
+				continue
+			}
+			image = imageWithTag(image)
+			removed[image] = true
+			_, err := client.ImageRemove(ctx, image, types.ImageRemoveOptions{})
+			report.record(Result{Kind: KindImage, Name: image, Service: name}, ActionRemoved, wrapError("image", image, err))
+		}
+	}
+
+	if failed := report.Failed(); len(failed) > 0 {
+		var names []string
+		for _, result := range failed {
+			names = append(names, string(result.Kind)+" "+result.Name)
+		}
+		return report, fmt.Errorf("failed to remove %s: %w", strings.Join(names, ", "), failed[0].Err)
+	}
+	return report, nil
+}
+
+// removeContainer stops the container if it is running and removes it. The daemon sends
+// the stop_signal the container was created with
+func (s Stack) removeContainer(ctx context.Context, client Client, c types.Container, options DownOptions) error {
+	if c.State == "running" || c.State == "paused" || c.State == "restarting" {
+		if err := client.ContainerStop(ctx, c.ID, s.stopTimeout(c.Labels[ServiceLabel], options.Timeout)); err != nil {
+			return wrapError("container", containerName(c), err)
+		}
+	}
+	err := client.ContainerRemove(ctx, c.ID, types.ContainerRemoveOptions{RemoveVolumes: options.RemoveVolumes})
+	return wrapError("container", containerName(c), err)
+}
+
+// stopTimeout returns the override if set, otherwise the stop_grace_period of the service.
+// Nil uses the timeout the container was created with
+func (s Stack) stopTimeout(service string, override *time.Duration) *time.Duration {
+	if override != nil {
+		return override
+	}
+	if seconds := s.services[service].GetContainerConfig().StopTimeout; seconds != nil {
+		timeout := time.Duration(*seconds) * time.Second
+		return &timeout
+	}
+	return nil
+}
+
+// stopOrder sorts the containers so orphans are first followed by services in reverse
+// dependency order. Replicas of a service are sorted by their number
+func (s Stack) stopOrder(containers []types.Container) []types.Container {
+	rank := make(map[string]int)
+	levels := s.serviceLevels(s.ServiceNames())
+	for i, level := range levels {
+		for _, name := range level {
+			rank[name] = len(levels) - i
+		}
+	}
+
+	sorted := append([]types.Container{}, containers...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		first, second := sorted[i].Labels, sorted[j].Labels
+		if rank[first[ServiceLabel]] != rank[second[ServiceLabel]] {
+			return rank[first[ServiceLabel]] < rank[second[ServiceLabel]]
+		}
+		if first[ServiceLabel] != second[ServiceLabel] {
+			return first[ServiceLabel] < second[ServiceLabel]
+		}
+		firstNumber, _ := strconv.Atoi(first[ContainerNumberLabel])
+		secondNumber, _ := strconv.Atoi(second[ContainerNumberLabel])
+		return firstNumber < secondNumber
+	})
+	return sorted
+}
+
+// containerName returns the name of the container without the leading slash added by the daemon
+func containerName(c types.Container) string {
+	if len(c.Names) == 0 {
+		return c.ID
+	}
+	return strings.TrimPrefix(c.Names[0], "/")
+}
+
+func wrapError(kind, name string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("%s %s: %w", kind, name, err)
+}
@@ -0,0 +1,103 @@
+package compose_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rmasp98/go-compose/compose"
+	"github.com/rmasp98/go-compose/compose/composetest"
+)
+
+func newRunningFakeClient(t *testing.T, stack compose.Stack) *composetest.FakeClient {
+	client := newFakeClient()
+	client.AddNetwork("shared", map[string]string{compose.ProjectLabel: "app", compose.NetworkLabel: "shared"})
+	if _, err := stack.Up(context.Background(), client, compose.UpOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	client.AddContainer("app-old-1", "busybox", "running", map[string]string{
+		compose.ProjectLabel: "app", compose.ServiceLabel: "old", compose.ContainerNumberLabel: "1",
+	})
+	client.ClearCalls()
+	return client
+}
+
+func TestDownStopsInReverseDependencyOrder(t *testing.T) {
+	stack := newUpStack(t)
+	client := newRunningFakeClient(t, stack)
+
+	report, err := stack.Down(context.Background(), client, compose.DownOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"ContainerStop app-web-1 30s",
+		"ContainerRemove app-web-1",
+		"ContainerStop app-api-1",
+		"ContainerRemove app-api-1",
+		"ContainerStop app-db-1",
+		"ContainerRemove app-db-1",
+		"NetworkRemove app_backend",
+		"NetworkRemove app_frontend",
+	}
+	if err := verifyValue(expected, client.Calls()); err != nil {
+		t.Error(err)
+	}
+	if result := report.Results[0]; result.Name != "app-old-1" || result.Action != compose.ActionSkipped {
+		t.Errorf("Orphan should have been skipped but got %v", result)
+	}
+	if _, exists := client.Volume("app_data"); !exists {
+		t.Error("Volumes should not be removed by default")
+	}
+}
+
+func TestDownCanRemoveOrphansVolumesAndImages(t *testing.T) {
+	stack := newUpStack(t)
+	client := newRunningFakeClient(t, stack)
+	timeout := 5 * time.Second
+
+	options := compose.DownOptions{RemoveOrphans: true, RemoveVolumes: true, RemoveImages: true, Timeout: &timeout}
+	if _, err := stack.Down(context.Background(), client, options); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"ContainerStop app-old-1 5s",
+		"ContainerRemove app-old-1",
+		"ContainerStop app-web-1 5s",
+		"ContainerRemove app-web-1",
+		"ContainerStop app-api-1 5s",
+		"ContainerRemove app-api-1",
+		"ContainerStop app-db-1 5s",
+		"ContainerRemove app-db-1",
+		"NetworkRemove app_backend",
+		"NetworkRemove app_frontend",
+		"VolumeRemove app_data",
+		"ImageRemove example/api:1.0",
+		"ImageRemove postgres:latest",
+		"ImageRemove nginx:latest",
+	}
+	if err := verifyValue(expected, client.Calls()); err != nil {
+		t.Error(err)
+	}
+	if _, exists := client.Network("shared"); !exists {
+		t.Error("External network should never be removed")
+	}
+}
+
+func TestDownRemovesStoppedContainersWithoutStopping(t *testing.T) {
+	stack := newUpStack(t)
+	client := newRunningFakeClient(t, stack)
+	client.SetContainerState("app-db-1", "exited")
+
+	if _, err := stack.Down(context.Background(), client, compose.DownOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, call := range client.Calls() {
+		if call == "ContainerStop app-db-1" {
+			t.Errorf("Stopped container should be removed without stopping but got %v", client.Calls())
+		}
+	}
+	if _, exists := client.Container("app-db-1"); exists {
+		t.Error("Container should have been removed")
+	}
+}
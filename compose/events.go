@@ -0,0 +1,239 @@
+package compose
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+)
+
+// Event is a change to a container, network or volume of the project
+type Event struct {
+	Time time.Time    `json:"time"`
+	Kind ResourceKind `json:"kind"`
+	// Action is the event from the daemon, e.g. create, start, die or health_status
+	Action string `json:"action"`
+	// Detail is the extra information some actions include, e.g. healthy for health_status
+	Detail string `json:"detail,omitempty"`
+	// Name is the name of the resource on the daemon
+	Name string `json:"name"`
+	ID   string `json:"id"`
+	// Service and Replica are only set for container events
+	Service    string            `json:"service,omitempty"`
+	Replica    int               `json:"replica,omitempty"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// EventOptions controls which events are passed to the handler
+type EventOptions struct {
+	// Services limits container events to the services. All services are included when empty
+	Services []string
+	// Since and Until are timestamps (RFC3339 or unix) or relative times (e.g. 10m) passed to the daemon
+	Since string
+	Until string
+	// ReconnectDelay is how long to wait before reconnecting when the stream fails. Defaults to 1s
+	ReconnectDelay time.Duration
+	// OnDisconnect is called with the error each time a stream fails before reconnecting. Containers
+	// are streamed separately from networks and volumes so it may be called once for each
+	OnDisconnect func(error)
+}
+
+// Events calls the handler for every event of the project until the context is cancelled, the
+// handler returns an error or Until is reached. If the stream from the daemon fails, Events
+// reconnects asking for the events since the last one received so none are missed. Until an event
+// is received, it reconnects from Since
+func (s Stack) Events(ctx context.Context, client Client, handler func(Event) error, options EventOptions) error {
+	for _, service := range options.Services {
+		if _, err := s.getService(service); err != nil {
+			return err
+		}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// The daemon only matches labels against container events, so networks and volumes are
+	// streamed separately and matched by name. Events are in order within each stream
+	streams := []filters.Args{eventFilter(s.projectFilter(), events.ContainerEventType)}
+	if len(options.Services) == 0 {
+		streams = append(streams, eventFilter(filters.NewArgs(), events.NetworkEventType, events.VolumeEventType))
+	}
+
+	// The handler is called by one stream at a time and never again once it returns an error
+	var lock sync.Mutex
+	var handlerErr error
+	handle := func(event Event) error {
+		lock.Lock()
+		defer lock.Unlock()
+		if handlerErr == nil {
+			handlerErr = handler(event)
+		}
+		return handlerErr
+	}
+
+	results := make(chan error, len(streams))
+	for _, filter := range streams {
+		go func(filter filters.Args) {
+			results <- s.streamEvents(ctx, client, filter, handle, options)
+		}(filter)
+	}
+	var err error
+	for range streams {
+		if result := <-results; result != nil && err == nil {
+			err = result
+			cancel()
+		}
+	}
+	return err
+}
+
+// streamEvents passes the events matching the filter to the handler, reconnecting from the time
+// of the last event received whenever the stream fails
+func (s Stack) streamEvents(ctx context.Context, client Client, filter filters.Args, handler func(Event) error, options EventOptions) error {
+	reconnectDelay := options.ReconnectDelay
+	if reconnectDelay <= 0 {
+		reconnectDelay = time.Second
+	}
+
+	since := options.Since
+	var last events.Message
+	for {
+		streamCtx, cancel := context.WithCancel(ctx)
+		messages, errs := client.Events(streamCtx, types.EventsOptions{
+			Since:   since,
+			Until:   options.Until,
+			Filters: filter,
+		})
+		err := s.handleEvents(ctx, messages, errs, handler, options.Services, &last)
+		cancel()
+
+		var disconnect disconnectError
+		if err == nil || ctx.Err() != nil {
+			return nil
+		} else if !errors.As(err, &disconnect) {
+			return err
+		}
+		if options.OnDisconnect != nil {
+			options.OnDisconnect(disconnect.err)
+		}
+		// The daemon's timestamp is used so a clock difference with the client cannot lose events
+		if last.TimeNano != 0 {
+			since = formatEventTime(last.TimeNano)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(reconnectDelay):
+		}
+	}
+}
+
+// disconnectError wraps an error from the event stream so it can be told apart from handler errors
+type disconnectError struct {
+	err error
+}
+
+func (d disconnectError) Error() string {
+	return d.err.Error()
+}
+
+// handleEvents passes the events of the project to the handler until the stream ends. Last is the
+// last event handled and is used to skip events that are sent again after reconnecting
+func (s Stack) handleEvents(ctx context.Context, messages <-chan events.Message, errs <-chan error, handler func(Event) error, services []string, last *events.Message) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-errs:
+			// The stream ends with EOF once Until is reached
+			if err == nil || err == io.EOF || err == ctx.Err() {
+				return nil
+			}
+			return disconnectError{err}
+		case message, open := <-messages:
+			if !open {
+				return nil
+			}
+			if message.TimeNano < last.TimeNano || (message.TimeNano == last.TimeNano && message.Actor.ID == last.Actor.ID && message.Action == last.Action) {
+				continue
+			}
+			*last = message
+			if event, matches := s.toEvent(message, services); matches {
+				if err := handler(event); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// toEvent converts the message from the daemon and returns whether it belongs to the project
+func (s Stack) toEvent(message events.Message, services []string) (Event, bool) {
+	attributes := message.Actor.Attributes
+	event := Event{
+		Time:       time.Unix(0, message.TimeNano),
+		Kind:       ResourceKind(message.Type),
+		ID:         message.Actor.ID,
+		Name:       attributes["name"],
+		Attributes: attributes,
+	}
+	parts := strings.SplitN(message.Action, ":", 2)
+	event.Action = parts[0]
+	if len(parts) == 2 {
+		event.Detail = strings.TrimSpace(parts[1])
+	}
+
+	switch message.Type {
+	case events.ContainerEventType:
+		if attributes[ProjectLabel] != s.projectName {
+			return Event{}, false
+		}
+		event.Service = attributes[ServiceLabel]
+		event.Replica, _ = strconv.Atoi(attributes[ContainerNumberLabel])
+		if len(services) == 0 {
+			return event, true
+		}
+		for _, service := range services {
+			if service == event.Service {
+				return event, true
+			}
+		}
+		return Event{}, false
+	case events.NetworkEventType:
+		// Network events do not include labels so are matched by name
+		for _, name := range s.NetworkNames() {
+			if s.GetNetworkName(name) == event.Name {
+				return event, len(services) == 0
+			}
+		}
+	case events.VolumeEventType:
+		event.Name = message.Actor.ID
+		for _, name := range s.VolumeNames() {
+			if s.GetVolumeName(name) == event.Name {
+				return event, len(services) == 0
+			}
+		}
+	}
+	return Event{}, false
+}
+
+// eventFilter adds the event types to the filter
+func eventFilter(filter filters.Args, eventTypes ...string) filters.Args {
+	for _, eventType := range eventTypes {
+		filter.Add("type", eventType)
+	}
+	return filter
+}
+
+// formatEventTime formats the time in the seconds.nanoseconds format accepted by since
+func formatEventTime(timeNano int64) string {
+	return fmt.Sprintf("%d.%09d", timeNano/int64(time.Second), timeNano%int64(time.Second))
+}
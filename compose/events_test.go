@@ -0,0 +1,254 @@
+package compose_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/rmasp98/go-compose/compose"
+	"github.com/rmasp98/go-compose/compose/composetest"
+)
+
+// collectEvents returns a summary of every event of the stack between start and now
+func collectEvents(t *testing.T, stack compose.Stack, client compose.Client, start time.Time, options compose.EventOptions) []string {
+	var summaries []string
+	options.Since, options.Until = start.Format(time.RFC3339Nano), time.Now().Format(time.RFC3339Nano)
+	err := stack.Events(context.Background(), client, func(event compose.Event) error {
+		summaries = append(summaries, fmt.Sprintf("%s %s %s %s-%d", event.Kind, event.Action, event.Name, event.Service, event.Replica))
+		return nil
+	}, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return summaries
+}
+
+func TestEventsOnlyIncludeProjectResources(t *testing.T) {
+	client := newFakeClient()
+	start := time.Now()
+	stack := newUpStack(t)
+	if _, err := stack.Up(context.Background(), client, compose.UpOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	other, _ := stack.Builder().Build(compose.WithProjectName("other"))
+	if _, err := other.Up(context.Background(), client, compose.UpOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Containers are streamed separately from networks and volumes so are only ordered by kind
+	var containers, resources []string
+	for _, summary := range collectEvents(t, stack, client, start, compose.EventOptions{}) {
+		if strings.HasPrefix(summary, "container ") {
+			containers = append(containers, summary)
+		} else {
+			resources = append(resources, summary)
+		}
+	}
+	expectedContainers := []string{
+		"container create app-db-1 db-1",
+		"container start app-db-1 db-1",
+		"container create app-api-1 api-1",
+		"container start app-api-1 api-1",
+		"container create app-web-1 web-1",
+		"container start app-web-1 web-1",
+	}
+	if err := verifyValue(expectedContainers, containers); err != nil {
+		t.Error(err)
+	}
+	expectedResources := []string{
+		"network create app_backend -0",
+		"network create app_frontend -0",
+		"volume create app_data -0",
+		"network connect app_frontend -0",
+	}
+	if err := verifyValue(expectedResources, resources); err != nil {
+		t.Error(err)
+	}
+}
+
+// eventsClient records the options of every event stream requested
+type eventsClient struct {
+	*composetest.FakeClient
+	mu      sync.Mutex
+	options []types.EventsOptions
+}
+
+func (c *eventsClient) Events(ctx context.Context, options types.EventsOptions) (<-chan events.Message, <-chan error) {
+	c.mu.Lock()
+	c.options = append(c.options, options)
+	c.mu.Unlock()
+	return c.FakeClient.Events(ctx, options)
+}
+
+func TestEventsAskTheDaemonForProjectContainersOnly(t *testing.T) {
+	client := &eventsClient{FakeClient: newFakeClient()}
+	stack := newUpStack(t)
+	options := compose.EventOptions{Since: "0", Until: time.Now().Format(time.RFC3339Nano)}
+	if err := stack.Events(context.Background(), client, func(compose.Event) error { return nil }, options); err != nil {
+		t.Fatal(err)
+	}
+
+	var containerFilter, resourceFilter []string
+	for _, requested := range client.options {
+		if requested.Filters.ExactMatch("type", "container") {
+			containerFilter = requested.Filters.Get("label")
+		} else {
+			resourceFilter = requested.Filters.Get("type")
+			sort.Strings(resourceFilter)
+		}
+	}
+	if err := verifyValue([]string{compose.ProjectLabel + "=app"}, containerFilter); err != nil {
+		t.Error(err)
+	}
+	if err := verifyValue([]string{"network", "volume"}, resourceFilter); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestEventsCanBeLimitedToServices(t *testing.T) {
+	client := newFakeClient()
+	start := time.Now()
+	stack := newUpStack(t)
+	if _, err := stack.Up(context.Background(), client, compose.UpOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	client.SetExitCode("app-db-1", 3)
+
+	expected := []string{
+		"container create app-db-1 db-1",
+		"container start app-db-1 db-1",
+		"container die app-db-1 db-1",
+	}
+	if err := verifyValue(expected, collectEvents(t, stack, client, start, compose.EventOptions{Services: []string{"db"}})); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestEventsRejectUnknownService(t *testing.T) {
+	err := newUpStack(t).Events(context.Background(), newFakeClient(), func(compose.Event) error { return nil },
+		compose.EventOptions{Services: []string{"cache"}})
+	if err == nil {
+		t.Error("Expected error for unknown service")
+	}
+}
+
+func TestEventsSplitActionDetail(t *testing.T) {
+	client := newFakeClient()
+	stack := newUpStack(t)
+	if _, err := stack.Up(context.Background(), client, compose.UpOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	client.ScriptHealth("app-api-1", "unhealthy")
+	client.ContainerInspect(context.Background(), "app-api-1")
+
+	var health compose.Event
+	options := compose.EventOptions{Since: start.Format(time.RFC3339Nano), Until: time.Now().Format(time.RFC3339Nano)}
+	if err := stack.Events(context.Background(), client, func(event compose.Event) error {
+		health = event
+		return nil
+	}, options); err != nil {
+		t.Fatal(err)
+	}
+	if health.Action != "health_status" || health.Detail != "unhealthy" || health.Service != "api" {
+		t.Fatalf("Unexpected event: %+v", health)
+	}
+
+	output, err := json.Marshal(health)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]interface{}
+	json.Unmarshal(output, &decoded)
+	for key, expected := range map[string]interface{}{"kind": "container", "action": "health_status", "detail": "unhealthy", "name": "app-api-1", "service": "api", "replica": 1.0} {
+		if decoded[key] != expected {
+			t.Errorf("%s should be %v but was %v", key, expected, decoded[key])
+		}
+	}
+}
+
+func TestEventsReturnHandlerError(t *testing.T) {
+	client := newFakeClient()
+	start := time.Now()
+	stack := newUpStack(t)
+	if _, err := stack.Up(context.Background(), client, compose.UpOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	stop := errors.New("stop")
+	count := 0
+	err := stack.Events(context.Background(), client, func(compose.Event) error {
+		count++
+		return stop
+	}, compose.EventOptions{Since: start.Format(time.RFC3339Nano)})
+	if err != stop || count != 1 {
+		t.Errorf("Expected handler error after one event but got %v after %d", err, count)
+	}
+}
+
+func TestEventsReconnectWithoutMissingOrRepeatingEvents(t *testing.T) {
+	client := newFakeClient()
+	stack := newUpStack(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	received := make(chan compose.Event, 100)
+	disconnects := make(chan error, 10)
+	done := make(chan error)
+	start := time.Now()
+	go func() {
+		done <- stack.Events(ctx, client, func(event compose.Event) error {
+			received <- event
+			return nil
+		}, compose.EventOptions{Since: start.Format(time.RFC3339Nano), Services: []string{"db"}, ReconnectDelay: time.Millisecond, OnDisconnect: func(err error) {
+			disconnects <- err
+		}})
+	}()
+
+	waitForEvent := func(action string) {
+		for {
+			select {
+			case event := <-received:
+				if event.Action == action {
+					return
+				}
+				if event.Action != "create" {
+					t.Fatalf("Unexpected event %s %s", event.Action, event.Name)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("Timed out waiting for %s", action)
+			}
+		}
+	}
+
+	if _, err := stack.Up(context.Background(), client, compose.UpOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	waitForEvent("start")
+
+	client.DisconnectEvents(errors.New("connection reset"))
+	client.SetContainerState("app-db-1", "exited")
+	waitForEvent("die")
+	client.SetContainerState("app-db-1", "running")
+	waitForEvent("start")
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+	if len(received) != 0 {
+		t.Errorf("Events should not be repeated but got %v", <-received)
+	}
+	if len(disconnects) != 1 {
+		t.Errorf("Expected one disconnect but got %d", len(disconnects))
+	} else if err := <-disconnects; err.Error() != "connection reset" {
+		t.Errorf("Unexpected disconnect error: %v", err)
+	}
+}
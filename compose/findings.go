@@ -0,0 +1,281 @@
+package compose
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Findings are the issues found by Validate
+type Findings struct {
+	Issues []ValidationIssue
+}
+
+// HasErrors returns true if any issue would stop the Stack from loading
+func (f Findings) HasErrors() bool {
+	for _, issue := range f.Issues {
+		if issue.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate loads the compose files like Load but reports every problem as an issue located in the
+// files rather than stopping at the first error. Errors that prevent the files being read at all,
+// such as a missing file, are returned as an error
+func Validate(options LoadOptions, stackOptions ...StackOption) (Findings, error) {
+	loaded, err := loadFiles(options)
+	if err != nil {
+		return Findings{}, err
+	}
+	findings := Findings{Issues: loaded.issues}
+	if findings.HasErrors() {
+		return findings, nil
+	}
+
+	if projectName := finishConfig(loaded.config, options, loaded.directory); projectName != "" {
+		stackOptions = append([]StackOption{WithProjectName(projectName)}, stackOptions...)
+	}
+	stack, err := NewStack(loaded.config, stackOptions...)
+	var issues []ValidationIssue
+	switch err := err.(type) {
+	case nil:
+		issues = stack.Warnings()
+	case ValidationError:
+		issues = append(err.Issues, err.Warnings...)
+	default:
+		issues = []ValidationIssue{configIssue(RuleInvalidConfig, err)}
+	}
+	for _, issue := range issues {
+		findings.Issues = append(findings.Issues, locateIssue(issue, loaded.files))
+	}
+	return findings, nil
+}
+
+// configIssue converts an error into an issue, using the path of a ConfigError
+func configIssue(rule string, err error) ValidationIssue {
+	issue := ValidationIssue{Severity: SeverityError, Rule: rule, Message: err.Error()}
+	if configErr, isConfigErr := err.(ConfigError); isConfigErr {
+		issue.Path, issue.Message = configErr.Path, configErr.Err.Error()
+	}
+	return issue
+}
+
+// yamlLineRegex finds the line number in the errors returned by the YAML parser
+var yamlLineRegex = regexp.MustCompile(`line (\d+)`)
+
+// fileIssue converts an error from reading a compose file into an issue. It returns false if the
+// file could not be read
+func fileIssue(path string, err error) (ValidationIssue, bool) {
+	switch err := err.(type) {
+	case yamlError:
+		issue := ValidationIssue{Severity: SeverityError, Rule: RuleYAMLSyntax, Message: strings.TrimPrefix(err.Error(), "yaml: "), File: path}
+		if match := yamlLineRegex.FindStringSubmatch(err.Error()); match != nil {
+			issue.Line, _ = strconv.Atoi(match[1])
+			issue.Column = 1
+		}
+		return issue, true
+	case ConfigError:
+		return ValidationIssue{Severity: SeverityError, Rule: RuleInvalidConfig, Message: err.Err.Error(), File: path, Line: 1, Column: 1}, true
+	}
+	return ValidationIssue{}, false
+}
+
+// locateIssue sets the position of the issue to the deepest node on its path found in the files.
+// Later files are preferred as they override earlier ones
+func locateIssue(issue ValidationIssue, files []composeFile) ValidationIssue {
+	depth := -1
+	for i := len(files) - 1; i >= 0; i-- {
+		node, found := findNode(files[i].node, issue.Path)
+		if node != nil && found > depth {
+			depth, issue.File, issue.Line, issue.Column = found, files[i].path, node.Line, node.Column
+		}
+	}
+	return issue
+}
+
+// findNode follows the path from the root of the document and returns the last node found and how
+// many elements of the path it matched. Mapping keys are returned rather than their values so the
+// position is that of the option. Elements of a sequence are matched by index or by value
+func findNode(document *yaml.Node, path []string) (*yaml.Node, int) {
+	if document == nil || document.Kind != yaml.DocumentNode || len(document.Content) == 0 {
+		return nil, 0
+	}
+	node, position := document.Content[0], document.Content[0]
+	for depth, element := range path {
+		var next, key *yaml.Node
+		switch node.Kind {
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value == element {
+					key, next = node.Content[i], node.Content[i+1]
+				}
+			}
+		case yaml.SequenceNode:
+			if index, err := strconv.Atoi(element); err == nil && index >= 0 && index < len(node.Content) {
+				next = node.Content[index]
+			}
+			for _, item := range node.Content {
+				if next == nil && item.Kind == yaml.ScalarNode && item.Value == element {
+					next = item
+				}
+			}
+		}
+		if next == nil {
+			return position, depth
+		}
+		node, position = next, next
+		if key != nil {
+			position = key
+		}
+	}
+	return position, len(path)
+}
+
+// String lists the issues as file:line:column: severity: message [rule]
+func (f Findings) String() string {
+	var lines []string
+	for _, issue := range f.Issues {
+		location := issue.File
+		if issue.Line > 0 {
+			location = fmt.Sprintf("%s:%d:%d", issue.File, issue.Line, issue.Column)
+		}
+		message := issue.Message
+		if len(issue.Path) > 0 {
+			message = issue.String()
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s: %s [%s]", location, issue.Severity, message, issue.Rule))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// MarshalJSON outputs the issues with their position. The path is joined with dots
+func (f Findings) MarshalJSON() ([]byte, error) {
+	type jsonIssue struct {
+		File     string   `json:"file"`
+		Line     int      `json:"line,omitempty"`
+		Column   int      `json:"column,omitempty"`
+		Rule     string   `json:"rule"`
+		Severity Severity `json:"severity"`
+		Path     string   `json:"path,omitempty"`
+		Message  string   `json:"message"`
+	}
+	issues := []jsonIssue{}
+	for _, issue := range f.Issues {
+		issues = append(issues, jsonIssue{issue.File, issue.Line, issue.Column, issue.Rule, issue.Severity, strings.Join(issue.Path, "."), issue.Message})
+	}
+	return json.Marshal(struct {
+		Issues []jsonIssue `json:"issues"`
+	}{issues})
+}
+
+// ruleDescriptions describe each rule in the SARIF output
+var ruleDescriptions = map[string]string{
+	RuleUndefinedService: "A service references a service that is not defined",
+	RuleUndefinedNetwork: "A service uses a network that is not defined",
+	RuleUndefinedVolume:  "A service mounts a named volume that is not defined",
+	RuleUndefinedSecret:  "A service uses a secret that is not defined",
+	RuleUnusedNetwork:    "A network is not used by any service",
+	RuleUnusedVolume:     "A volume is not used by any service",
+	RuleUnusedSecret:     "A secret is not used by any service",
+	RuleDependencyCycle:  "Services depend on each other in a cycle",
+	RuleStaticAddress:    "A static address cannot be assigned by the network",
+	RuleExclusiveNetwork: "A host or none network is combined with other networks",
+	RuleSelfReference:    "A service references itself",
+	RuleScaleConflict:    "A service with more than one replica sets an option that must be unique",
+	RuleUnknownOption:    "A service sets an option that is not in the compose specification",
+	RuleYAMLSyntax:       "The compose file is not valid YAML",
+	RuleInterpolation:    "A variable cannot be substituted",
+	RuleInvalidConfig:    "A value in the compose file is invalid",
+}
+
+// RelativeTo returns the findings with the file paths made relative to the directory when they are inside it
+func (f Findings) RelativeTo(directory string) Findings {
+	relative := Findings{Issues: make([]ValidationIssue, len(f.Issues))}
+	for i, issue := range f.Issues {
+		if path, err := filepath.Rel(directory, issue.File); issue.File != "" && err == nil && !strings.HasPrefix(path, "..") {
+			issue.File = path
+		}
+		relative.Issues[i] = issue
+	}
+	return relative
+}
+
+// SARIF outputs the issues as a SARIF 2.1.0 log. Use RelativeTo first so the file paths match the
+// paths in the repository
+func (f Findings) SARIF() ([]byte, error) {
+	type message struct {
+		Text string `json:"text"`
+	}
+	type region struct {
+		StartLine   int `json:"startLine"`
+		StartColumn int `json:"startColumn,omitempty"`
+	}
+	type physicalLocation struct {
+		ArtifactLocation struct {
+			URI string `json:"uri"`
+		} `json:"artifactLocation"`
+		Region *region `json:"region,omitempty"`
+	}
+	type location struct {
+		PhysicalLocation physicalLocation `json:"physicalLocation"`
+	}
+	type result struct {
+		RuleID    string     `json:"ruleId"`
+		Level     string     `json:"level"`
+		Message   message    `json:"message"`
+		Locations []location `json:"locations,omitempty"`
+	}
+	type rule struct {
+		ID               string  `json:"id"`
+		ShortDescription message `json:"shortDescription"`
+	}
+
+	rules := []rule{}
+	results := []result{}
+	usedRules := make(map[string]bool)
+	for _, issue := range f.Issues {
+		usedRules[issue.Rule] = true
+		text := issue.Message
+		if len(issue.Path) > 0 {
+			text = issue.String()
+		}
+		r := result{RuleID: issue.Rule, Level: string(issue.Severity), Message: message{text}}
+		if issue.File != "" {
+			var physical physicalLocation
+			physical.ArtifactLocation.URI = filepath.ToSlash(issue.File)
+			if issue.Line > 0 {
+				physical.Region = &region{issue.Line, issue.Column}
+			}
+			r.Locations = []location{{physical}}
+		}
+		results = append(results, r)
+	}
+	for _, id := range sortedKeys(usedRules) {
+		rules = append(rules, rule{id, message{ruleDescriptions[id]}})
+	}
+
+	type driver struct {
+		Name  string `json:"name"`
+		Rules []rule `json:"rules"`
+	}
+	type run struct {
+		Tool struct {
+			Driver driver `json:"driver"`
+		} `json:"tool"`
+		Results []result `json:"results"`
+	}
+	sarifRun := run{Results: results}
+	sarifRun.Tool.Driver = driver{"go-compose", rules}
+	return json.MarshalIndent(struct {
+		Schema  string `json:"$schema"`
+		Version string `json:"version"`
+		Runs    []run  `json:"runs"`
+	}{"https://json.schemastore.org/sarif-2.1.0.json", "2.1.0", []run{sarifRun}}, "", "  ")
+}
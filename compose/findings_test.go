@@ -0,0 +1,165 @@
+package compose_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/rmasp98/go-compose/compose"
+)
+
+const findingsBase = `services:
+  web:
+    image: nginx
+    depends_on:
+      - api
+    networks: [frontend]
+networks:
+  frontend:
+  unused:
+`
+
+const findingsOverride = `services:
+  web:
+    networks:
+      - frontend
+      - missing
+`
+
+// issueSummary describes where an issue was found, e.g. "override.yaml:5:9 error undefined-network"
+func issueSummary(directory string, issue compose.ValidationIssue) string {
+	file, _ := filepath.Rel(directory, issue.File)
+	return fmt.Sprintf("%s:%d:%d %s %s", file, issue.Line, issue.Column, issue.Severity, issue.Rule)
+}
+
+func TestValidateLocatesIssuesInFiles(t *testing.T) {
+	directory := writeProject(t, map[string]string{"compose.yaml": findingsBase, "override.yaml": findingsOverride})
+	findings, err := compose.Validate(compose.LoadOptions{
+		Files:       []string{filepath.Join(directory, "compose.yaml"), filepath.Join(directory, "override.yaml")},
+		Environment: map[string]string{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var summaries []string
+	for _, issue := range findings.Issues {
+		summaries = append(summaries, issueSummary(directory, issue))
+	}
+	expected := []string{
+		"compose.yaml:4:5 error undefined-service",
+		"override.yaml:5:9 error undefined-network",
+		"compose.yaml:9:3 warning unused-network",
+	}
+	if err := verifyValue(expected, summaries); err != nil {
+		t.Error(err)
+	}
+	if !findings.HasErrors() {
+		t.Error("Findings should have errors")
+	}
+}
+
+func TestValidateReportsParseErrorsAsIssues(t *testing.T) {
+	directory := writeProject(t, map[string]string{
+		"syntax.yaml":        "services:\n  web:\n\timage: nginx\n",
+		"interpolation.yaml": "services:\n  web:\n    image: nginx\n    user: ${USER?must be set}\n",
+		"invalid.yaml":       "services:\n  web:\n    image: nginx\n    healthcheck:\n      interval: often\n",
+		"list.yaml":          "- web\n",
+	})
+
+	testData := map[string]string{
+		"syntax":        "syntax.yaml:3:1 error yaml-syntax",
+		"interpolation": "interpolation.yaml:4:5 error interpolation",
+		"invalid":       "invalid.yaml:5:7 error invalid-config",
+		"list":          "list.yaml:1:1 error invalid-config",
+	}
+
+	for name, expected := range testData {
+		t.Run(name, func(t *testing.T) {
+			findings, err := compose.Validate(compose.LoadOptions{Files: []string{filepath.Join(directory, name+".yaml")}, Environment: map[string]string{}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(findings.Issues) != 1 || issueSummary(directory, findings.Issues[0]) != expected {
+				t.Errorf("Expected %s but got %v", expected, findings.Issues)
+			}
+		})
+	}
+
+	if _, err := compose.Validate(compose.LoadOptions{Files: []string{filepath.Join(directory, "missing.yaml")}}); err == nil {
+		t.Error("Expected error for missing file")
+	}
+}
+
+func TestFindingsOutputFormats(t *testing.T) {
+	directory := writeProject(t, map[string]string{"compose.yaml": findingsBase})
+	findings, err := compose.Validate(compose.LoadOptions{ProjectDirectory: directory, Environment: map[string]string{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	findings = findings.RelativeTo(directory)
+
+	expected := "compose.yaml:4:5: error: services.web.depends_on: depends on undefined service api [undefined-service]\n" +
+		"compose.yaml:9:3: warning: networks.unused: network unused is not used by any service [unused-network]"
+	if findings.String() != expected {
+		t.Errorf("Unexpected text output:\n%s", findings.String())
+	}
+
+	output, err := json.Marshal(findings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded struct {
+		Issues []map[string]interface{} `json:"issues"`
+	}
+	if err := json.Unmarshal(output, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.Issues) != 2 || decoded.Issues[0]["file"] != "compose.yaml" || decoded.Issues[0]["line"] != 4.0 || decoded.Issues[1]["severity"] != "warning" {
+		t.Errorf("Unexpected JSON: %s", output)
+	}
+
+	output, err = findings.SARIF()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sarif struct {
+		Version string `json:"version"`
+		Runs    []struct {
+			Tool struct {
+				Driver struct {
+					Rules []struct {
+						ID string `json:"id"`
+					} `json:"rules"`
+				} `json:"driver"`
+			} `json:"tool"`
+			Results []struct {
+				RuleID    string `json:"ruleId"`
+				Level     string `json:"level"`
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct {
+							URI string `json:"uri"`
+						} `json:"artifactLocation"`
+						Region struct {
+							StartLine   int `json:"startLine"`
+							StartColumn int `json:"startColumn"`
+						} `json:"region"`
+					} `json:"physicalLocation"`
+				} `json:"locations"`
+			} `json:"results"`
+		} `json:"runs"`
+	}
+	if err := json.Unmarshal(output, &sarif); err != nil {
+		t.Fatal(err)
+	}
+	if sarif.Version != "2.1.0" || len(sarif.Runs) != 1 || len(sarif.Runs[0].Results) != 2 || len(sarif.Runs[0].Tool.Driver.Rules) != 2 {
+		t.Fatalf("Unexpected SARIF: %s", output)
+	}
+	result := sarif.Runs[0].Results[1]
+	location := result.Locations[0].PhysicalLocation
+	if result.RuleID != "unused-network" || result.Level != "warning" || location.ArtifactLocation.URI != "compose.yaml" || location.Region.StartLine != 9 || location.Region.StartColumn != 3 {
+		t.Errorf("Unexpected SARIF result: %+v", result)
+	}
+}
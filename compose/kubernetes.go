@@ -0,0 +1,892 @@
+package compose
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/go-connections/nat"
+	units "github.com/docker/go-units"
+	"gopkg.in/yaml.v3"
+)
+
+// RuleKubernetesUnsupported is the rule of the issues raised for options that cannot be converted to Kubernetes
+const RuleKubernetesUnsupported = "kubernetes-unsupported"
+
+// KubernetesOptions controls the manifests created by ToKubernetes
+type KubernetesOptions struct {
+	// Namespace is set on every object when not empty
+	Namespace string
+	// StorageClass of the PersistentVolumeClaims. The default storage class of the cluster is used when empty
+	StorageClass string
+	// VolumeSize is the storage requested by each PersistentVolumeClaim. Defaults to 1Gi
+	VolumeSize string
+}
+
+// KubernetesObject is a single Kubernetes manifest. Maps are sorted by key when marshalled so the output is stable
+type KubernetesObject map[string]interface{}
+
+// Kind returns the kind of the object, e.g. Deployment
+func (o KubernetesObject) Kind() string {
+	kind, _ := o["kind"].(string)
+	return kind
+}
+
+// Name returns the name of the object from its metadata
+func (o KubernetesObject) Name() string {
+	metadata, _ := o["metadata"].(map[string]interface{})
+	name, _ := metadata["name"].(string)
+	return name
+}
+
+// KubernetesManifests are the objects converted from a Stack
+type KubernetesManifests struct {
+	Objects []KubernetesObject
+	// Unsupported has a warning for every option that was ignored or only partly converted
+	Unsupported []ValidationIssue
+}
+
+// YAML outputs the objects as a multi-document YAML stream that can be passed to kubectl apply
+func (m KubernetesManifests) YAML() ([]byte, error) {
+	var output bytes.Buffer
+	encoder := yaml.NewEncoder(&output)
+	encoder.SetIndent(2)
+	for _, object := range m.Objects {
+		if err := encoder.Encode(map[string]interface{}(object)); err != nil {
+			return nil, err
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return output.Bytes(), nil
+}
+
+// Report lists the options that could not be converted, one per line
+func (m KubernetesManifests) Report() string {
+	var lines []string
+	for _, issue := range m.Unsupported {
+		lines = append(lines, issue.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// unsupportedServiceOptions are the service options that have no equivalent in a pod and why
+var unsupportedServiceOptions = map[string]string{
+	"build":           "images must be built and pushed to a registry before deploying",
+	"cgroup_parent":   "cgroups are managed by the kubelet",
+	"container_name":  "pods are named by their controller",
+	"credential_spec": "credential specs are not supported",
+	"depends_on":      "pods are started in any order, use readiness probes or init containers instead",
+	"devices":         "devices require a device plugin",
+	"dns":             "DNS is configured by the cluster",
+	"dns_search":      "DNS is configured by the cluster",
+	"domainname":      "the domain is set by the cluster",
+	"env_file":        "env files are read when loading the compose files",
+	"external_links":  "containers outside the project cannot be linked",
+	"init":            "pods do not run an init process",
+	"ipc":             "the IPC namespace cannot be shared between pods",
+	"isolation":       "isolation is Windows specific",
+	"links":           "services are reached by the name of their Service",
+	"logging":         "logging is configured by the cluster",
+	"mac_address":     "MAC addresses are assigned by the network plugin",
+	"pid":             "the PID namespace cannot be shared between pods",
+	"pids_limit":      "the PID limit of pods is set by the kubelet",
+	"security_opt":    "security options must be set as a securityContext",
+	"shm_size":        "shared memory must be mounted as a memory emptyDir",
+	"stop_signal":     "pods are always stopped with SIGTERM",
+	"sysctls":         "sysctls must be allowed by the kubelet and set in the securityContext",
+	"ulimits":         "ulimits are set by the container runtime",
+	"userns_mode":     "user namespaces are not supported",
+}
+
+// convertedServiceOptions are the service options converted by convertService. Any other option
+// that is not in unsupportedServiceOptions is reported as not converted
+var convertedServiceOptions = map[string]bool{
+	"cap_add": true, "cap_drop": true, "command": true, "configs": true, "cpu_shares": true, "cpus": true,
+	"deploy": true, "entrypoint": true, "environment": true, "expose": true, "extends": true, "extra_hosts": true,
+	"healthcheck": true, "hostname": true, "image": true, "labels": true, "mem_limit": true,
+	"mem_reservation": true, "network_mode": true, "networks": true, "platform": true, "ports": true,
+	"privileged": true, "profiles": true, "pull_policy": true, "read_only": true, "restart": true, "scale": true,
+	"secrets": true, "stdin_open": true, "stop_grace_period": true, "tmpfs": true, "tty": true, "user": true,
+	"volumes": true, "working_dir": true,
+}
+
+var invalidKubernetesCharacters = regexp.MustCompile("[^a-z0-9.-]+")
+
+// kubernetesName converts a compose name into a valid Kubernetes object name
+func kubernetesName(name string) string {
+	return strings.Trim(invalidKubernetesCharacters.ReplaceAllString(strings.ToLower(name), "-"), "-.")
+}
+
+// kubernetesConverter accumulates the objects and issues while converting a Stack
+type kubernetesConverter struct {
+	stack     Stack
+	options   KubernetesOptions
+	manifests KubernetesManifests
+	// sharedVolumes has every mounted named volume. It is true for volumes mounted by more than one
+	// service, which need a ReadWriteMany claim
+	sharedVolumes map[string]bool
+}
+
+// ToKubernetes converts the Stack into Kubernetes manifests. Each service becomes a Deployment with a
+// Service for its ports, or a StatefulSet with a headless Service if it mounts a named volume that
+// no other service mounts. Those volumes become claim templates of the StatefulSet, other named
+// volumes become PersistentVolumeClaims. Secrets and configs become Secrets and ConfigMaps and the
+// networks become NetworkPolicies that only allow traffic between services that share a network.
+// Options without an equivalent are listed in Unsupported
+func (s Stack) ToKubernetes(options KubernetesOptions) (KubernetesManifests, error) {
+	if options.VolumeSize == "" {
+		options.VolumeSize = "1Gi"
+	}
+	c := &kubernetesConverter{stack: s, options: options, sharedVolumes: s.sharedVolumes()}
+
+	for _, name := range sortedKeys(s.sourceSection("configs")) {
+		if err := c.convertConfig(name); err != nil {
+			return KubernetesManifests{}, err
+		}
+	}
+	for _, name := range sortedKeys(s.secrets) {
+		if err := c.convertSecret(name, s.secrets[name]); err != nil {
+			return KubernetesManifests{}, err
+		}
+	}
+	for _, name := range sortedKeys(s.volumes) {
+		c.convertVolume(name, s.volumes[name])
+	}
+	for _, name := range sortedKeys(s.services) {
+		if err := c.convertService(name, s.services[name]); err != nil {
+			return KubernetesManifests{}, err
+		}
+	}
+	c.convertNetworks()
+	return c.manifests, nil
+}
+
+// sourceSection returns a top level section of the compose file, e.g. configs
+func (s Stack) sourceSection(section string) map[string]interface{} {
+	config, _ := s.source[section].(map[string]interface{})
+	return config
+}
+
+// sourceService returns the options of the service as they were in the compose file
+func (s Stack) sourceService(name string) map[string]interface{} {
+	config, _ := s.sourceSection("services")[name].(map[string]interface{})
+	return config
+}
+
+// sharedVolumes finds the named volumes that are mounted and whether more than one service mounts them
+func (s Stack) sharedVolumes() map[string]bool {
+	mounts := make(map[string]int)
+	for _, service := range s.services {
+		mounted := map[string]bool{}
+		for _, volume := range service.GetVolumes() {
+			if volume.Type == "volume" && volume.Source != "" && !mounted[volume.Source] {
+				mounted[volume.Source] = true
+				mounts[volume.Source]++
+			}
+		}
+	}
+	shared := make(map[string]bool)
+	for name, count := range mounts {
+		shared[name] = count > 1
+	}
+	return shared
+}
+
+func (c *kubernetesConverter) unsupported(path []string, format string, args ...interface{}) {
+	c.manifests.Unsupported = append(c.manifests.Unsupported, newWarning(RuleKubernetesUnsupported, path, format, args...))
+}
+
+func (c *kubernetesConverter) add(apiVersion, kind, name string, labels map[string]string, fields map[string]interface{}) {
+	metadata := map[string]interface{}{"name": kubernetesName(name)}
+	if c.options.Namespace != "" {
+		metadata["namespace"] = c.options.Namespace
+	}
+	metadata["labels"] = mergeLabels(map[string]string{ProjectLabel: c.stack.projectName}, labels)
+	object := KubernetesObject{"apiVersion": apiVersion, "kind": kind, "metadata": metadata}
+	for key, value := range fields {
+		object[key] = value
+	}
+	c.manifests.Objects = append(c.manifests.Objects, object)
+}
+
+// serviceLabels select the pods of the service
+func (c *kubernetesConverter) serviceLabels(service string) map[string]string {
+	return map[string]string{ProjectLabel: c.stack.projectName, ServiceLabel: service}
+}
+
+func (c *kubernetesConverter) convertConfig(name string) error {
+	path := []string{"configs", name}
+	config, _ := c.stack.sourceSection("configs")[name].(map[string]interface{})
+	if external, _ := config["external"].(bool); external {
+		return nil
+	}
+
+	var content string
+	if file, isFile := config["file"].(string); isFile {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("reading config %s: %w", name, err)
+		}
+		content = string(data)
+	} else if inline, isContent := config["content"].(string); isContent {
+		content = inline
+	} else {
+		c.unsupported(path, "only configs from a file or with content can be converted")
+		return nil
+	}
+	c.add("v1", "ConfigMap", name, nil, map[string]interface{}{"data": map[string]string{name: content}})
+	return nil
+}
+
+func (c *kubernetesConverter) convertSecret(name string, secret Secret) error {
+	path := []string{"secrets", name}
+	if _, isExternal := secret.GetExternalName(); isExternal {
+		return nil
+	}
+	if secret.GetEnvironment() != "" {
+		c.unsupported(path, "secret from environment variable %s must be created in the cluster", secret.GetEnvironment())
+		return nil
+	}
+	data, err := ioutil.ReadFile(secret.GetFile())
+	if err != nil {
+		return fmt.Errorf("reading secret %s: %w", name, err)
+	}
+	c.add("v1", "Secret", name, nil, map[string]interface{}{
+		"type": "Opaque",
+		"data": map[string]string{name: base64.StdEncoding.EncodeToString(data)},
+	})
+	return nil
+}
+
+func (c *kubernetesConverter) convertVolume(name string, volume Volume) {
+	if _, isExternal := volume.GetExternalName(); isExternal {
+		return
+	}
+	if driver := volume.GetCreateConfig().Driver; driver != "" && driver != "local" {
+		c.unsupported([]string{"volumes", name, "driver"}, "volume driver %s is replaced by the storage class", driver)
+	}
+	if c.isClaimTemplate(name) && c.isMounted(name) {
+		return
+	}
+	accessMode := "ReadWriteOnce"
+	if c.sharedVolumes[name] {
+		accessMode = "ReadWriteMany"
+		c.unsupported([]string{"volumes", name}, "volume is mounted by more than one service so the storage class must support ReadWriteMany")
+	}
+	c.add("v1", "PersistentVolumeClaim", name, map[string]string{VolumeLabel: name}, map[string]interface{}{"spec": c.claimSpec(accessMode)})
+}
+
+// claimSpec requests a volume of the configured size and storage class
+func (c *kubernetesConverter) claimSpec(accessMode string) map[string]interface{} {
+	spec := map[string]interface{}{
+		"accessModes": []string{accessMode},
+		"resources":   map[string]interface{}{"requests": map[string]string{"storage": c.options.VolumeSize}},
+	}
+	if c.options.StorageClass != "" {
+		spec["storageClassName"] = c.options.StorageClass
+	}
+	return spec
+}
+
+// isClaimTemplate returns true if the named volume becomes a claim template of the StatefulSet of the
+// service that mounts it, so each pod has its own volume. External and shared volumes are claimed once
+func (c *kubernetesConverter) isClaimTemplate(name string) bool {
+	_, isExternal := c.stack.volumes[name].GetExternalName()
+	return !isExternal && !c.sharedVolumes[name]
+}
+
+// isMounted returns true if any service mounts the named volume
+func (c *kubernetesConverter) isMounted(name string) bool {
+	_, isMounted := c.sharedVolumes[name]
+	return isMounted
+}
+
+// claimName returns the name of the claim for a named volume, which is the external name for external volumes
+func (c *kubernetesConverter) claimName(name string) string {
+	if externalName, isExternal := c.stack.volumes[name].GetExternalName(); isExternal && externalName != "" {
+		return kubernetesName(externalName)
+	}
+	return kubernetesName(name)
+}
+
+// secretName returns the name of the Secret for a secret, which is the external name for external secrets
+func (c *kubernetesConverter) secretName(name string) string {
+	if externalName, isExternal := c.stack.secrets[name].GetExternalName(); isExternal && externalName != "" {
+		return kubernetesName(externalName)
+	}
+	return kubernetesName(name)
+}
+
+func (c *kubernetesConverter) convertService(name string, service Service) error {
+	path := []string{"services", name}
+	source := c.stack.sourceService(name)
+	for _, option := range sortedKeys(source) {
+		if reason, isUnsupported := unsupportedServiceOptions[option]; isUnsupported {
+			c.unsupported(append(path, option), "%s", reason)
+		} else if !convertedServiceOptions[option] && !strings.HasPrefix(option, "x-") {
+			c.unsupported(append(path, option), "%s cannot be converted", option)
+		}
+	}
+	c.reportServiceNetworks(path, source)
+	c.reportDeploy(path, source)
+
+	config, hostConfig := service.GetContainerConfig(), service.GetHostConfig()
+	containerSpec := map[string]interface{}{"name": kubernetesName(name), "image": config.Image}
+	if len(config.Entrypoint) > 0 {
+		containerSpec["command"] = []string(config.Entrypoint)
+	}
+	if len(config.Cmd) > 0 {
+		containerSpec["args"] = []string(config.Cmd)
+	}
+	setIfNotEmpty(containerSpec, "workingDir", config.WorkingDir)
+	setIfNotEmpty(containerSpec, "tty", config.Tty)
+	setIfNotEmpty(containerSpec, "stdin", config.OpenStdin)
+	if env := c.convertEnvironment(path, config.Env); len(env) > 0 {
+		containerSpec["env"] = env
+	}
+	if policy, isSet := source["pull_policy"].(string); isSet {
+		pullPolicies := map[string]string{"always": "Always", "never": "Never", "missing": "IfNotPresent", "if_not_present": "IfNotPresent"}
+		if pullPolicy, isValid := pullPolicies[policy]; isValid {
+			containerSpec["imagePullPolicy"] = pullPolicy
+		} else {
+			c.unsupported(append(path, "pull_policy"), "pull_policy %s has no equivalent", policy)
+		}
+	}
+
+	ports, servicePorts, publishedPorts := c.convertPorts(path, config.ExposedPorts, hostConfig.PortBindings)
+	if len(ports) > 0 {
+		containerSpec["ports"] = ports
+	}
+	if probe := convertProbe(config.Healthcheck); probe != nil {
+		containerSpec["livenessProbe"], containerSpec["readinessProbe"] = probe, probe
+	}
+	if resources := c.convertResources(path, source); len(resources) > 0 {
+		containerSpec["resources"] = resources
+	}
+	if securityContext := c.convertSecurityContext(path, config.User, hostConfig); len(securityContext) > 0 {
+		containerSpec["securityContext"] = securityContext
+	}
+
+	pod := map[string]interface{}{"containers": []interface{}{containerSpec}}
+	mounts, volumes, claims := c.convertMounts(name, service, hostConfig.Tmpfs)
+	if len(mounts) > 0 {
+		containerSpec["volumeMounts"] = mounts
+	}
+	if len(volumes) > 0 {
+		pod["volumes"] = volumes
+	}
+	isStateful := len(claims) > 0
+	setIfNotEmpty(pod, "hostname", config.Hostname)
+	if config.StopTimeout != nil {
+		pod["terminationGracePeriodSeconds"] = *config.StopTimeout
+	}
+	c.convertNetworkMode(path, hostConfig, pod)
+	if hostAliases := c.convertExtraHosts(path, hostConfig.ExtraHosts); len(hostAliases) > 0 {
+		pod["hostAliases"] = hostAliases
+	}
+	if platform := service.GetPlatformConfig(); platform.OS != "" {
+		nodeSelector := map[string]string{"kubernetes.io/os": platform.OS}
+		if platform.Architecture != "" {
+			nodeSelector["kubernetes.io/arch"] = platform.Architecture
+		}
+		pod["nodeSelector"] = nodeSelector
+	}
+	if restart := hostConfig.RestartPolicy.Name; restart != "" && restart != "always" && restart != "unless-stopped" {
+		c.unsupported(append(path, "restart"), "containers of a %s are always restarted", workloadKind(isStateful))
+	}
+
+	labels := c.serviceLabels(name)
+	template := map[string]interface{}{"metadata": map[string]interface{}{"labels": labels}, "spec": pod}
+	if len(config.Labels) > 0 {
+		template["metadata"].(map[string]interface{})["annotations"] = config.Labels
+	}
+	spec := map[string]interface{}{
+		"replicas": service.GetReplicas(),
+		"selector": map[string]interface{}{"matchLabels": labels},
+		"template": template,
+	}
+	if isStateful {
+		spec["serviceName"] = kubernetesName(name)
+		spec["volumeClaimTemplates"] = claims
+		if service.GetReplicas() > 1 {
+			c.unsupported(append(path, "volumes"), "each replica of the StatefulSet has its own copy of the named volumes")
+		}
+	}
+	c.add("apps/v1", workloadKind(isStateful), name, map[string]string{ServiceLabel: name}, map[string]interface{}{"spec": spec})
+
+	// The headless Service of a StatefulSet gives its pods stable names, so published ports are
+	// exposed by a second Service
+	if isStateful {
+		serviceSpec := map[string]interface{}{"selector": labels, "clusterIP": "None"}
+		if len(servicePorts) > 0 {
+			serviceSpec["ports"] = servicePorts
+		}
+		c.add("v1", "Service", name, map[string]string{ServiceLabel: name}, map[string]interface{}{"spec": serviceSpec})
+	}
+	if publishedPorts || (!isStateful && len(servicePorts) > 0) {
+		serviceName := name
+		if isStateful {
+			serviceName = name + "-published"
+		}
+		serviceSpec := map[string]interface{}{"selector": labels, "ports": servicePorts}
+		if publishedPorts {
+			serviceSpec["type"] = "LoadBalancer"
+		}
+		c.add("v1", "Service", serviceName, map[string]string{ServiceLabel: name}, map[string]interface{}{"spec": serviceSpec})
+	}
+	return nil
+}
+
+func workloadKind(isStateful bool) string {
+	if isStateful {
+		return "StatefulSet"
+	}
+	return "Deployment"
+}
+
+// convertEnvironment converts KEY=VALUE pairs. Variables without a value are taken from the
+// environment of compose so cannot be converted
+func (c *kubernetesConverter) convertEnvironment(path []string, env []string) []interface{} {
+	var converted []interface{}
+	for _, variable := range env {
+		parts := strings.SplitN(variable, "=", 2)
+		if len(parts) != 2 {
+			c.unsupported(append(path, "environment", parts[0]), "variable without a value is not set from the environment")
+			continue
+		}
+		converted = append(converted, map[string]string{"name": parts[0], "value": parts[1]})
+	}
+	return converted
+}
+
+// convertPorts returns the ports of the container and the Service. Published ports are exposed
+// on their host port by a LoadBalancer Service, other ports on their container port
+func (c *kubernetesConverter) convertPorts(path []string, exposed nat.PortSet, bindings nat.PortMap) ([]interface{}, []interface{}, bool) {
+	all := nat.PortMap{}
+	for port := range exposed {
+		all[port] = nil
+	}
+	for port, portBindings := range bindings {
+		all[port] = portBindings
+	}
+
+	var containerPorts, servicePorts []interface{}
+	published := false
+	for _, port := range sortedPorts(all) {
+		protocol := strings.ToUpper(port.Proto())
+		portName := fmt.Sprintf("%s-%d", port.Proto(), port.Int())
+		containerPorts = append(containerPorts, map[string]interface{}{"name": portName, "containerPort": port.Int(), "protocol": protocol})
+
+		servicePort := port.Int()
+		for _, binding := range all[port] {
+			if binding.HostIP != "" && binding.HostIP != "0.0.0.0" {
+				c.unsupported(append(path, "ports"), "port %s cannot be bound to host IP %s", port, binding.HostIP)
+			}
+			if hostPort, err := strconv.Atoi(binding.HostPort); err == nil {
+				servicePort, published = hostPort, true
+			}
+		}
+		servicePorts = append(servicePorts, map[string]interface{}{"name": portName, "port": servicePort, "targetPort": port.Int(), "protocol": protocol})
+	}
+	return containerPorts, servicePorts, published
+}
+
+// convertProbe converts the healthcheck into a probe, or returns nil if there is no healthcheck
+func convertProbe(healthcheck *container.HealthConfig) map[string]interface{} {
+	if healthcheck == nil || len(healthcheck.Test) < 2 {
+		return nil
+	}
+	command := healthcheck.Test[1:]
+	if healthcheck.Test[0] == "CMD-SHELL" {
+		command = []string{"/bin/sh", "-c", strings.Join(healthcheck.Test[1:], " ")}
+	}
+	probe := map[string]interface{}{"exec": map[string]interface{}{"command": command}}
+	setIfNotEmpty(probe, "periodSeconds", probeSeconds(healthcheck.Interval))
+	setIfNotEmpty(probe, "timeoutSeconds", probeSeconds(healthcheck.Timeout))
+	setIfNotEmpty(probe, "initialDelaySeconds", probeSeconds(healthcheck.StartPeriod))
+	setIfNotEmpty(probe, "failureThreshold", healthcheck.Retries)
+	return probe
+}
+
+// probeSeconds rounds the duration up to whole seconds as probes do not support fractions
+func probeSeconds(duration time.Duration) int {
+	return int((duration + time.Second - 1) / time.Second)
+}
+
+// convertResources converts the resource options of the service and deploy.resources, which
+// overrides them. They are read from the source as they are not part of the host config
+func (c *kubernetesConverter) convertResources(path []string, source map[string]interface{}) map[string]interface{} {
+	quantities := map[string]map[string]string{"limits": {}, "requests": {}}
+	setQuantity := func(optionPath []string, field, resource string, value interface{}) {
+		if resource == "cpu" {
+			quantities[field]["cpu"] = fmt.Sprint(value)
+			return
+		}
+		bytes, err := units.RAMInBytes(fmt.Sprint(value))
+		if err != nil {
+			c.unsupported(optionPath, "memory %v is not a valid size", value)
+			return
+		}
+		quantities[field]["memory"] = memoryQuantity(bytes)
+	}
+
+	for _, option := range []struct{ name, field, resource string }{
+		{"cpus", "limits", "cpu"}, {"mem_limit", "limits", "memory"}, {"mem_reservation", "requests", "memory"},
+	} {
+		if value, isSet := source[option.name]; isSet {
+			setQuantity(append(path, option.name), option.field, option.resource, value)
+		}
+	}
+	// Shares are a weight relative to the default of 1024, which is requested as that fraction of a CPU
+	if value, isSet := source["cpu_shares"]; isSet {
+		if shares, err := strconv.ParseInt(fmt.Sprint(value), 10, 64); err == nil && shares > 0 {
+			quantities["requests"]["cpu"] = fmt.Sprintf("%dm", shares*1000/1024)
+		} else {
+			c.unsupported(append(path, "cpu_shares"), "cpu_shares %v is not a positive number", value)
+		}
+	}
+
+	deploy, _ := source["deploy"].(map[string]interface{})
+	resources, _ := deploy["resources"].(map[string]interface{})
+	for _, mapping := range [][2]string{{"limits", "limits"}, {"reservations", "requests"}} {
+		option, field := mapping[0], mapping[1]
+		values, _ := resources[option].(map[string]interface{})
+		for _, name := range sortedKeys(values) {
+			optionPath := append(path, "deploy", "resources", option, name)
+			switch name {
+			case "cpus":
+				setQuantity(optionPath, field, "cpu", values[name])
+			case "memory":
+				setQuantity(optionPath, field, "memory", values[name])
+			default:
+				c.unsupported(optionPath, "%s cannot be converted", name)
+			}
+		}
+	}
+
+	converted := map[string]interface{}{}
+	for field, values := range quantities {
+		if len(values) > 0 {
+			converted[field] = values
+		}
+	}
+	return converted
+}
+
+// reportDeploy reports the deploy options other than replicas and resources, which are converted
+func (c *kubernetesConverter) reportDeploy(path []string, source map[string]interface{}) {
+	deploy, _ := source["deploy"].(map[string]interface{})
+	for _, option := range sortedKeys(deploy) {
+		if option != "replicas" && option != "resources" {
+			c.unsupported(append(path, "deploy", option), "deploy.%s cannot be converted", option)
+		}
+	}
+}
+
+// reportServiceNetworks reports the options of the networks the service joins. Pods only join the
+// network of the cluster so only membership is converted, as a NetworkPolicy
+func (c *kubernetesConverter) reportServiceNetworks(path []string, source map[string]interface{}) {
+	networks, _ := source["networks"].(map[string]interface{})
+	for _, network := range sortedKeys(networks) {
+		options, _ := networks[network].(map[string]interface{})
+		for _, option := range sortedKeys(options) {
+			optionPath := append(path, "networks", network, option)
+			switch option {
+			case "aliases":
+				c.unsupported(optionPath, "services are reached by the name of their Service")
+			case "ipv4_address", "ipv6_address", "link_local_ips", "mac_address":
+				c.unsupported(optionPath, "pod addresses are assigned by the network plugin")
+			default:
+				c.unsupported(optionPath, "%s cannot be converted", option)
+			}
+		}
+	}
+}
+
+// memoryQuantity formats the bytes with the largest binary suffix that divides them exactly
+func memoryQuantity(bytes int64) string {
+	for _, suffix := range []struct {
+		name string
+		size int64
+	}{{"Gi", units.GiB}, {"Mi", units.MiB}, {"Ki", units.KiB}} {
+		if bytes >= suffix.size && bytes%suffix.size == 0 {
+			return strconv.FormatInt(bytes/suffix.size, 10) + suffix.name
+		}
+	}
+	return strconv.FormatInt(bytes, 10)
+}
+
+// convertSecurityContext converts the user, privileges, capabilities and read only root filesystem
+func (c *kubernetesConverter) convertSecurityContext(path []string, user string, hostConfig container.HostConfig) map[string]interface{} {
+	securityContext := map[string]interface{}{}
+	if user != "" {
+		parts := strings.SplitN(user, ":", 2)
+		uid, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			c.unsupported(append(path, "user"), "user %s must be a numeric ID", user)
+		} else {
+			securityContext["runAsUser"] = uid
+		}
+		if len(parts) == 2 {
+			if gid, err := strconv.ParseInt(parts[1], 10, 64); err == nil {
+				securityContext["runAsGroup"] = gid
+			} else {
+				c.unsupported(append(path, "user"), "group %s must be a numeric ID", parts[1])
+			}
+		}
+	}
+	setIfNotEmpty(securityContext, "privileged", hostConfig.Privileged)
+	setIfNotEmpty(securityContext, "readOnlyRootFilesystem", hostConfig.ReadonlyRootfs)
+	if len(hostConfig.CapAdd) > 0 || len(hostConfig.CapDrop) > 0 {
+		capabilities := map[string]interface{}{}
+		setIfNotEmpty(capabilities, "add", []string(hostConfig.CapAdd))
+		setIfNotEmpty(capabilities, "drop", []string(hostConfig.CapDrop))
+		securityContext["capabilities"] = capabilities
+	}
+	return securityContext
+}
+
+// convertMounts converts the volumes, tmpfs, secrets and configs of the service. It also returns the
+// claim templates of the named volumes only this service mounts, which make it a StatefulSet
+func (c *kubernetesConverter) convertMounts(name string, service Service, tmpfs map[string]string) ([]interface{}, []interface{}, []interface{}) {
+	path := []string{"services", name}
+	var mounts, volumes, claims []interface{}
+	addMount := func(volume map[string]interface{}, mount map[string]interface{}) {
+		mount["name"] = volume["name"]
+		mounts = append(mounts, mount)
+		for _, existing := range volumes {
+			if existing.(map[string]interface{})["name"] == volume["name"] {
+				return
+			}
+		}
+		volumes = append(volumes, volume)
+	}
+
+	for i, volume := range service.GetVolumes() {
+		mount := map[string]interface{}{"mountPath": volume.Target}
+		setIfNotEmpty(mount, "readOnly", volume.ReadOnly)
+		switch {
+		case volume.Type == "volume" && volume.Source != "" && c.isClaimTemplate(volume.Source):
+			mount["name"] = kubernetesName(volume.Source)
+			mounts = append(mounts, mount)
+			claim := map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"name":   kubernetesName(volume.Source),
+					"labels": map[string]string{ProjectLabel: c.stack.projectName, VolumeLabel: volume.Source},
+				},
+				"spec": c.claimSpec("ReadWriteOnce"),
+			}
+			if !containsClaim(claims, claim) {
+				claims = append(claims, claim)
+			}
+		case volume.Type == "volume" && volume.Source != "":
+			addMount(map[string]interface{}{
+				"name":                  kubernetesName(volume.Source),
+				"persistentVolumeClaim": map[string]string{"claimName": c.claimName(volume.Source)},
+			}, mount)
+		case volume.Type == "volume" || volume.Type == "tmpfs":
+			emptyDir := map[string]interface{}{}
+			if volume.Type == "tmpfs" {
+				emptyDir["medium"] = "Memory"
+			}
+			addMount(map[string]interface{}{"name": fmt.Sprintf("%s-%d", volume.Type, i), "emptyDir": emptyDir}, mount)
+		default:
+			c.unsupported(append(path, "volumes"), "%s mount of %s is not portable between nodes", volume.Type, volume.Source)
+		}
+	}
+
+	for i, target := range sortedKeys(tmpfs) {
+		addMount(map[string]interface{}{"name": fmt.Sprintf("tmpfs-%d", i+len(service.GetVolumes())), "emptyDir": map[string]string{"medium": "Memory"}},
+			map[string]interface{}{"mountPath": target})
+	}
+
+	for _, secret := range service.GetSecrets() {
+		if _, exists := c.stack.secrets[secret.Source]; !exists || c.stack.secrets[secret.Source].GetEnvironment() != "" {
+			continue
+		}
+		target := fileTarget(secret.Target, secret.Source, "/run/secrets")
+		volume := map[string]interface{}{"name": "secret-" + kubernetesName(secret.Source), "secret": c.fileSource("secretName", c.secretName(secret.Source), secret.Source, secret.Mode)}
+		addMount(volume, map[string]interface{}{"mountPath": target, "subPath": secret.Source, "readOnly": true})
+		if secret.UID != "" || secret.GID != "" {
+			c.unsupported(append(path, "secrets", secret.Source), "the owner of the secret cannot be set")
+		}
+	}
+
+	configs, _ := c.stack.sourceService(name)["configs"].([]interface{})
+	for _, config := range configs {
+		source, target, mode := serviceConfigFile(config)
+		sourceConfig, exists := c.stack.sourceSection("configs")[source].(map[string]interface{})
+		if !exists {
+			c.unsupported(append(path, "configs"), "config %s is not defined", source)
+			continue
+		}
+		configName := source
+		if external, _ := sourceConfig["external"].(bool); external {
+			if externalName, hasName := sourceConfig["name"].(string); hasName {
+				configName = externalName
+			}
+		}
+		volume := map[string]interface{}{"name": "config-" + kubernetesName(source), "configMap": c.fileSource("name", kubernetesName(configName), source, mode)}
+		addMount(volume, map[string]interface{}{"mountPath": fileTarget(target, source, ""), "subPath": source, "readOnly": true})
+	}
+	return mounts, volumes, claims
+}
+
+// containsClaim returns true if a claim template with the same name is in the list
+func containsClaim(claims []interface{}, claim map[string]interface{}) bool {
+	name := claim["metadata"].(map[string]interface{})["name"]
+	for _, existing := range claims {
+		if existing.(map[string]interface{})["metadata"].(map[string]interface{})["name"] == name {
+			return true
+		}
+	}
+	return false
+}
+
+// fileSource is the source of a secret or configMap volume with the file mode if set
+func (c *kubernetesConverter) fileSource(nameField, name, key string, mode *uint32) map[string]interface{} {
+	source := map[string]interface{}{nameField: name, "items": []interface{}{map[string]string{"key": key, "path": key}}}
+	if mode != nil {
+		source["defaultMode"] = *mode
+	}
+	return source
+}
+
+// fileTarget returns where a secret or config is mounted. Relative targets are inside the directory
+func fileTarget(target, source, directory string) string {
+	if target == "" {
+		target = source
+	}
+	if strings.HasPrefix(target, "/") {
+		return target
+	}
+	return path.Join("/", directory, target)
+}
+
+// serviceConfigFile reads the short or long syntax of a config granted to a service
+func serviceConfigFile(config interface{}) (string, string, *uint32) {
+	switch config := config.(type) {
+	case string:
+		return config, "", nil
+	case map[string]interface{}:
+		source, _ := config["source"].(string)
+		target, _ := config["target"].(string)
+		if mode, isInt := config["mode"].(int); isInt {
+			fileMode := uint32(mode)
+			return source, target, &fileMode
+		}
+		return source, target, nil
+	}
+	return "", "", nil
+}
+
+// convertNetworkMode maps host networking onto the pod. Sharing another service's network stack
+// or disabling networking cannot be converted
+func (c *kubernetesConverter) convertNetworkMode(path []string, hostConfig container.HostConfig, pod map[string]interface{}) {
+	switch mode := string(hostConfig.NetworkMode); {
+	case mode == "host":
+		pod["hostNetwork"] = true
+	case mode != "" && mode != "default" && mode != "bridge":
+		c.unsupported(append(path, "network_mode"), "network_mode %s cannot be converted", mode)
+	}
+}
+
+// convertExtraHosts converts host:ip entries into host aliases grouped by IP
+func (c *kubernetesConverter) convertExtraHosts(path []string, extraHosts []string) []interface{} {
+	hostnames := map[string][]string{}
+	for _, entry := range extraHosts {
+		parts := strings.SplitN(entry, ":", 2)
+		if len(parts) != 2 {
+			c.unsupported(append(path, "extra_hosts"), "extra host %s should be host:ip", entry)
+			continue
+		}
+		hostnames[parts[1]] = append(hostnames[parts[1]], parts[0])
+	}
+	var aliases []interface{}
+	for _, ip := range sortedKeys(hostnames) {
+		aliases = append(aliases, map[string]interface{}{"ip": ip, "hostnames": hostnames[ip]})
+	}
+	return aliases
+}
+
+// convertNetworks creates a NetworkPolicy for each service that only allows traffic from services that
+// share one of its networks. Published ports are also allowed from anywhere
+func (c *kubernetesConverter) convertNetworks() {
+	members := make(map[string][]string)
+	for _, name := range sortedKeys(c.stack.services) {
+		service := c.stack.services[name]
+		if mode := string(service.GetHostConfig().NetworkMode); mode != "" && mode != "default" && mode != "bridge" {
+			continue
+		}
+		endpoints := service.GetNetworkConfig().EndpointsConfig
+		if len(endpoints) == 0 {
+			members[implicitDefaultNetwork] = append(members[implicitDefaultNetwork], name)
+		}
+		for _, network := range sortedKeys(endpoints) {
+			members[network] = append(members[network], name)
+		}
+	}
+
+	for _, name := range sortedKeys(c.stack.networks) {
+		network := c.stack.networks[name]
+		if _, isExternal := network.GetExternalName(); isExternal {
+			c.unsupported([]string{"networks", name}, "external network is treated as a network shared by its services")
+		}
+		if network.GetCreateConfig().IPAM != nil && len(network.GetCreateConfig().IPAM.Config) > 0 {
+			c.unsupported([]string{"networks", name, "ipam"}, "pod addresses are assigned by the network plugin")
+		}
+	}
+
+	for _, name := range sortedKeys(c.stack.services) {
+		peers := map[string]bool{}
+		for _, network := range sortedKeys(members) {
+			isMember := false
+			for _, member := range members[network] {
+				isMember = isMember || member == name
+			}
+			for _, member := range members[network] {
+				peers[member] = peers[member] || isMember
+			}
+		}
+		if !peers[name] {
+			continue
+		}
+
+		var from []interface{}
+		for _, peer := range sortedKeys(peers) {
+			if peers[peer] {
+				from = append(from, map[string]interface{}{"podSelector": map[string]interface{}{"matchLabels": c.serviceLabels(peer)}})
+			}
+		}
+		ingress := []interface{}{map[string]interface{}{"from": from}}
+
+		var published []interface{}
+		bindings := c.stack.services[name].GetHostConfig().PortBindings
+		for _, port := range sortedPorts(bindings) {
+			for _, binding := range bindings[port] {
+				if binding.HostPort != "" {
+					published = append(published, map[string]interface{}{"port": port.Int(), "protocol": strings.ToUpper(port.Proto())})
+					break
+				}
+			}
+		}
+		if len(published) > 0 {
+			ingress = append(ingress, map[string]interface{}{"ports": published})
+		}
+
+		c.add("networking.k8s.io/v1", "NetworkPolicy", name, map[string]string{ServiceLabel: name}, map[string]interface{}{"spec": map[string]interface{}{
+			"podSelector": map[string]interface{}{"matchLabels": c.serviceLabels(name)},
+			"policyTypes": []string{"Ingress"},
+			"ingress":     ingress,
+		}})
+	}
+}
@@ -0,0 +1,301 @@
+package compose_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/rmasp98/go-compose/compose"
+	"gopkg.in/yaml.v3"
+)
+
+const kubernetesCompose = `
+name: shop
+services:
+  web:
+    image: nginx
+    command: ["nginx", "-g", "daemon off;"]
+    environment:
+      MODE: production
+    ports:
+      - "8080:80"
+    healthcheck:
+      test: ["CMD-SHELL", "curl -f http://localhost"]
+      interval: 10s
+      timeout: 1500ms
+      retries: 3
+    deploy:
+      replicas: 2
+      resources:
+        limits:
+          cpus: "0.5"
+          memory: 512M
+        reservations:
+          memory: 128M
+      restart_policy:
+        condition: on-failure
+    configs:
+      - source: site
+        target: /etc/nginx/conf.d/site.conf
+    volumes:
+      - uploads:/srv/uploads
+    networks: [frontend]
+    depends_on: [api]
+  api:
+    image: api
+    user: "1000:1000"
+    expose: ["9000"]
+    mem_limit: 256m
+    cpus: 0.25
+    cpu_shares: 512
+    pids_limit: 100
+    group_add: [audio]
+    secrets: [token]
+    networks: [frontend, backend]
+    volumes:
+      - /tmp
+      - uploads:/uploads
+  db:
+    image: postgres
+    volumes:
+      - data:/var/lib/postgresql/data
+      - ./init:/docker-entrypoint-initdb.d
+    networks:
+      backend:
+        aliases: [database]
+networks:
+  frontend:
+  backend:
+volumes:
+  data:
+  uploads:
+secrets:
+  token:
+    file: ./token.txt
+configs:
+  site:
+    file: ./site.conf
+`
+
+func convertToKubernetes(t *testing.T) compose.KubernetesManifests {
+	directory := writeProject(t, map[string]string{
+		"compose.yaml": kubernetesCompose,
+		"token.txt":    "secret",
+		"site.conf":    "server {}",
+	})
+	stack, err := compose.Load(compose.LoadOptions{ProjectDirectory: directory, Environment: map[string]string{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifests, err := stack.ToKubernetes(compose.KubernetesOptions{Namespace: "shop", StorageClass: "fast"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return manifests
+}
+
+// findObject returns the object as decoded from the YAML output so the test checks what kubectl would read
+func findObject(t *testing.T, manifests compose.KubernetesManifests, kind, name string) map[string]interface{} {
+	output, err := manifests.YAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoder := yaml.NewDecoder(strings.NewReader(string(output)))
+	for {
+		var object map[string]interface{}
+		if err := decoder.Decode(&object); err != nil {
+			t.Fatalf("%s %s not found: %v", kind, name, err)
+		}
+		if compose.KubernetesObject(object).Kind() == kind && compose.KubernetesObject(object).Name() == name {
+			return object
+		}
+	}
+}
+
+// lookup follows the dotted path through maps and lists, e.g. spec.template.spec.containers.0.image
+func lookup(value interface{}, path string) interface{} {
+	for _, element := range strings.Split(path, ".") {
+		switch current := value.(type) {
+		case map[string]interface{}:
+			value = current[element]
+		case []interface{}:
+			var index int
+			if _, err := fmt.Sscan(element, &index); err != nil || index >= len(current) {
+				return nil
+			}
+			value = current[index]
+		default:
+			return nil
+		}
+	}
+	return value
+}
+
+func TestToKubernetesCreatesObjects(t *testing.T) {
+	manifests := convertToKubernetes(t)
+
+	var objects []string
+	for _, object := range manifests.Objects {
+		objects = append(objects, object.Kind()+"/"+object.Name())
+	}
+	expected := []string{
+		"ConfigMap/site", "Secret/token", "PersistentVolumeClaim/uploads",
+		"Deployment/api", "Service/api", "StatefulSet/db", "Service/db", "Deployment/web", "Service/web",
+		"NetworkPolicy/api", "NetworkPolicy/db", "NetworkPolicy/web",
+	}
+	if err := verifyValue(expected, objects); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestToKubernetesConvertsServices(t *testing.T) {
+	manifests := convertToKubernetes(t)
+	web := findObject(t, manifests, "Deployment", "web")
+	api := findObject(t, manifests, "Deployment", "api")
+	db := findObject(t, manifests, "StatefulSet", "db")
+
+	testData := map[string]struct {
+		object   map[string]interface{}
+		path     string
+		expected interface{}
+	}{
+		"namespace":      {web, "metadata.namespace", "shop"},
+		"replicas":       {web, "spec.replicas", 2},
+		"selector":       {web, "spec.selector.matchLabels", map[string]interface{}{compose.ProjectLabel: "shop", compose.ServiceLabel: "web"}},
+		"args":           {web, "spec.template.spec.containers.0.args", []interface{}{"nginx", "-g", "daemon off;"}},
+		"env":            {web, "spec.template.spec.containers.0.env.0", map[string]interface{}{"name": "MODE", "value": "production"}},
+		"containerPort":  {web, "spec.template.spec.containers.0.ports.0.containerPort", 80},
+		"probeCommand":   {web, "spec.template.spec.containers.0.livenessProbe.exec.command", []interface{}{"/bin/sh", "-c", "curl -f http://localhost"}},
+		"probeTimeout":   {web, "spec.template.spec.containers.0.readinessProbe.timeoutSeconds", 2},
+		"probeRetries":   {web, "spec.template.spec.containers.0.readinessProbe.failureThreshold", 3},
+		"cpuLimit":       {web, "spec.template.spec.containers.0.resources.limits.cpu", "0.5"},
+		"memoryLimit":    {web, "spec.template.spec.containers.0.resources.limits.memory", "512Mi"},
+		"memoryRequest":  {web, "spec.template.spec.containers.0.resources.requests.memory", "128Mi"},
+		"configMount":    {web, "spec.template.spec.containers.0.volumeMounts.1.mountPath", "/etc/nginx/conf.d/site.conf"},
+		"configMap":      {web, "spec.template.spec.volumes.1.configMap.name", "site"},
+		"apiCPULimit":    {api, "spec.template.spec.containers.0.resources.limits.cpu", "0.25"},
+		"apiMemoryLimit": {api, "spec.template.spec.containers.0.resources.limits.memory", "256Mi"},
+		"apiCPURequest":  {api, "spec.template.spec.containers.0.resources.requests.cpu", "500m"},
+		"runAsUser":      {api, "spec.template.spec.containers.0.securityContext.runAsUser", 1000},
+		"secretMount":    {api, "spec.template.spec.containers.0.volumeMounts.2.mountPath", "/run/secrets/token"},
+		"secretName":     {api, "spec.template.spec.volumes.2.secret.secretName", "token"},
+		"anonymous":      {api, "spec.template.spec.volumes.0.emptyDir", map[string]interface{}{}},
+		"serviceName":    {db, "spec.serviceName", "db"},
+		"claimMount":     {db, "spec.template.spec.containers.0.volumeMounts.0.name", "data"},
+		"claimTemplate":  {db, "spec.volumeClaimTemplates.0.metadata.name", "data"},
+		"claimStorage":   {db, "spec.volumeClaimTemplates.0.spec.storageClassName", "fast"},
+		"noPodClaim":     {db, "spec.template.spec.volumes", nil},
+		"sharedClaim":    {web, "spec.template.spec.volumes.0.persistentVolumeClaim.claimName", "uploads"},
+		"bindNotMounted": {db, "spec.template.spec.containers.0.volumeMounts.1", nil},
+	}
+
+	for name, data := range testData {
+		t.Run(name, func(t *testing.T) {
+			if err := verifyValue(data.expected, lookup(data.object, data.path)); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestToKubernetesConvertsPortsVolumesAndNetworks(t *testing.T) {
+	manifests := convertToKubernetes(t)
+	webLabels := map[string]interface{}{compose.ProjectLabel: "shop", compose.ServiceLabel: "web"}
+
+	testData := map[string]struct {
+		kind, name, path string
+		expected         interface{}
+	}{
+		"loadBalancer":  {"Service", "web", "spec.type", "LoadBalancer"},
+		"publishedPort": {"Service", "web", "spec.ports.0", map[string]interface{}{"name": "tcp-80", "port": 8080, "targetPort": 80, "protocol": "TCP"}},
+		"clusterIP":     {"Service", "api", "spec.type", nil},
+		"exposedPort":   {"Service", "api", "spec.ports.0.port", 9000},
+		"headless":      {"Service", "db", "spec.clusterIP", "None"},
+		"storageClass":  {"PersistentVolumeClaim", "uploads", "spec.storageClassName", "fast"},
+		"storage":       {"PersistentVolumeClaim", "uploads", "spec.resources.requests.storage", "1Gi"},
+		"accessMode":    {"PersistentVolumeClaim", "uploads", "spec.accessModes", []interface{}{"ReadWriteMany"}},
+		"secretData":    {"Secret", "token", "data.token", "c2VjcmV0"},
+		"configData":    {"ConfigMap", "site", "data.site", "server {}"},
+		"webPeers":      {"NetworkPolicy", "web", "spec.ingress.0.from.1.podSelector.matchLabels", webLabels},
+		"webPublished":  {"NetworkPolicy", "web", "spec.ingress.1.ports.0.port", 80},
+		"apiPeers":      {"NetworkPolicy", "api", "spec.ingress.0.from.2.podSelector.matchLabels", webLabels},
+		"dbPeers":       {"NetworkPolicy", "db", "spec.ingress.0.from.2", nil},
+	}
+
+	for name, data := range testData {
+		t.Run(name, func(t *testing.T) {
+			object := findObject(t, manifests, data.kind, data.name)
+			if err := verifyValue(data.expected, lookup(object, data.path)); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestToKubernetesReportsUnsupportedOptions(t *testing.T) {
+	manifests := convertToKubernetes(t)
+
+	var paths []string
+	for _, issue := range manifests.Unsupported {
+		if issue.Rule != compose.RuleKubernetesUnsupported || issue.Severity != compose.SeverityWarning {
+			t.Errorf("Unexpected issue %v", issue)
+		}
+		paths = append(paths, strings.Join(issue.Path, "."))
+	}
+	expected := []string{
+		"volumes.uploads", "services.api.group_add", "services.api.pids_limit", "services.db.networks.backend.aliases",
+		"services.db.volumes", "services.web.depends_on", "services.web.deploy.restart_policy",
+	}
+	if err := verifyValue(expected, paths); err != nil {
+		t.Error(err)
+	}
+	if !strings.Contains(manifests.Report(), "services.db.volumes: bind mount of") {
+		t.Errorf("Unexpected report:\n%s", manifests.Report())
+	}
+}
+
+func TestToKubernetesGivesStatefulSetsAHeadlessService(t *testing.T) {
+	stack, err := compose.NewStack(parseYaml(`
+name: shop
+services:
+  db:
+    image: postgres
+    ports: ["5432:5432"]
+    scale: 2
+    volumes:
+      - data:/var/lib/postgresql/data
+volumes:
+  data:
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifests, err := stack.ToKubernetes(compose.KubernetesOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testData := map[string]struct {
+		kind, name, path string
+		expected         interface{}
+	}{
+		"serviceName":   {"StatefulSet", "db", "spec.serviceName", "db"},
+		"claimTemplate": {"StatefulSet", "db", "spec.volumeClaimTemplates.0.spec.accessModes", []interface{}{"ReadWriteOnce"}},
+		"headless":      {"Service", "db", "spec.clusterIP", "None"},
+		"headlessPorts": {"Service", "db", "spec.ports.0.targetPort", 5432},
+		"headlessType":  {"Service", "db", "spec.type", nil},
+		"published":     {"Service", "db-published", "spec.type", "LoadBalancer"},
+		"publishedPort": {"Service", "db-published", "spec.ports.0.port", 5432},
+	}
+	for name, data := range testData {
+		t.Run(name, func(t *testing.T) {
+			object := findObject(t, manifests, data.kind, data.name)
+			if err := verifyValue(data.expected, lookup(object, data.path)); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+	if !strings.Contains(manifests.Report(), "services.db.volumes: each replica of the StatefulSet has its own copy") {
+		t.Errorf("Replicas with their own volumes should be reported:\n%s", manifests.Report())
+	}
+}
@@ -0,0 +1,25 @@
+package compose
+
+// Labels added to every resource created for a Stack so they can be found again.
+// These match the labels used by docker compose
+const (
+	ProjectLabel         = "com.docker.compose.project"
+	ServiceLabel         = "com.docker.compose.service"
+	ContainerNumberLabel = "com.docker.compose.container-number"
+	OneoffLabel          = "com.docker.compose.oneoff"
+	NetworkLabel         = "com.docker.compose.network"
+	VolumeLabel          = "com.docker.compose.volume"
+	ConfigHashLabel      = "com.docker.compose.config-hash"
+)
+
+// mergeLabels returns a new map containing the labels with the extra labels taking precedence
+func mergeLabels(labels map[string]string, extra map[string]string) map[string]string {
+	merged := make(map[string]string, len(labels)+len(extra))
+	for key, value := range labels {
+		merged[key] = value
+	}
+	for key, value := range extra {
+		merged[key] = value
+	}
+	return merged
+}
@@ -0,0 +1,221 @@
+package compose
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+)
+
+// LifecycleOptions controls which containers Start, Stop, Restart, Pause, Unpause and Kill act on
+type LifecycleOptions struct {
+	// Services limits the operation to the services. All services are included when empty
+	Services []string
+	// Timeout overrides the stop_grace_period of every service when stopping or restarting
+	Timeout *time.Duration
+	// Signal is sent by Kill. Defaults to SIGKILL
+	Signal string
+}
+
+const (
+	ActionRestarted Action = "restarted"
+	ActionPaused    Action = "paused"
+	ActionUnpaused  Action = "unpaused"
+	ActionKilled    Action = "killed"
+)
+
+// lifecycleOperation changes a single container and returns false if it was already in the desired state
+type lifecycleOperation func(ctx context.Context, c types.Container) (bool, error)
+
+// Start starts the stopped containers of the services and the services they depend on.
+// Dependencies are started first
+func (s Stack) Start(ctx context.Context, client Client, options LifecycleOptions) (Report, error) {
+	services, err := s.lifecycleServices(options)
+	if err != nil {
+		return Report{}, err
+	}
+	return s.runLifecycle(ctx, client, s.withDependencies(services), false, ActionStarted, func(ctx context.Context, c types.Container) (bool, error) {
+		if c.State == "running" || c.State == "paused" {
+			return false, nil
+		}
+		return true, client.ContainerStart(ctx, c.ID, types.ContainerStartOptions{})
+	})
+}
+
+// Stop stops the running containers of the services, stopping dependents first. The daemon
+// sends the stop_signal of the container and kills it after the stop_grace_period or Timeout
+func (s Stack) Stop(ctx context.Context, client Client, options LifecycleOptions) (Report, error) {
+	services, err := s.lifecycleServices(options)
+	if err != nil {
+		return Report{}, err
+	}
+	return s.runLifecycle(ctx, client, services, true, ActionStopped, func(ctx context.Context, c types.Container) (bool, error) {
+		if c.State != "running" && c.State != "paused" && c.State != "restarting" {
+			return false, nil
+		}
+		return true, client.ContainerStop(ctx, c.ID, s.stopTimeout(c.Labels[ServiceLabel], options.Timeout))
+	})
+}
+
+// Restart restarts every container of the services, whether running or not, with dependencies
+// restarted first
+func (s Stack) Restart(ctx context.Context, client Client, options LifecycleOptions) (Report, error) {
+	services, err := s.lifecycleServices(options)
+	if err != nil {
+		return Report{}, err
+	}
+	return s.runLifecycle(ctx, client, services, false, ActionRestarted, func(ctx context.Context, c types.Container) (bool, error) {
+		return true, client.ContainerRestart(ctx, c.ID, s.stopTimeout(c.Labels[ServiceLabel], options.Timeout))
+	})
+}
+
+// Pause pauses the running containers of the services, pausing dependents first
+func (s Stack) Pause(ctx context.Context, client Client, options LifecycleOptions) (Report, error) {
+	services, err := s.lifecycleServices(options)
+	if err != nil {
+		return Report{}, err
+	}
+	return s.runLifecycle(ctx, client, services, true, ActionPaused, func(ctx context.Context, c types.Container) (bool, error) {
+		if c.State != "running" {
+			return false, nil
+		}
+		return true, client.ContainerPause(ctx, c.ID)
+	})
+}
+
+// Unpause resumes the paused containers of the services, resuming dependencies first
+func (s Stack) Unpause(ctx context.Context, client Client, options LifecycleOptions) (Report, error) {
+	services, err := s.lifecycleServices(options)
+	if err != nil {
+		return Report{}, err
+	}
+	return s.runLifecycle(ctx, client, services, false, ActionUnpaused, func(ctx context.Context, c types.Container) (bool, error) {
+		if c.State != "paused" {
+			return false, nil
+		}
+		return true, client.ContainerUnpause(ctx, c.ID)
+	})
+}
+
+// Kill sends the signal to the running containers of the services, killing dependents first
+func (s Stack) Kill(ctx context.Context, client Client, options LifecycleOptions) (Report, error) {
+	services, err := s.lifecycleServices(options)
+	if err != nil {
+		return Report{}, err
+	}
+	signal := options.Signal
+	if signal == "" {
+		signal = "SIGKILL"
+	}
+	return s.runLifecycle(ctx, client, services, true, ActionKilled, func(ctx context.Context, c types.Container) (bool, error) {
+		if c.State != "running" {
+			return false, nil
+		}
+		return true, client.ContainerKill(ctx, c.ID, signal)
+	})
+}
+
+// lifecycleServices returns the services of the options, or every service if none are set
+func (s Stack) lifecycleServices(options LifecycleOptions) ([]string, error) {
+	if len(options.Services) == 0 {
+		return s.ServiceNames(), nil
+	}
+	for _, service := range options.Services {
+		if _, err := s.getService(service); err != nil {
+			return nil, err
+		}
+	}
+	return options.Services, nil
+}
+
+// runLifecycle applies the operation to the containers of the services one dependency level at a
+// time, in reverse if set. Services within a level are independent so their containers are changed
+// concurrently. When going forwards, services with a failed dependency are skipped. The report
+// is ordered by level, service and replica so it is the same whatever order the operations finish
+func (s Stack) runLifecycle(ctx context.Context, client Client, services []string, reverse bool, action Action, operation lifecycleOperation) (Report, error) {
+	levels := s.serviceLevels(services)
+	if reverse {
+		for i, j := 0, len(levels)-1; i < j; i, j = i+1, j-1 {
+			levels[i], levels[j] = levels[j], levels[i]
+		}
+	}
+
+	report := Report{}
+	failed := make(map[string]bool)
+	for _, level := range levels {
+		results := make([][]Result, len(level))
+		var wait sync.WaitGroup
+		for i, service := range level {
+			if !reverse && s.hasFailedDependency(service, failed) {
+				results[i] = []Result{{Kind: KindContainer, Name: service, Service: service, Action: ActionSkipped}}
+				continue
+			}
+			wait.Add(1)
+			go func(i int, service string) {
+				defer wait.Done()
+				results[i] = s.applyLifecycle(ctx, client, service, action, operation)
+			}(i, service)
+		}
+		wait.Wait()
+
+		for _, serviceResults := range results {
+			for _, result := range serviceResults {
+				report.Results = append(report.Results, result)
+				if result.Err != nil || result.Action == ActionSkipped {
+					failed[result.Service] = true
+				}
+			}
+		}
+	}
+
+	if failures := report.Failed(); len(failures) > 0 {
+		var names []string
+		for _, result := range failures {
+			names = append(names, result.Name)
+		}
+		return report, fmt.Errorf("failed to change %s: %w", strings.Join(names, ", "), failures[0].Err)
+	}
+	return report, nil
+}
+
+// applyLifecycle applies the operation to every replica of the service concurrently
+func (s Stack) applyLifecycle(ctx context.Context, client Client, service string, action Action, operation lifecycleOperation) []Result {
+	containers, err := s.findContainers(ctx, client, service)
+	if err != nil {
+		return []Result{{Kind: KindContainer, Name: service, Service: service, Action: ActionFailed, Err: fmt.Errorf("listing containers of %s: %w", service, err)}}
+	}
+
+	replicas := sortedReplicas(containers)
+	results := make([]Result, len(replicas))
+	var wait sync.WaitGroup
+	for i, replica := range replicas {
+		wait.Add(1)
+		go func(i int, c types.Container) {
+			defer wait.Done()
+			result := Result{Kind: KindContainer, Name: containerName(c), Service: service, Action: action}
+			changed, err := operation(ctx, c)
+			if !changed {
+				result.Action = ActionUnchanged
+			}
+			if err != nil {
+				result.Action, result.Err = ActionFailed, wrapError("container", result.Name, err)
+			}
+			results[i] = result
+		}(i, *containers[replica])
+	}
+	wait.Wait()
+	return results
+}
+
+// hasFailedDependency returns whether any service the service depends on has failed
+func (s Stack) hasFailedDependency(service string, failed map[string]bool) bool {
+	for _, dependency := range s.serviceDependencies(service) {
+		if failed[dependency] {
+			return true
+		}
+	}
+	return false
+}
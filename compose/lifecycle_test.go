@@ -0,0 +1,176 @@
+package compose_test
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/rmasp98/go-compose/compose"
+	"github.com/rmasp98/go-compose/compose/composetest"
+)
+
+func newRunningStack(t *testing.T) (compose.Stack, *composetest.FakeClient) {
+	client := newFakeClient()
+	stack := newUpStack(t)
+	if _, err := stack.Up(context.Background(), client, compose.UpOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	client.ClearCalls()
+	return stack, client
+}
+
+func reportActions(report compose.Report) map[string]compose.Action {
+	actions := make(map[string]compose.Action)
+	for _, result := range report.Results {
+		actions[result.Name] = result.Action
+	}
+	return actions
+}
+
+func TestStopStopsDependentsFirst(t *testing.T) {
+	stack, client := newRunningStack(t)
+	report, err := stack.Stop(context.Background(), client, compose.LifecycleOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"ContainerStop app-web-1 30s", "ContainerStop app-api-1", "ContainerStop app-db-1"}
+	if err := verifyValue(expected, client.Calls()); err != nil {
+		t.Error(err)
+	}
+	expectedActions := map[string]compose.Action{"app-web-1": compose.ActionStopped, "app-api-1": compose.ActionStopped, "app-db-1": compose.ActionStopped}
+	if err := verifyValue(expectedActions, reportActions(report)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestStartStartsDependenciesFirst(t *testing.T) {
+	stack, client := newRunningStack(t)
+	client.SetContainerState("app-web-1", "exited")
+	client.SetContainerState("app-db-1", "exited")
+
+	report, err := stack.Start(context.Background(), client, compose.LifecycleOptions{Services: []string{"web"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyValue([]string{"ContainerStart app-db-1", "ContainerStart app-web-1"}, client.Calls()); err != nil {
+		t.Error(err)
+	}
+	expected := map[string]compose.Action{"app-db-1": compose.ActionStarted, "app-api-1": compose.ActionUnchanged, "app-web-1": compose.ActionStarted}
+	if err := verifyValue(expected, reportActions(report)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestStartSkipsServicesWithFailedDependencies(t *testing.T) {
+	stack, client := newRunningStack(t)
+	stack.Stop(context.Background(), client, compose.LifecycleOptions{})
+	client.ClearCalls()
+	client.FailOn("ContainerStart", errors.New("port is already allocated"))
+
+	report, err := stack.Start(context.Background(), client, compose.LifecycleOptions{})
+	if err == nil {
+		t.Fatal("Expected error when a container fails to start")
+	}
+	if err := verifyValue([]string{"ContainerStart app-db-1"}, client.Calls()); err != nil {
+		t.Error(err)
+	}
+	expected := map[string]compose.Action{"app-db-1": compose.ActionFailed, "api": compose.ActionSkipped, "web": compose.ActionSkipped}
+	if err := verifyValue(expected, reportActions(report)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRestartUsesTimeoutOverride(t *testing.T) {
+	stack, client := newRunningStack(t)
+	timeout := 5 * time.Second
+	if _, err := stack.Restart(context.Background(), client, compose.LifecycleOptions{Services: []string{"web", "db"}, Timeout: &timeout}); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyValue([]string{"ContainerRestart app-db-1 5s", "ContainerRestart app-web-1 5s"}, client.Calls()); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPauseAndUnpauseFollowDependencyOrder(t *testing.T) {
+	stack, client := newRunningStack(t)
+	if _, err := stack.Pause(context.Background(), client, compose.LifecycleOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if c, _ := client.Container("app-api-1"); c.State != "paused" {
+		t.Errorf("Container should be paused but was %s", c.State)
+	}
+	if _, err := stack.Unpause(context.Background(), client, compose.LifecycleOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"ContainerPause app-web-1", "ContainerPause app-api-1", "ContainerPause app-db-1",
+		"ContainerUnpause app-db-1", "ContainerUnpause app-api-1", "ContainerUnpause app-web-1",
+	}
+	if err := verifyValue(expected, client.Calls()); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestKillOnlySignalsRunningContainers(t *testing.T) {
+	testData := map[string]struct {
+		signal   string
+		expected []string
+	}{
+		"default": {"", []string{"ContainerKill app-web-1 SIGKILL", "ContainerKill app-db-1 SIGKILL"}},
+		"signal":  {"SIGHUP", []string{"ContainerKill app-web-1 SIGHUP", "ContainerKill app-db-1 SIGHUP"}},
+	}
+
+	for name, data := range testData {
+		t.Run(name, func(t *testing.T) {
+			stack, client := newRunningStack(t)
+			client.SetContainerState("app-api-1", "exited")
+			report, err := stack.Kill(context.Background(), client, compose.LifecycleOptions{Signal: data.signal})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := verifyValue(data.expected, client.Calls()); err != nil {
+				t.Error(err)
+			}
+			if actions := reportActions(report); actions["app-api-1"] != compose.ActionUnchanged || actions["app-db-1"] != compose.ActionKilled {
+				t.Errorf("Unexpected actions: %v", actions)
+			}
+		})
+	}
+}
+
+func TestLifecycleChangesReplicasConcurrently(t *testing.T) {
+	client := newFakeClient()
+	stack, _ := newUpStack(t).Builder().Build(compose.WithScale("db", 3))
+	if _, err := stack.Up(context.Background(), client, compose.UpOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	client.ClearCalls()
+
+	report, err := stack.Stop(context.Background(), client, compose.LifecycleOptions{Services: []string{"db"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := client.Calls()
+	sort.Strings(calls)
+	if err := verifyValue([]string{"ContainerStop app-db-1", "ContainerStop app-db-2", "ContainerStop app-db-3"}, calls); err != nil {
+		t.Error(err)
+	}
+
+	var names []string
+	for _, result := range report.Results {
+		names = append(names, result.Name)
+	}
+	if err := verifyValue([]string{"app-db-1", "app-db-2", "app-db-3"}, names); err != nil {
+		t.Errorf("Report should be in replica order: %s", err)
+	}
+}
+
+func TestLifecycleRejectsUnknownService(t *testing.T) {
+	stack, client := newRunningStack(t)
+	if _, err := stack.Stop(context.Background(), client, compose.LifecycleOptions{Services: []string{"cache"}}); err == nil {
+		t.Error("Expected error for unknown service")
+	}
+}
@@ -0,0 +1,657 @@
+package compose
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/docker/cli/cli/compose/template"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultFiles are the compose files looked for in the project directory when no files are given
+var defaultFiles = []string{"compose.yaml", "compose.yml", "docker-compose.yaml", "docker-compose.yml"}
+
+// LoadOptions controls how the compose files of a project are found, merged and interpolated
+type LoadOptions struct {
+	// Files are merged in order with later files overriding earlier ones. Defaults to the
+	// first of compose.yaml, compose.yml, docker-compose.yaml and docker-compose.yml found
+	// in the project directory
+	Files []string
+	// ProjectDirectory is used to resolve relative paths and name the project. Defaults to
+	// the directory of the first file, or the working directory if no files are given
+	ProjectDirectory string
+	// ProjectName overrides the name in the compose files. If neither is set the name of the
+	// project directory is used
+	ProjectName string
+	// EnvFiles contain the variables used for interpolation. Defaults to .env in the project
+	// directory if it exists
+	EnvFiles []string
+	// Environment overrides the variables from EnvFiles. Defaults to the environment of the process
+	Environment map[string]string
+	// Profiles enables the services with a matching profile. Services without profiles are always enabled
+	Profiles []string
+}
+
+// Load reads, merges and interpolates the compose files, resolves the services that extend other
+// services and creates the Stack from the result. Relative bind mounts and secret files are
+// resolved against the project directory
+func Load(options LoadOptions, stackOptions ...StackOption) (Stack, error) {
+	config, projectName, err := LoadConfig(options)
+	if err != nil {
+		return Stack{}, err
+	}
+	if projectName != "" {
+		stackOptions = append([]StackOption{WithProjectName(projectName)}, stackOptions...)
+	}
+	return NewStack(config, stackOptions...)
+}
+
+// LoadConfig returns the merged and interpolated compose file that Load creates the Stack from and
+// the project name that should be used if the compose file does not set one. Problems in the files
+// are reported with their position, as Validate does
+func LoadConfig(options LoadOptions) (map[string]interface{}, string, error) {
+	loaded, err := loadFiles(options)
+	if err != nil {
+		return nil, "", err
+	}
+	if findings := (Findings{Issues: loaded.issues}); findings.HasErrors() {
+		return nil, "", errors.New(findings.String())
+	}
+	return loaded.config, finishConfig(loaded.config, options, loaded.directory), nil
+}
+
+// loadedFiles is the merged config of the compose files. The files are kept so issues found in the
+// config can be located in them
+type loadedFiles struct {
+	config    map[string]interface{}
+	directory string
+	files     []composeFile
+	// issues are the problems that stopped a file being read or merged. The config is incomplete
+	// if there are any
+	issues []ValidationIssue
+}
+
+// loadFiles reads, interpolates and merges the compose files and resolves extends. Every problem in
+// the files is gathered as a located issue. Errors that prevent the files being read at all, such
+// as a missing file, are returned as an error
+func loadFiles(options LoadOptions) (loadedFiles, error) {
+	paths, directory, err := resolveFiles(options.Files, options.ProjectDirectory)
+	if err != nil {
+		return loadedFiles{}, err
+	}
+	environment, err := loadEnvironment(options, directory)
+	if err != nil {
+		return loadedFiles{}, err
+	}
+
+	loaded := loadedFiles{config: map[string]interface{}{}, directory: directory}
+	for _, path := range paths {
+		file, err := readComposeFile(path)
+		if err != nil {
+			issue, isIssue := fileIssue(path, err)
+			if !isIssue {
+				return loadedFiles{}, err
+			}
+			loaded.issues = append(loaded.issues, issue)
+			continue
+		}
+		loaded.files = append(loaded.files, file)
+
+		config, err := interpolate(file.config, environment)
+		if err != nil {
+			loaded.issues = append(loaded.issues, locateIssue(configIssue(RuleInterpolation, err), []composeFile{file}))
+			continue
+		}
+		loaded.config = mergeConfig(loaded.config, config, nil).(map[string]interface{})
+	}
+	if len(loaded.issues) > 0 {
+		return loaded, nil
+	}
+	if err := resolveExtends(loaded.config, directory, environment); err != nil {
+		loaded.issues = append(loaded.issues, locateIssue(configIssue(RuleInvalidConfig, err), loaded.files))
+	}
+	return loaded, nil
+}
+
+// finishConfig applies the profiles and resolves the paths of the merged config. It returns the
+// project name that should be used if the config does not set one
+func finishConfig(config map[string]interface{}, options LoadOptions, directory string) string {
+	removeDisabledServices(config, options.Profiles)
+	resolvePaths(config, directory)
+
+	if _, hasName := config["name"]; options.ProjectName == "" && !hasName {
+		return projectNameFromDirectory(directory)
+	}
+	return options.ProjectName
+}
+
+// resolveFiles returns the absolute paths of the files and the project directory
+func resolveFiles(files []string, directory string) ([]string, string, error) {
+	if directory != "" {
+		var err error
+		if directory, err = filepath.Abs(directory); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if len(files) == 0 {
+		searchDirectory := directory
+		if searchDirectory == "" {
+			var err error
+			if searchDirectory, err = os.Getwd(); err != nil {
+				return nil, "", err
+			}
+		}
+		for _, name := range defaultFiles {
+			if _, err := os.Stat(filepath.Join(searchDirectory, name)); err == nil {
+				files = []string{filepath.Join(searchDirectory, name)}
+				break
+			}
+		}
+		if len(files) == 0 {
+			return nil, "", fmt.Errorf("no compose file found in %s", searchDirectory)
+		}
+	}
+
+	resolved := make([]string, 0, len(files))
+	for _, file := range files {
+		path, err := filepath.Abs(file)
+		if err != nil {
+			return nil, "", err
+		}
+		resolved = append(resolved, path)
+	}
+	if directory == "" {
+		directory = filepath.Dir(resolved[0])
+	}
+	return resolved, directory, nil
+}
+
+// loadEnvironment reads the env files and overrides them with the environment
+func loadEnvironment(options LoadOptions, directory string) (map[string]string, error) {
+	envFiles := options.EnvFiles
+	if len(envFiles) == 0 {
+		if _, err := os.Stat(filepath.Join(directory, ".env")); err == nil {
+			envFiles = []string{filepath.Join(directory, ".env")}
+		}
+	}
+
+	environment := make(map[string]string)
+	for _, file := range envFiles {
+		variables, err := ReadEnvFile(file)
+		if err != nil {
+			return nil, err
+		}
+		for name, value := range variables {
+			environment[name] = value
+		}
+	}
+
+	overrides := options.Environment
+	if overrides == nil {
+		overrides = make(map[string]string)
+		for _, variable := range os.Environ() {
+			parts := strings.SplitN(variable, "=", 2)
+			overrides[parts[0]] = parts[1]
+		}
+	}
+	for name, value := range overrides {
+		environment[name] = value
+	}
+	return environment, nil
+}
+
+// ReadEnvFile reads the KEY=VALUE lines of the file. Blank lines and lines starting with # are
+// ignored, an export prefix is allowed and values in single or double quotes are unquoted
+func ReadEnvFile(file string) (map[string]string, error) {
+	contents, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer contents.Close()
+
+	variables := make(map[string]string)
+	scanner := bufio.NewScanner(contents)
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(strings.TrimPrefix(line, "export "), "=", 2)
+		name := strings.TrimSpace(parts[0])
+		if len(parts) != 2 || !envNameRegex.MatchString(name) {
+			return nil, fmt.Errorf("%s:%d: invalid variable %q", file, number, line)
+		}
+		variables[name] = unquoteEnvValue(strings.TrimSpace(parts[1]))
+	}
+	return variables, scanner.Err()
+}
+
+var envNameRegex = regexp.MustCompile("^[A-Za-z_][A-Za-z0-9_]*$")
+
+// unquoteEnvValue removes matching quotes and, for unquoted values, any trailing comment
+func unquoteEnvValue(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		if value[0] == '"' {
+			return strings.NewReplacer(`\n`, "\n", `\"`, `"`, `\\`, `\`).Replace(value[1 : len(value)-1])
+		}
+		return value[1 : len(value)-1]
+	}
+	if index := strings.Index(value, " #"); index >= 0 {
+		value = strings.TrimSpace(value[:index])
+	}
+	return value
+}
+
+// composeFile is a parsed compose file. The node is kept so issues can be located in the file
+type composeFile struct {
+	path   string
+	node   *yaml.Node
+	config map[string]interface{}
+}
+
+// yamlError is returned by readComposeFile when the file is not valid YAML
+type yamlError struct {
+	err error
+}
+
+func (e yamlError) Error() string {
+	return e.err.Error()
+}
+
+// readComposeFile parses the file. A file that is not a map is returned as a ConfigError
+func readComposeFile(path string) (composeFile, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return composeFile{}, err
+	}
+	file := composeFile{path: path, node: &yaml.Node{}, config: map[string]interface{}{}}
+	if err := yaml.Unmarshal(contents, file.node); err != nil {
+		return composeFile{}, yamlError{err}
+	}
+	var data interface{}
+	if err := file.node.Decode(&data); err != nil {
+		return composeFile{}, yamlError{err}
+	}
+	if data == nil {
+		return file, nil
+	}
+	config, isMap := data.(map[string]interface{})
+	if !isMap {
+		return composeFile{}, ConfigError{nil, fmt.Errorf("compose file should be a map")}
+	}
+	file.config = config
+	return file, nil
+}
+
+// interpolate substitutes the variables in every string of the value. Errors are returned as a ConfigError
+// with the path of the string
+func interpolate(value interface{}, environment map[string]string) (interface{}, error) {
+	switch value := value.(type) {
+	case string:
+		return template.Substitute(value, func(name string) (string, bool) {
+			variable, exists := environment[name]
+			return variable, exists
+		})
+	case map[string]interface{}:
+		interpolated := make(map[string]interface{}, len(value))
+		for key, element := range value {
+			var err error
+			if interpolated[key], err = interpolate(element, environment); err != nil {
+				return nil, withPath(err, key)
+			}
+		}
+		return interpolated, nil
+	case []interface{}:
+		interpolated := make([]interface{}, len(value))
+		for i, element := range value {
+			var err error
+			if interpolated[i], err = interpolate(element, environment); err != nil {
+				return nil, withPath(err, strconv.Itoa(i))
+			}
+		}
+		return interpolated, nil
+	}
+	return value, nil
+}
+
+// appendedOptions are the service options whose lists are combined when merging rather than replaced
+var appendedOptions = map[string]bool{
+	"cap_add": true, "cap_drop": true, "devices": true, "dns": true, "dns_search": true, "expose": true,
+	"external_links": true, "ports": true, "secrets": true, "security_opt": true, "tmpfs": true, "volumes": true,
+}
+
+// keyedOptions are the service options that can be a list of KEY=VALUE or a map. They are merged by key
+var keyedOptions = map[string]bool{"environment": true, "labels": true, "extra_hosts": true, "sysctls": true}
+
+// mergeConfig merges the override into the base. Maps are merged by key and other values replaced,
+// except for the options of a service that combine lists or are merged by key. Path is the location
+// of the values in the compose file
+func mergeConfig(base, override interface{}, path []string) interface{} {
+	isServiceOption := len(path) == 3 && path[0] == "services"
+	if isServiceOption && keyedOptions[path[2]] {
+		_, baseIsList := base.([]interface{})
+		_, overrideIsList := override.([]interface{})
+		merged := mergeConfig(toKeyedMap(base), toKeyedMap(override), nil)
+		if baseIsList || overrideIsList {
+			separator := "="
+			if path[2] == "extra_hosts" {
+				separator = ":"
+			}
+			return fromKeyedMap(merged, separator)
+		}
+		return merged
+	}
+
+	baseMap, baseIsMap := base.(map[string]interface{})
+	overrideMap, overrideIsMap := override.(map[string]interface{})
+	if baseIsMap && overrideIsMap {
+		merged := make(map[string]interface{}, len(baseMap)+len(overrideMap))
+		for key, value := range baseMap {
+			merged[key] = value
+		}
+		for key, value := range overrideMap {
+			if existing, exists := merged[key]; exists {
+				merged[key] = mergeConfig(existing, value, append(append([]string{}, path...), key))
+			} else {
+				merged[key] = value
+			}
+		}
+		return merged
+	}
+
+	baseList, baseIsList := base.([]interface{})
+	overrideList, overrideIsList := override.([]interface{})
+	if isServiceOption && appendedOptions[path[2]] && baseIsList && overrideIsList {
+		merged := append([]interface{}{}, baseList...)
+		for _, value := range overrideList {
+			duplicate := false
+			for _, existing := range baseList {
+				duplicate = duplicate || fmt.Sprint(existing) == fmt.Sprint(value)
+			}
+			if !duplicate {
+				merged = append(merged, value)
+			}
+		}
+		return merged
+	}
+	return override
+}
+
+// toKeyedMap converts a list of KEY=VALUE or KEY:VALUE strings to a map. A bare KEY is kept with a nil
+// value so fromKeyedMap can write it back out as KEY. Other values are returned unchanged
+func toKeyedMap(value interface{}) interface{} {
+	list, isList := value.([]interface{})
+	if !isList {
+		return value
+	}
+	keyed := make(map[string]interface{}, len(list))
+	for _, element := range list {
+		entry := fmt.Sprint(element)
+		separator := strings.IndexAny(entry, "=:")
+		if separator < 0 {
+			keyed[entry] = nil
+			continue
+		}
+		keyed[entry[:separator]] = entry[separator+1:]
+	}
+	return keyed
+}
+
+// fromKeyedMap converts a map from toKeyedMap back to a list of KEY=VALUE strings, using the
+// separator between the key and value, in key order. Keys with a nil value are written as a bare KEY
+func fromKeyedMap(value interface{}, separator string) interface{} {
+	keyed, isMap := value.(map[string]interface{})
+	if !isMap {
+		return value
+	}
+	list := make([]interface{}, 0, len(keyed))
+	for _, key := range sortedKeys(keyed) {
+		if keyed[key] == nil {
+			list = append(list, key)
+		} else {
+			list = append(list, key+separator+fmt.Sprint(keyed[key]))
+		}
+	}
+	return list
+}
+
+// extendsResolver replaces the extends option of services with the options of the service they extend
+type extendsResolver struct {
+	environment map[string]string
+	// files are the services of the other compose files that have been read, by path
+	files map[string]map[string]interface{}
+}
+
+// resolveExtends merges each service that extends another over a copy of that service. The base
+// service can be in the same config or in another file, which is read relative to the directory
+// and interpolated with the environment. Relative bind mounts of a service from another file are
+// resolved against the directory of that file
+func resolveExtends(config map[string]interface{}, directory string, environment map[string]string) error {
+	services, isMap := config["services"].(map[string]interface{})
+	if !isMap {
+		return nil
+	}
+	resolver := extendsResolver{environment: environment, files: make(map[string]map[string]interface{})}
+	for _, name := range sortedKeys(services) {
+		service, err := resolver.resolve(services, name, "", directory, nil)
+		if err != nil {
+			return withPath(err, "services", name)
+		}
+		services[name] = service
+	}
+	return nil
+}
+
+// resolve returns the service with its extends option resolved. File is the path of the file the
+// services are from, or empty for the merged config. Seen are the services already being resolved,
+// as file:service, so cycles can be detected
+func (r extendsResolver) resolve(services map[string]interface{}, name, file, directory string, seen []string) (interface{}, error) {
+	service, isMap := services[name].(map[string]interface{})
+	if !isMap {
+		return services[name], nil
+	}
+	extends, hasExtends := service["extends"]
+	if !hasExtends {
+		return service, nil
+	}
+
+	var baseFile, baseName string
+	switch extends := extends.(type) {
+	case string:
+		baseName = extends
+	case map[string]interface{}:
+		baseName, _ = extends["service"].(string)
+		baseFile, _ = extends["file"].(string)
+	}
+	if baseName == "" {
+		return nil, withPath(fmt.Errorf("should be a service name or a map with service and file"), "extends")
+	}
+
+	baseServices, baseDirectory := services, directory
+	if baseFile != "" {
+		baseFile = resolvePath(baseFile, directory)
+		var err error
+		if baseServices, err = r.readServices(baseFile); err != nil {
+			return nil, withPath(err, "extends", "file")
+		}
+		baseDirectory = filepath.Dir(baseFile)
+	} else {
+		baseFile = file
+	}
+	if _, exists := baseServices[baseName]; !exists {
+		return nil, withPath(fmt.Errorf("extends undefined service %s", baseName), "extends", "service")
+	}
+
+	key := baseFile + ":" + baseName
+	for _, resolving := range seen {
+		if resolving == key {
+			return nil, withPath(fmt.Errorf("extends %s in a cycle", baseName), "extends")
+		}
+	}
+	base, err := r.resolve(baseServices, baseName, baseFile, baseDirectory, append(seen, key))
+	if err != nil {
+		return nil, err
+	}
+	base = copyTree(base)
+	if baseConfig, isMap := base.(map[string]interface{}); isMap && baseDirectory != directory {
+		resolveServicePaths(baseConfig, baseDirectory)
+	}
+
+	override := make(map[string]interface{}, len(service))
+	for option, value := range service {
+		if option != "extends" {
+			override[option] = value
+		}
+	}
+	return mergeConfig(base, override, []string{"services", name}), nil
+}
+
+// readServices returns the interpolated services of the compose file
+func (r extendsResolver) readServices(path string) (map[string]interface{}, error) {
+	if services, isRead := r.files[path]; isRead {
+		return services, nil
+	}
+	file, err := readComposeFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config, err := interpolate(file.config, r.environment)
+	if err != nil {
+		return nil, err
+	}
+	services, _ := config.(map[string]interface{})["services"].(map[string]interface{})
+	r.files[path] = services
+	return services, nil
+}
+
+// copyTree deep copies the maps and lists created when unmarshalling yaml
+func copyTree(input interface{}) interface{} {
+	switch input := input.(type) {
+	case map[string]interface{}:
+		output := make(map[string]interface{}, len(input))
+		for key, value := range input {
+			output[key] = copyTree(value)
+		}
+		return output
+	case []interface{}:
+		output := make([]interface{}, len(input))
+		for i, value := range input {
+			output[i] = copyTree(value)
+		}
+		return output
+	}
+	return input
+}
+
+// removeDisabledServices removes the services with profiles that are not enabled
+func removeDisabledServices(config map[string]interface{}, profiles []string) {
+	services, isMap := config["services"].(map[string]interface{})
+	if !isMap {
+		return
+	}
+	enabled := make(map[string]bool)
+	for _, profile := range profiles {
+		enabled[profile] = true
+	}
+
+	for name, service := range services {
+		serviceConfig, isMap := service.(map[string]interface{})
+		if !isMap {
+			continue
+		}
+		serviceProfiles, hasProfiles := serviceConfig["profiles"].([]interface{})
+		if !hasProfiles || len(serviceProfiles) == 0 {
+			continue
+		}
+		isEnabled := false
+		for _, profile := range serviceProfiles {
+			isEnabled = isEnabled || enabled[fmt.Sprint(profile)]
+		}
+		if !isEnabled {
+			delete(services, name)
+		}
+	}
+}
+
+// resolvePaths makes the sources of bind mounts and the files of secrets and configs absolute
+func resolvePaths(config map[string]interface{}, directory string) {
+	if services, isMap := config["services"].(map[string]interface{}); isMap {
+		for _, service := range services {
+			if serviceConfig, isMap := service.(map[string]interface{}); isMap {
+				resolveServicePaths(serviceConfig, directory)
+			}
+		}
+	}
+
+	for _, section := range []string{"secrets", "configs"} {
+		if files, isMap := config[section].(map[string]interface{}); isMap {
+			for _, file := range files {
+				if fileConfig, isMap := file.(map[string]interface{}); isMap {
+					if path, isString := fileConfig["file"].(string); isString {
+						fileConfig["file"] = resolvePath(path, directory)
+					}
+				}
+			}
+		}
+	}
+}
+
+// resolveServicePaths makes the sources of the bind mounts of the service absolute
+func resolveServicePaths(service map[string]interface{}, directory string) {
+	volumes, isList := service["volumes"].([]interface{})
+	if !isList {
+		return
+	}
+	for i, volume := range volumes {
+		switch volume := volume.(type) {
+		case string:
+			parts := strings.SplitN(volume, ":", 2)
+			if len(parts) == 2 && isRelativePath(parts[0]) {
+				volumes[i] = resolvePath(parts[0], directory) + ":" + parts[1]
+			}
+		case map[string]interface{}:
+			if source, isString := volume["source"].(string); isString && volume["type"] == "bind" {
+				volume["source"] = resolvePath(source, directory)
+			}
+		}
+	}
+}
+
+func isRelativePath(path string) bool {
+	return path == "." || path == "~" || strings.HasPrefix(path, "./") || strings.HasPrefix(path, "../") || strings.HasPrefix(path, "~/")
+}
+
+// resolvePath expands ~ to the home directory and joins relative paths to the directory
+func resolvePath(path, directory string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, strings.TrimPrefix(path, "~"))
+		}
+		return path
+	}
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(directory, path)
+}
+
+// projectNameFromDirectory removes the characters of the directory name that are not valid in a project name
+func projectNameFromDirectory(directory string) string {
+	name := strings.ToLower(filepath.Base(directory))
+	name = invalidProjectCharacters.ReplaceAllString(name, "")
+	name = strings.TrimLeft(name, "_-")
+	if name == "" {
+		return defaultProjectName
+	}
+	return name
+}
+
+var invalidProjectCharacters = regexp.MustCompile("[^a-z0-9_-]")
@@ -0,0 +1,275 @@
+package compose_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/rmasp98/go-compose/compose"
+	"gopkg.in/yaml.v3"
+)
+
+// writeProject creates the files in a temporary directory named My.App and returns its path
+func writeProject(t *testing.T, files map[string]string) string {
+	directory := filepath.Join(t.TempDir(), "My.App")
+	if err := os.Mkdir(directory, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, contents := range files {
+		if err := ioutil.WriteFile(filepath.Join(directory, name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return directory
+}
+
+const loadBase = `
+services:
+  web:
+    image: nginx:${TAG:-latest}
+    environment:
+      - MODE=production
+      - DEBUG=false
+      - HOST_VAR
+    ports:
+      - "8080:80"
+    volumes:
+      - ./html:/usr/share/nginx/html
+  debug:
+    image: busybox
+    profiles: [debug]
+secrets:
+  token:
+    file: ./token.txt
+`
+
+const loadOverride = `
+services:
+  web:
+    image: nginx:${TAG}
+    environment:
+      DEBUG: "true"
+    ports:
+      - "8443:443"
+`
+
+func TestLoadMergesFilesWithEnvironment(t *testing.T) {
+	directory := writeProject(t, map[string]string{
+		"compose.yaml":  loadBase,
+		"override.yaml": loadOverride,
+		".env":          "# comment\nTAG=\"1.21\"\nexport OTHER=value # trailing\n",
+	})
+
+	config, projectName, err := compose.LoadConfig(compose.LoadOptions{
+		Files:       []string{filepath.Join(directory, "compose.yaml"), filepath.Join(directory, "override.yaml")},
+		Environment: map[string]string{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if projectName != "myapp" {
+		t.Errorf("Project name should come from the directory but got %s", projectName)
+	}
+
+	web := config["services"].(map[string]interface{})["web"].(map[string]interface{})
+	if web["image"] != "nginx:1.21" {
+		t.Errorf("Image should be interpolated from the env file but got %v", web["image"])
+	}
+	if err := verifyValue([]interface{}{"DEBUG=true", "HOST_VAR", "MODE=production"}, web["environment"]); err != nil {
+		t.Error(err)
+	}
+	stack, err := compose.NewStack(config, compose.WithProjectName(projectName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	environment := stack.ToMap()["services"].(map[string]interface{})["web"].(map[string]interface{})["environment"]
+	if err := verifyValue([]string{"DEBUG=true", "HOST_VAR", "MODE=production"}, environment); err != nil {
+		t.Errorf("A bare key should be written back without a value: %s", err.Error())
+	}
+	if err := verifyValue([]interface{}{"8080:80", "8443:443"}, web["ports"]); err != nil {
+		t.Error(err)
+	}
+	if err := verifyValue([]interface{}{filepath.Join(directory, "html") + ":/usr/share/nginx/html"}, web["volumes"]); err != nil {
+		t.Error(err)
+	}
+	secret := config["secrets"].(map[string]interface{})["token"].(map[string]interface{})
+	if secret["file"] != filepath.Join(directory, "token.txt") {
+		t.Errorf("Secret file should be resolved but got %v", secret["file"])
+	}
+}
+
+func TestLoadEnvironmentOverridesEnvFile(t *testing.T) {
+	directory := writeProject(t, map[string]string{"compose.yaml": loadBase, "custom.env": "TAG=1.21"})
+	stack, err := compose.Load(compose.LoadOptions{
+		ProjectDirectory: directory,
+		EnvFiles:         []string{filepath.Join(directory, "custom.env")},
+		Environment:      map[string]string{"TAG": "1.22"},
+		ProjectName:      "custom",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stack.GetProjectName() != "custom" {
+		t.Errorf("Project name should be overridden but got %s", stack.GetProjectName())
+	}
+	if image := stack.ToMap()["services"].(map[string]interface{})["web"].(map[string]interface{})["image"]; image != "nginx:1.22" {
+		t.Errorf("Environment should override the env file but got %v", image)
+	}
+}
+
+func TestLoadEnablesServicesWithActiveProfiles(t *testing.T) {
+	directory := writeProject(t, map[string]string{"docker-compose.yml": loadBase})
+
+	testData := map[string]struct {
+		profiles []string
+		expected []string
+	}{
+		"default": {nil, []string{"web"}},
+		"debug":   {[]string{"debug"}, []string{"debug", "web"}},
+		"other":   {[]string{"other"}, []string{"web"}},
+	}
+
+	for name, data := range testData {
+		t.Run(name, func(t *testing.T) {
+			stack, err := compose.Load(compose.LoadOptions{ProjectDirectory: directory, Profiles: data.profiles, Environment: map[string]string{}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := verifyValue(data.expected, stack.ServiceNames()); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+const extendsCompose = `
+services:
+  base:
+    image: nginx:${TAG}
+    environment:
+      MODE: production
+    ports: ["80"]
+  web:
+    extends: base
+    environment:
+      DEBUG: "true"
+    ports: ["443"]
+  worker:
+    extends:
+      file: common/worker.yaml
+      service: worker
+    command: ["work", "--fast"]
+`
+
+const extendsCommon = `
+services:
+  queue:
+    image: worker:${TAG}
+    volumes: ["./data:/data"]
+  worker:
+    extends: queue
+    command: ["work"]
+    user: "1000"
+`
+
+func TestLoadResolvesExtendsAndMarshalsResult(t *testing.T) {
+	directory := writeProject(t, map[string]string{"compose.yaml": extendsCompose})
+	if err := os.Mkdir(filepath.Join(directory, "common"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(directory, "common", "worker.yaml"), []byte(extendsCommon), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	stack, err := compose.Load(compose.LoadOptions{ProjectDirectory: directory, Environment: map[string]string{"TAG": "1.21"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	services := stack.ToMap()["services"].(map[string]interface{})
+	web, worker := services["web"].(map[string]interface{}), services["worker"].(map[string]interface{})
+
+	testData := map[string]struct {
+		actual, expected interface{}
+	}{
+		"image":         {web["image"], "nginx:1.21"},
+		"environment":   {sortedStrings(web["environment"].([]string)), []string{"DEBUG=true", "MODE=production"}},
+		"ports":         {len(web["ports"].([]interface{})), 2},
+		"otherFile":     {worker["image"], "worker:1.21"},
+		"command":       {worker["command"], []string{"work", "--fast"}},
+		"nestedExtends": {worker["user"], "1000"},
+		"bindSource":    {worker["volumes"].([]interface{})[0].(map[string]interface{})["source"], filepath.Join(directory, "common", "data")},
+	}
+	for name, data := range testData {
+		t.Run(name, func(t *testing.T) {
+			if err := verifyValue(data.expected, data.actual); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+
+	first, err := yaml.Marshal(stack)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(first), "extends") {
+		t.Errorf("extends should be resolved before marshalling:\n%s", first)
+	}
+	reparsed, err := compose.NewStack(parseYaml(string(first)))
+	if err != nil {
+		t.Fatalf("%s\n%s", err.Error(), first)
+	}
+	if second, _ := yaml.Marshal(reparsed); string(first) != string(second) {
+		t.Errorf("Output changed after reparsing:\n%s\n---\n%s", first, second)
+	}
+}
+
+func sortedStrings(values []string) []string {
+	sorted := append([]string{}, values...)
+	sort.Strings(sorted)
+	return sorted
+}
+
+func TestLoadReportsErrors(t *testing.T) {
+	directory := writeProject(t, map[string]string{
+		"invalid.yaml":     "services: [",
+		"list.yaml":        "- web",
+		"missing.yaml":     "services:\n  web:\n    image: ${TAG?required}",
+		"invalid-env.yaml": "services: {}",
+		"bad.env":          "not a variable",
+		"cycle.yaml":       "services:\n  a:\n    extends: b\n  b:\n    extends: a",
+		"undefined.yaml":   "services:\n  web:\n    extends: base",
+		"extendsFile.yaml": "services:\n  web:\n    extends:\n      file: none.yaml\n      service: base",
+	})
+
+	testData := map[string]compose.LoadOptions{
+		"noFiles":        {ProjectDirectory: filepath.Join(directory, "..")},
+		"missingFile":    {Files: []string{filepath.Join(directory, "none.yaml")}},
+		"invalidYaml":    {Files: []string{filepath.Join(directory, "invalid.yaml")}},
+		"notMap":         {Files: []string{filepath.Join(directory, "list.yaml")}},
+		"requiredVar":    {Files: []string{filepath.Join(directory, "missing.yaml")}, Environment: map[string]string{}},
+		"invalidEnvFile": {Files: []string{filepath.Join(directory, "invalid-env.yaml")}, EnvFiles: []string{filepath.Join(directory, "bad.env")}},
+		"extendsCycle":   {Files: []string{filepath.Join(directory, "cycle.yaml")}, Environment: map[string]string{}},
+		"extendsMissing": {Files: []string{filepath.Join(directory, "undefined.yaml")}, Environment: map[string]string{}},
+		"extendsFile":    {Files: []string{filepath.Join(directory, "extendsFile.yaml")}, Environment: map[string]string{}},
+	}
+
+	for name, options := range testData {
+		t.Run(name, func(t *testing.T) {
+			if _, err := compose.Load(options); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+}
+
+func TestLoadReportsTheLocationOfErrors(t *testing.T) {
+	directory := writeProject(t, map[string]string{"compose.yaml": "services:\n  web:\n    image: ${TAG?required}"})
+	_, err := compose.Load(compose.LoadOptions{ProjectDirectory: directory, Environment: map[string]string{}})
+	expected := filepath.Join(directory, "compose.yaml") + ":3:5: error: services.web.image: "
+	if err == nil || !strings.HasPrefix(err.Error(), expected) {
+		t.Errorf("Expected error starting with %q but got %v", expected, err)
+	}
+}
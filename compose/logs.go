@@ -0,0 +1,261 @@
+package compose
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// LogOptions selects which logs are output by Logs
+type LogOptions struct {
+	// Services limits the logs to the services. All services are included when empty
+	Services []string
+	// Follow keeps streaming new output, including from containers that are created
+	// or recreated, until the context is cancelled. Containers that are removed while
+	// following are skipped and their replacements attached when they start
+	Follow bool
+	// Since only outputs logs after the timestamp (RFC3339) or relative time (e.g. 10m)
+	Since string
+	// Tail limits the number of lines output from the end of each container's log
+	Tail string
+	// Timestamps adds the time to the start of every line
+	Timestamps bool
+	// NoColor disables the colour of the prefixes
+	NoColor bool
+	// PollInterval is how often new containers are looked for when following, in case a start
+	// event is missed. Defaults to 1s
+	PollInterval time.Duration
+}
+
+// prefixColours are the ANSI colours assigned to services in name order
+var prefixColours = []string{"36", "33", "32", "35", "34", "96", "93", "92", "95", "94"}
+
+// Logs writes the logs of every container in the project to the writer. Each line is prefixed
+// with the service and replica number, e.g. "web-1 | ", and output from different containers is
+// only interleaved between complete lines
+func (s Stack) Logs(ctx context.Context, client Client, output io.Writer, options LogOptions) error {
+	for _, service := range options.Services {
+		if _, err := s.getService(service); err != nil {
+			return err
+		}
+	}
+	pollInterval := options.PollInterval
+	if pollInterval <= 0 {
+		pollInterval = time.Second
+	}
+
+	var started <-chan events.Message
+	if options.Follow {
+		eventsCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		filter := s.projectFilter()
+		filter.Add("type", events.ContainerEventType)
+		filter.Add("event", "start")
+		started, _ = client.Events(eventsCtx, types.EventsOptions{Filters: filter})
+	}
+
+	logWriter := &logWriter{output: output, width: s.prefixWidth(options.Services)}
+	attached := make(map[string]bool)
+	errs := make(chan error, 1)
+	var wg sync.WaitGroup
+	for {
+		containers, err := s.logContainers(ctx, client, options.Services)
+		if err != nil {
+			wg.Wait()
+			return err
+		}
+		for _, c := range containers {
+			if attached[c.ID] {
+				continue
+			}
+			attached[c.ID] = true
+			prefix := s.logPrefix(c, !options.NoColor)
+			wg.Add(1)
+			go func(id string) {
+				defer wg.Done()
+				err := streamLogs(ctx, client, id, logWriter.prefixed(prefix), options)
+				// A container removed while following is being recreated or removed by down
+				if options.Follow && isNotFound(err) {
+					return
+				}
+				if err != nil && ctx.Err() == nil {
+					select {
+					case errs <- fmt.Errorf("%s: %w", strings.TrimSpace(prefix), err):
+					default:
+					}
+				}
+			}(c.ID)
+		}
+
+		if !options.Follow {
+			wg.Wait()
+			select {
+			case err := <-errs:
+				return err
+			default:
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return nil
+		case err := <-errs:
+			wg.Wait()
+			return err
+		case _, isOpen := <-started:
+			if !isOpen {
+				started = nil
+			}
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// logContainers returns the containers of the services, or the whole project, in name order
+func (s Stack) logContainers(ctx context.Context, client Client, services []string) ([]types.Container, error) {
+	filter := s.projectFilter()
+	filter.Add("label", OneoffLabel+"=False")
+	containers, err := client.ContainerList(ctx, types.ContainerListOptions{All: true, Filters: filter})
+	if err != nil {
+		return nil, err
+	}
+
+	included := make(map[string]bool)
+	for _, service := range services {
+		included[service] = true
+	}
+	var selected []types.Container
+	for _, c := range containers {
+		if len(services) == 0 || included[c.Labels[ServiceLabel]] {
+			selected = append(selected, c)
+		}
+	}
+	sort.Slice(selected, func(i, j int) bool { return containerName(selected[i]) < containerName(selected[j]) })
+	return selected, nil
+}
+
+// streamLogs copies the logs of the container to the writer, separating stdout and stderr
+// if the container does not use a TTY
+func streamLogs(ctx context.Context, client Client, id string, writer *prefixWriter, options LogOptions) error {
+	inspect, err := client.ContainerInspect(ctx, id)
+	if err != nil {
+		return err
+	}
+	stream, err := client.ContainerLogs(ctx, id, types.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     options.Follow,
+		Since:      options.Since,
+		Tail:       options.Tail,
+		Timestamps: options.Timestamps,
+	})
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+	defer writer.Flush()
+
+	if inspect.Config != nil && inspect.Config.Tty {
+		_, err = io.Copy(writer, stream)
+	} else {
+		_, err = stdcopy.StdCopy(writer, writer, stream)
+	}
+	return err
+}
+
+// logPrefix returns the prefix for the container in the form service-replica
+func (s Stack) logPrefix(c types.Container, colour bool) string {
+	service := c.Labels[ServiceLabel]
+	prefix := service + "-" + c.Labels[ContainerNumberLabel]
+	if !colour {
+		return prefix
+	}
+	index := sort.SearchStrings(s.ServiceNames(), service)
+	return "\x1b[" + prefixColours[index%len(prefixColours)] + "m" + prefix + "\x1b[0m"
+}
+
+// prefixWidth returns the width of the longest prefix so the output lines up
+func (s Stack) prefixWidth(services []string) int {
+	if len(services) == 0 {
+		services = s.ServiceNames()
+	}
+	width := 0
+	for _, service := range services {
+		replicas := s.services[service].GetReplicas()
+		if length := len(fmt.Sprintf("%s-%d", service, replicas)); length > width {
+			width = length
+		}
+	}
+	return width
+}
+
+// logWriter writes complete lines from many containers to the output
+type logWriter struct {
+	mu     sync.Mutex
+	output io.Writer
+	width  int
+}
+
+func (l *logWriter) prefixed(prefix string) *prefixWriter {
+	return &prefixWriter{logWriter: l, prefix: prefix}
+}
+
+func (l *logWriter) writeLine(prefix string, line []byte) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	padding := l.width - len(stripColour(prefix))
+	if padding < 0 {
+		padding = 0
+	}
+	_, err := fmt.Fprintf(l.output, "%s%s | %s\n", prefix, strings.Repeat(" ", padding), line)
+	return err
+}
+
+// prefixWriter buffers partial lines so each line is written with the prefix of the container
+type prefixWriter struct {
+	logWriter *logWriter
+	prefix    string
+	buffer    []byte
+}
+
+func (p *prefixWriter) Write(data []byte) (int, error) {
+	p.buffer = append(p.buffer, data...)
+	for {
+		end := bytes.IndexByte(p.buffer, '\n')
+		if end < 0 {
+			return len(data), nil
+		}
+		line := bytes.TrimSuffix(p.buffer[:end], []byte("\r"))
+		if err := p.logWriter.writeLine(p.prefix, line); err != nil {
+			return 0, err
+		}
+		p.buffer = p.buffer[end+1:]
+	}
+}
+
+// Flush writes any remaining partial line
+func (p *prefixWriter) Flush() {
+	if len(p.buffer) > 0 {
+		p.logWriter.writeLine(p.prefix, p.buffer)
+		p.buffer = nil
+	}
+}
+
+func stripColour(prefix string) string {
+	if strings.HasPrefix(prefix, "\x1b[") {
+		prefix = prefix[strings.Index(prefix, "m")+1:]
+		prefix = strings.TrimSuffix(prefix, "\x1b[0m")
+	}
+	return prefix
+}
@@ -0,0 +1,215 @@
+package compose_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/rmasp98/go-compose/compose"
+	"github.com/rmasp98/go-compose/compose/composetest"
+)
+
+const logsCompose = `
+name: app
+services:
+  web:
+    image: nginx
+    scale: 2
+  db:
+    image: postgres
+`
+
+// syncBuffer is a bytes.Buffer that can be read while logs are being written
+type syncBuffer struct {
+	mu     sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (s *syncBuffer) Write(data []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.buffer.Write(data)
+}
+
+func (s *syncBuffer) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.buffer.String()
+}
+
+func newLogsStack(t *testing.T) (compose.Stack, *composetest.FakeClient) {
+	stack, err := compose.NewStack(parseYaml(logsCompose))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := newFakeClient()
+	if _, err := stack.Up(context.Background(), client, compose.UpOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	client.WriteLogs("app-web-1", false, "listening", "GET /")
+	client.WriteLogs("app-web-2", true, "warning: slow")
+	client.WriteLogs("app-db-1", false, "ready")
+	return stack, client
+}
+
+func sortedLines(output string) []string {
+	lines := strings.Split(strings.TrimSuffix(output, "\n"), "\n")
+	sort.Strings(lines)
+	return lines
+}
+
+func TestLogsArePrefixedWithServiceAndReplica(t *testing.T) {
+	stack, client := newLogsStack(t)
+	output := &syncBuffer{}
+	if err := stack.Logs(context.Background(), client, output, compose.LogOptions{NoColor: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"db-1  | ready",
+		"web-1 | GET /",
+		"web-1 | listening",
+		"web-2 | warning: slow",
+	}
+	if err := verifyValue(expected, sortedLines(output.String())); err != nil {
+		t.Error(err)
+	}
+	if strings.Index(output.String(), "listening") > strings.Index(output.String(), "GET /") {
+		t.Error("Lines from a container should stay in order")
+	}
+}
+
+func TestLogsCanBeLimitedToServicesAndTail(t *testing.T) {
+	stack, client := newLogsStack(t)
+	output := &syncBuffer{}
+	options := compose.LogOptions{Services: []string{"web"}, Tail: "1", NoColor: true}
+	if err := stack.Logs(context.Background(), client, output, options); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyValue([]string{"web-1 | GET /", "web-2 | warning: slow"}, sortedLines(output.String())); err != nil {
+		t.Error(err)
+	}
+
+	if err := stack.Logs(context.Background(), client, output, compose.LogOptions{Services: []string{"missing"}}); err == nil {
+		t.Error("Expected error for unknown service")
+	}
+}
+
+func TestLogsPrefixesAreColouredByService(t *testing.T) {
+	stack, client := newLogsStack(t)
+	output := &syncBuffer{}
+	if err := stack.Logs(context.Background(), client, output, compose.LogOptions{Services: []string{"db"}}); err != nil {
+		t.Fatal(err)
+	}
+	if output.String() != "\x1b[36mdb-1\x1b[0m | ready\n" {
+		t.Errorf("Unexpected coloured output %q", output.String())
+	}
+}
+
+func TestFollowingLogsReattachesToRecreatedContainers(t *testing.T) {
+	stack, client := newLogsStack(t)
+	output := &syncBuffer{}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		options := compose.LogOptions{Services: []string{"db"}, Follow: true, NoColor: true, PollInterval: time.Millisecond}
+		done <- stack.Logs(ctx, client, output, options)
+	}()
+
+	waitForOutput(t, output, "db-1 | ready\n")
+	client.WriteLogs("app-db-1", false, "checkpoint")
+	waitForOutput(t, output, "db-1 | checkpoint\n")
+
+	if _, err := stack.Up(context.Background(), client, compose.UpOptions{ForceRecreate: true}); err != nil {
+		t.Fatal(err)
+	}
+	client.WriteLogs("app-db-1", false, "restarted")
+	waitForOutput(t, output, "db-1 | restarted\n")
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(time.Second):
+		t.Error("Logs did not stop when the context was cancelled")
+	}
+}
+
+// notFoundError is how the docker client reports objects that do not exist
+type notFoundError struct {
+	error
+}
+
+func (notFoundError) NotFound() {}
+
+// removedContainerClient reports the first container inspected as not found, as if it was removed
+// between being listed and its logs being requested
+type removedContainerClient struct {
+	*composetest.FakeClient
+	once      sync.Once
+	inspected chan struct{}
+}
+
+func (c *removedContainerClient) ContainerInspect(ctx context.Context, id string) (types.ContainerJSON, error) {
+	removed := false
+	c.once.Do(func() {
+		removed = true
+		close(c.inspected)
+	})
+	if removed {
+		return types.ContainerJSON{}, notFoundError{fmt.Errorf("no such container: %s", id)}
+	}
+	return c.FakeClient.ContainerInspect(ctx, id)
+}
+
+func TestFollowingLogsSkipsRemovedContainersUntilTheyStart(t *testing.T) {
+	stack, fake := newLogsStack(t)
+	client := &removedContainerClient{FakeClient: fake, inspected: make(chan struct{})}
+	output := &syncBuffer{}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		// The poll interval is too long for the test so the replacement is found by its start event
+		options := compose.LogOptions{Services: []string{"db"}, Follow: true, NoColor: true, PollInterval: time.Hour}
+		done <- stack.Logs(ctx, client, output, options)
+	}()
+
+	<-client.inspected
+	if err := client.ContainerRemove(context.Background(), "app-db-1", types.ContainerRemoveOptions{Force: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stack.Up(context.Background(), client, compose.UpOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	client.WriteLogs("app-db-1", false, "recreated")
+	waitForOutput(t, output, "db-1 | recreated\n")
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(time.Second):
+		t.Error("Logs did not stop when the context was cancelled")
+	}
+}
+
+func waitForOutput(t *testing.T, output *syncBuffer, expected string) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !strings.Contains(output.String(), expected) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Output did not contain %q:\n%s", expected, output.String())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,368 @@
+package compose
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/docker/cli/cli/compose/types"
+	"github.com/docker/go-connections/nat"
+)
+
+// ToMap converts the Stack back into the structure of a compose file. Only what the
+// Stack understood is included, using the long syntax wherever the compose file has
+// one and with the defaults and project scoped names filled in. Maps are sorted by
+// key when marshalled to YAML or JSON so the output is stable
+func (s Stack) ToMap() map[string]interface{} {
+	config := map[string]interface{}{"name": s.projectName}
+
+	services := map[string]interface{}{}
+	s.RangeServices(func(name string, service Service) bool {
+		services[name] = service.toMap()
+		return true
+	})
+	config["services"] = services
+
+	if len(s.networks) > 0 {
+		networks := map[string]interface{}{}
+		s.RangeNetworks(func(name string, network Network) bool {
+			networks[name] = network.toMap(s.GetNetworkName(name))
+			return true
+		})
+		config["networks"] = networks
+	}
+
+	if len(s.volumes) > 0 {
+		volumes := map[string]interface{}{}
+		s.RangeVolumes(func(name string, volume Volume) bool {
+			volumes[name] = volume.toMap(s.GetVolumeName(name))
+			return true
+		})
+		config["volumes"] = volumes
+	}
+
+	if len(s.secrets) > 0 {
+		secrets := map[string]interface{}{}
+		for name, secret := range s.secrets {
+			secrets[name] = secret.toMap()
+		}
+		config["secrets"] = secrets
+	}
+
+	return config
+}
+
+// MarshalYAML implements yaml.Marshaler
+func (s Stack) MarshalYAML() (interface{}, error) {
+	return s.ToMap(), nil
+}
+
+// MarshalJSON implements json.Marshaler
+func (s Stack) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.ToMap())
+}
+
+func (s Service) toMap() map[string]interface{} {
+	config := map[string]interface{}{}
+	cc, hc := s.containerConfig, s.hostConfig
+
+	setIfNotEmpty(config, "hostname", cc.Hostname)
+	setIfNotEmpty(config, "domainname", cc.Domainname)
+	setIfNotEmpty(config, "user", cc.User)
+	setIfNotEmpty(config, "image", cc.Image)
+	setIfNotEmpty(config, "working_dir", cc.WorkingDir)
+	setIfNotEmpty(config, "mac_address", cc.MacAddress)
+	setIfNotEmpty(config, "stop_signal", cc.StopSignal)
+	setIfNotEmpty(config, "tty", cc.Tty)
+	setIfNotEmpty(config, "stdin_open", cc.OpenStdin)
+	setIfNotEmpty(config, "labels", cc.Labels)
+	if cc.Cmd != nil {
+		config["command"] = []string(cc.Cmd)
+	}
+	if cc.Entrypoint != nil {
+		config["entrypoint"] = []string(cc.Entrypoint)
+	}
+	// The list form is kept as KEY and KEY= have different meanings
+	setIfNotEmpty(config, "environment", cc.Env)
+	if len(cc.ExposedPorts) > 0 {
+		expose := []string{}
+		for port := range cc.ExposedPorts {
+			expose = append(expose, string(port))
+		}
+		sort.Strings(expose)
+		config["expose"] = expose
+	}
+	if cc.StopTimeout != nil {
+		config["stop_grace_period"] = (time.Duration(*cc.StopTimeout) * time.Second).String()
+	}
+	if healthcheck := healthCheckToMap(s); len(healthcheck) > 0 {
+		config["healthcheck"] = healthcheck
+	}
+	if len(s.volumes) > 0 {
+		volumes := []interface{}{}
+		for _, volume := range s.volumes {
+			volumes = append(volumes, volumeToMap(volume))
+		}
+		config["volumes"] = volumes
+	}
+
+	if hc.LogConfig.Type != "" || len(hc.LogConfig.Config) > 0 {
+		logging := map[string]interface{}{}
+		setIfNotEmpty(logging, "driver", hc.LogConfig.Type)
+		setIfNotEmpty(logging, "options", hc.LogConfig.Config)
+		config["logging"] = logging
+	}
+	setIfNotEmpty(config, "network_mode", string(hc.NetworkMode))
+	if len(hc.PortBindings) > 0 {
+		config["ports"] = portsToList(hc.PortBindings)
+	}
+	if hc.RestartPolicy.Name != "" {
+		restart := hc.RestartPolicy.Name
+		if hc.RestartPolicy.MaximumRetryCount > 0 {
+			restart += ":" + strconv.Itoa(hc.RestartPolicy.MaximumRetryCount)
+		}
+		config["restart"] = restart
+	}
+	setIfNotEmpty(config, "cap_add", []string(hc.CapAdd))
+	setIfNotEmpty(config, "cap_drop", []string(hc.CapDrop))
+	setIfNotEmpty(config, "dns", hc.DNS)
+	setIfNotEmpty(config, "dns_search", hc.DNSSearch)
+	setIfNotEmpty(config, "extra_hosts", hc.ExtraHosts)
+	setIfNotEmpty(config, "ipc", string(hc.IpcMode))
+	setIfNotEmpty(config, "pid", string(hc.PidMode))
+	setIfNotEmpty(config, "external_links", hc.Links)
+	setIfNotEmpty(config, "privileged", hc.Privileged)
+	setIfNotEmpty(config, "read_only", hc.ReadonlyRootfs)
+	setIfNotEmpty(config, "security_opt", hc.SecurityOpt)
+	setIfNotEmpty(config, "userns_mode", string(hc.UsernsMode))
+	if hc.ShmSize != 0 {
+		config["shm_size"] = strconv.FormatInt(hc.ShmSize, 10)
+	}
+	setIfNotEmpty(config, "sysctls", hc.Sysctls)
+	setIfNotEmpty(config, "cgroup_parent", hc.CgroupParent)
+	if hc.Init != nil && *hc.Init {
+		config["init"] = true
+	}
+	if len(hc.Tmpfs) > 0 {
+		tmpfs := []string{}
+		for path, options := range hc.Tmpfs {
+			if options != "" {
+				path += ":" + options
+			}
+			tmpfs = append(tmpfs, path)
+		}
+		sort.Strings(tmpfs)
+		config["tmpfs"] = tmpfs
+	}
+	if len(hc.Devices) > 0 {
+		devices := []string{}
+		for _, device := range hc.Devices {
+			devices = append(devices, device.PathOnHost+":"+device.PathInContainer+":"+device.CgroupPermissions)
+		}
+		config["devices"] = devices
+	}
+	if len(hc.Ulimits) > 0 {
+		ulimits := map[string]interface{}{}
+		for _, ulimit := range hc.Ulimits {
+			ulimits[ulimit.Name] = map[string]interface{}{"soft": ulimit.Soft, "hard": ulimit.Hard}
+		}
+		config["ulimits"] = ulimits
+	}
+
+	if len(s.networkConfig.EndpointsConfig) > 0 {
+		networks := map[string]interface{}{}
+		for name, endpoint := range s.networkConfig.EndpointsConfig {
+			network := map[string]interface{}{}
+			setIfNotEmpty(network, "aliases", endpoint.Aliases)
+			setIfNotEmpty(network, "ipv4_address", endpoint.IPAddress)
+			setIfNotEmpty(network, "ipv6_address", endpoint.GlobalIPv6Address)
+			if len(network) > 0 {
+				networks[name] = network
+			} else {
+				networks[name] = nil
+			}
+		}
+		config["networks"] = networks
+	}
+
+	if s.platform.OS != "" {
+		config["platform"] = FormatPlatform(s.platform)
+	}
+	setIfNotEmpty(config, "container_name", s.containerName)
+	if s.replicas != 1 {
+		config["scale"] = s.replicas
+	}
+	setIfNotEmpty(config, "links", s.links)
+	if len(s.dependsOn) > 0 {
+		dependsOn := map[string]interface{}{}
+		for name, condition := range s.dependsOn {
+			dependsOn[name] = map[string]interface{}{"condition": condition}
+		}
+		config["depends_on"] = dependsOn
+	}
+	if len(s.secrets) > 0 {
+		secrets := []interface{}{}
+		for _, secret := range s.secrets {
+			secrets = append(secrets, secretReferenceToMap(secret))
+		}
+		config["secrets"] = secrets
+	}
+
+	return config
+}
+
+func (n Network) toMap(name string) map[string]interface{} {
+	config := map[string]interface{}{"name": name}
+	if n.external {
+		config["external"] = true
+		return config
+	}
+
+	config["driver"] = n.data.Driver
+	setIfNotEmpty(config, "driver_opts", n.data.Options)
+	setIfNotEmpty(config, "attachable", n.data.Attachable)
+	setIfNotEmpty(config, "enable_ipv6", n.data.EnableIPv6)
+	setIfNotEmpty(config, "internal", n.data.Internal)
+	setIfNotEmpty(config, "labels", n.data.Labels)
+	if n.data.IPAM != nil && (n.data.IPAM.Driver != "" || len(n.data.IPAM.Config) > 0 || len(n.data.IPAM.Options) > 0) {
+		ipam := map[string]interface{}{}
+		setIfNotEmpty(ipam, "driver", n.data.IPAM.Driver)
+		setIfNotEmpty(ipam, "options", n.data.IPAM.Options)
+		if len(n.data.IPAM.Config) > 0 {
+			pools := []interface{}{}
+			for _, pool := range n.data.IPAM.Config {
+				poolConfig := map[string]interface{}{}
+				setIfNotEmpty(poolConfig, "subnet", pool.Subnet)
+				setIfNotEmpty(poolConfig, "gateway", pool.Gateway)
+				setIfNotEmpty(poolConfig, "ip_range", pool.IPRange)
+				setIfNotEmpty(poolConfig, "aux_addresses", pool.AuxAddress)
+				pools = append(pools, poolConfig)
+			}
+			ipam["config"] = pools
+		}
+		config["ipam"] = ipam
+	}
+	return config
+}
+
+func (v Volume) toMap(name string) map[string]interface{} {
+	config := map[string]interface{}{"name": name}
+	if v.external {
+		config["external"] = true
+		return config
+	}
+	setIfNotEmpty(config, "driver", v.data.Driver)
+	setIfNotEmpty(config, "driver_opts", v.data.DriverOpts)
+	setIfNotEmpty(config, "labels", v.data.Labels)
+	return config
+}
+
+func (s Secret) toMap() map[string]interface{} {
+	config := map[string]interface{}{}
+	setIfNotEmpty(config, "file", s.file)
+	setIfNotEmpty(config, "environment", s.environment)
+	setIfNotEmpty(config, "external", s.external)
+	setIfNotEmpty(config, "name", s.name)
+	return config
+}
+
+func healthCheckToMap(s Service) map[string]interface{} {
+	config := map[string]interface{}{}
+	if hc := s.containerConfig.Healthcheck; hc != nil {
+		setIfNotEmpty(config, "test", hc.Test)
+		setIfNotEmpty(config, "retries", hc.Retries)
+		for name, duration := range map[string]time.Duration{"interval": hc.Interval, "timeout": hc.Timeout, "start_period": hc.StartPeriod} {
+			if duration != 0 {
+				config[name] = duration.String()
+			}
+		}
+	}
+	return config
+}
+
+func volumeToMap(volume types.ServiceVolumeConfig) map[string]interface{} {
+	config := map[string]interface{}{"type": volume.Type}
+	setIfNotEmpty(config, "source", volume.Source)
+	setIfNotEmpty(config, "target", volume.Target)
+	setIfNotEmpty(config, "read_only", volume.ReadOnly)
+	if volume.Bind != nil && volume.Bind.Propagation != "" {
+		config["bind"] = map[string]interface{}{"propagation": volume.Bind.Propagation}
+	}
+	if volume.Volume != nil && volume.Volume.NoCopy {
+		config["volume"] = map[string]interface{}{"nocopy": true}
+	}
+	if volume.Tmpfs != nil && volume.Tmpfs.Size != 0 {
+		config["tmpfs"] = map[string]interface{}{"size": volume.Tmpfs.Size}
+	}
+	return config
+}
+
+func secretReferenceToMap(secret types.ServiceSecretConfig) map[string]interface{} {
+	config := map[string]interface{}{"source": secret.Source}
+	setIfNotEmpty(config, "target", secret.Target)
+	setIfNotEmpty(config, "uid", secret.UID)
+	setIfNotEmpty(config, "gid", secret.GID)
+	if secret.Mode != nil {
+		config["mode"] = *secret.Mode
+	}
+	return config
+}
+
+func portsToList(bindings nat.PortMap) []interface{} {
+	ports := []interface{}{}
+	for _, port := range sortedPorts(bindings) {
+		for _, binding := range bindings[port] {
+			config := map[string]interface{}{"target": port.Int(), "protocol": port.Proto()}
+			setIfNotEmpty(config, "published", binding.HostPort)
+			setIfNotEmpty(config, "host_ip", binding.HostIP)
+			ports = append(ports, config)
+		}
+	}
+	return ports
+}
+
+func sortedPorts(bindings nat.PortMap) []nat.Port {
+	ports := []nat.Port{}
+	for port := range bindings {
+		ports = append(ports, port)
+	}
+	sort.Slice(ports, func(i, j int) bool {
+		if ports[i].Int() != ports[j].Int() {
+			return ports[i].Int() < ports[j].Int()
+		}
+		return ports[i].Proto() < ports[j].Proto()
+	})
+	return ports
+}
+
+// setIfNotEmpty only adds the value if it is not the zero value of its type
+func setIfNotEmpty(config map[string]interface{}, name string, value interface{}) {
+	switch value := value.(type) {
+	case string:
+		if value == "" {
+			return
+		}
+	case bool:
+		if !value {
+			return
+		}
+	case int:
+		if value == 0 {
+			return
+		}
+	case int64:
+		if value == 0 {
+			return
+		}
+	case []string:
+		if len(value) == 0 {
+			return
+		}
+	case map[string]string:
+		if len(value) == 0 {
+			return
+		}
+	}
+	config[name] = value
+}
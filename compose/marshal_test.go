@@ -0,0 +1,103 @@
+package compose_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/rmasp98/go-compose/compose"
+	"gopkg.in/yaml.v3"
+)
+
+func TestMarshalledStackCanBeParsedToSameStack(t *testing.T) {
+	composeFile, _ := ioutil.ReadFile("../test_data/compose.yaml")
+	stack, err := compose.NewStack(parseYaml(string(composeFile)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := yaml.Marshal(stack)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reparsed, err := compose.NewStack(parseYaml(string(first)))
+	if err != nil {
+		t.Fatalf("%s\n%s", err.Error(), first)
+	}
+	second, _ := yaml.Marshal(reparsed)
+	if string(first) != string(second) {
+		t.Errorf("Output changed after reparsing:\n%s\n---\n%s", first, second)
+	}
+}
+
+func TestMarshalUsesLongSyntaxAndResolvedValues(t *testing.T) {
+	stack, err := compose.NewStack(parseYaml(`
+name: app
+services:
+  web:
+    image: nginx
+    command: nginx -g "daemon off;"
+    ports: ["127.0.0.1:8080:80"]
+    volumes: ["data:/data:ro"]
+    environment: [DEBUG=1]
+    depends_on: [db]
+  db:
+    image: postgres
+volumes:
+  data:
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var config map[string]interface{}
+	data, _ := json.Marshal(stack)
+	if err := json.Unmarshal(data, &config); err != nil {
+		t.Fatal(err)
+	}
+	web := config["services"].(map[string]interface{})["web"].(map[string]interface{})
+
+	expected := map[string]interface{}{
+		"command":     []interface{}{"nginx", "-g", "daemon off;"},
+		"ports":       []interface{}{map[string]interface{}{"target": float64(80), "published": "8080", "host_ip": "127.0.0.1", "protocol": "tcp"}},
+		"volumes":     []interface{}{map[string]interface{}{"type": "volume", "source": "data", "target": "/data", "read_only": true}},
+		"environment": []interface{}{"DEBUG=1"},
+		"depends_on":  map[string]interface{}{"db": map[string]interface{}{"condition": "service_started"}},
+	}
+	for name, value := range expected {
+		if err := verifyValue(value, web[name]); err != nil {
+			t.Errorf("%s: %s", name, err.Error())
+		}
+	}
+
+	volumeName := config["volumes"].(map[string]interface{})["data"].(map[string]interface{})["name"]
+	if volumeName != "app_data" {
+		t.Errorf("Volume name should be \"app_data\" but got \"%v\"", volumeName)
+	}
+}
+
+func TestMarshalledKeysAreSorted(t *testing.T) {
+	stack, _ := compose.NewStack(parseYaml("services:\n  b:\n    image: b\n  a:\n    image: a"))
+	data, _ := yaml.Marshal(stack)
+	if strings.Index(string(data), "  a:") > strings.Index(string(data), "  b:") {
+		t.Errorf("Services were not sorted:\n%s", data)
+	}
+}
+
+func TestCanParseLongSyntaxPorts(t *testing.T) {
+	service, err := compose.NewService(map[string]interface{}{"ports": []interface{}{
+		map[string]interface{}{"target": 80, "published": "8080", "host_ip": "127.0.0.1", "protocol": "udp"},
+		map[string]interface{}{"target": 443},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bindings := service.GetHostConfig().PortBindings
+	if binding := bindings["80/udp"]; len(binding) != 1 || binding[0].HostPort != "8080" || binding[0].HostIP != "127.0.0.1" {
+		t.Errorf("80/udp was not parsed correctly: %v", bindings)
+	}
+	if _, isSet := bindings["443/tcp"]; !isSet {
+		t.Errorf("443/tcp was not parsed: %v", bindings)
+	}
+}
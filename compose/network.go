@@ -2,7 +2,7 @@ package compose
 
 import (
 	"fmt"
-	"regexp"
+	"net"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/network"
@@ -76,10 +76,10 @@ func validateNetworkDriver(input interface{}) error {
 func convertIPAM(input interface{}) (interface{}, error) {
 	if config, isMap := input.(map[string]interface{}); isMap {
 		ipam := network.IPAM{}
-		// TODO: add validation functions
 		mapping := []setValueMapping{
 			{"driver", &ipam.Driver, nil, nil},
 			{"config", &ipam.Config, convertIPAMConfig, nil},
+			{"options", &ipam.Options, convertToStringMap, nil},
 		}
 		if err := setValues(mapping, config); err != nil {
 			return nil, err
@@ -89,30 +89,16 @@ func convertIPAM(input interface{}) (interface{}, error) {
 	return nil, fmt.Errorf("ipam should be a map")
 }
 
-var ipv4Cidr = "^(([0-9]|[1-9][0-9]|1[0-9]{2}|2[0-4][0-9]|25[0-5])\\.){3}([0-9]|[1-9][0-9]|1[0-9]{2}|2[0-4][0-9]|25[0-5])(\\/(3[0-2]|[1-2][0-9]|[0-9]))$"
-var ipv6Cidr = "^s*((([0-9A-Fa-f]{1,4}:){7}([0-9A-Fa-f]{1,4}|:))|(([0-9A-Fa-f]{1,4}:){6}(:[0-9A-Fa-f]{1,4}|((25[0-5]|2[0-4]d|1dd|[1-9]?d)(.(25[0-5]|2[0-4]d|1dd|[1-9]?d)){3})|:))|(([0-9A-Fa-f]{1,4}:){5}(((:[0-9A-Fa-f]{1,4}){1,2})|:((25[0-5]|2[0-4]d|1dd|[1-9]?d)(.(25[0-5]|2[0-4]d|1dd|[1-9]?d)){3})|:))|(([0-9A-Fa-f]{1,4}:){4}(((:[0-9A-Fa-f]{1,4}){1,3})|((:[0-9A-Fa-f]{1,4})?:((25[0-5]|2[0-4]d|1dd|[1-9]?d)(.(25[0-5]|2[0-4]d|1dd|[1-9]?d)){3}))|:))|(([0-9A-Fa-f]{1,4}:){3}(((:[0-9A-Fa-f]{1,4}){1,4})|((:[0-9A-Fa-f]{1,4}){0,2}:((25[0-5]|2[0-4]d|1dd|[1-9]?d)(.(25[0-5]|2[0-4]d|1dd|[1-9]?d)){3}))|:))|(([0-9A-Fa-f]{1,4}:){2}(((:[0-9A-Fa-f]{1,4}){1,5})|((:[0-9A-Fa-f]{1,4}){0,3}:((25[0-5]|2[0-4]d|1dd|[1-9]?d)(.(25[0-5]|2[0-4]d|1dd|[1-9]?d)){3}))|:))|(([0-9A-Fa-f]{1,4}:){1}(((:[0-9A-Fa-f]{1,4}){1,6})|((:[0-9A-Fa-f]{1,4}){0,4}:((25[0-5]|2[0-4]d|1dd|[1-9]?d)(.(25[0-5]|2[0-4]d|1dd|[1-9]?d)){3}))|:))|(:(((:[0-9A-Fa-f]{1,4}){1,7})|((:[0-9A-Fa-f]{1,4}){0,5}:((25[0-5]|2[0-4]d|1dd|[1-9]?d)(.(25[0-5]|2[0-4]d|1dd|[1-9]?d)){3}))|:)))(%.+)?s*"
-
 func convertIPAMConfig(input interface{}) (interface{}, error) {
 	if config, isList := input.([]interface{}); isList {
 		ipamConfig := []network.IPAMConfig{}
 		for _, element := range config {
 			if element, isMap := element.(map[string]interface{}); isMap {
-				// subnet should be only element so any more if incorrect
-				if len(element) > 1 {
-					return nil, fmt.Errorf("contained invalid element")
-				}
-				if subnet, isSet := element["subnet"]; isSet {
-					subnet, err := getString(subnet)
-					if err != nil {
-						return nil, fmt.Errorf("subnet: %s", err.Error())
-					}
-					if match, _ := regexp.MatchString(ipv4Cidr+"|"+ipv6Cidr, subnet); !match {
-						return nil, fmt.Errorf("subnet was not valid")
-					}
-					ipamConfig = append(ipamConfig, network.IPAMConfig{Subnet: subnet})
-				} else {
-					return nil, fmt.Errorf("did not contain subnet")
+				pool, err := parseIPAMPool(element)
+				if err != nil {
+					return nil, err
 				}
+				ipamConfig = append(ipamConfig, pool)
 			} else {
 				return nil, fmt.Errorf("should be a map[string]string")
 			}
@@ -122,6 +108,83 @@ func convertIPAMConfig(input interface{}) (interface{}, error) {
 	return nil, fmt.Errorf("config should be a list")
 }
 
+func parseIPAMPool(config map[string]interface{}) (network.IPAMConfig, error) {
+	for key := range config {
+		switch key {
+		case "subnet", "gateway", "ip_range", "aux_addresses":
+		default:
+			return network.IPAMConfig{}, fmt.Errorf("contained invalid element %s", key)
+		}
+	}
+
+	pool := network.IPAMConfig{}
+	mapping := []setValueMapping{
+		{"subnet", &pool.Subnet, nil, validateCIDR},
+		{"gateway", &pool.Gateway, nil, validateIP},
+		{"ip_range", &pool.IPRange, nil, validateCIDR},
+		{"aux_addresses", &pool.AuxAddress, convertToStringMap, nil},
+	}
+	if err := setValues(mapping, config); err != nil {
+		return pool, err
+	}
+
+	if pool.Subnet == "" {
+		return pool, fmt.Errorf("did not contain subnet")
+	}
+	if err := verifyIPAMPool(pool); err != nil {
+		return pool, fmt.Errorf("subnet %s: %s", pool.Subnet, err.Error())
+	}
+	return pool, nil
+}
+
+// verifyIPAMPool ensures the gateway, ip range and auxiliary addresses of the pool are all inside the subnet
+func verifyIPAMPool(pool network.IPAMConfig) error {
+	_, subnet, _ := net.ParseCIDR(pool.Subnet)
+	if pool.Gateway != "" && !subnet.Contains(net.ParseIP(pool.Gateway)) {
+		return fmt.Errorf("gateway %s is not in the subnet", pool.Gateway)
+	}
+
+	if pool.IPRange != "" {
+		_, ipRange, _ := net.ParseCIDR(pool.IPRange)
+		rangeSize, _ := ipRange.Mask.Size()
+		subnetSize, _ := subnet.Mask.Size()
+		if !subnet.Contains(ipRange.IP) || rangeSize < subnetSize || len(ipRange.IP) != len(subnet.IP) {
+			return fmt.Errorf("ip_range %s is not in the subnet", pool.IPRange)
+		}
+	}
+
+	for name, address := range pool.AuxAddress {
+		ip := net.ParseIP(address)
+		if ip == nil {
+			return fmt.Errorf("aux_addresses: %s is not a valid IP address", name)
+		}
+		if !subnet.Contains(ip) {
+			return fmt.Errorf("aux_addresses: %s (%s) is not in the subnet", name, address)
+		}
+	}
+	return nil
+}
+
+func validateCIDR(input interface{}) error {
+	if cidr, isStr := input.(string); isStr {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return fmt.Errorf("%s is not a valid CIDR", cidr)
+		}
+		return nil
+	}
+	return fmt.Errorf("CIDR should be a string")
+}
+
+func validateIP(input interface{}) error {
+	if ip, isStr := input.(string); isStr {
+		if net.ParseIP(ip) == nil {
+			return fmt.Errorf("%s is not a valid IP address", ip)
+		}
+		return nil
+	}
+	return fmt.Errorf("IP address should be a string")
+}
+
 func getDefaultNetwork() types.NetworkCreate {
 	return types.NetworkCreate{
 		Driver:     "bridge",
@@ -80,8 +80,23 @@ func TestReturnsErrorForInvalidData(t *testing.T) {
 
 // Test data and helper functions
 var (
-	sourceIPAM   = map[string]interface{}{"driver": "test", "config": []interface{}{map[string]interface{}{"subnet": "172.28.0.0/16"}}}
-	expectedIPAM = network.IPAM{Driver: "test", Config: []network.IPAMConfig{{Subnet: "172.28.0.0/16"}}}
+	sourceIPAM = map[string]interface{}{
+		"driver":  "test",
+		"options": map[string]interface{}{"foo": "bar", "baz": 1},
+		"config": []interface{}{
+			map[string]interface{}{"subnet": "172.28.0.0/16", "gateway": "172.28.5.254", "ip_range": "172.28.5.0/24",
+				"aux_addresses": map[string]interface{}{"host1": "172.28.1.5"}},
+			map[string]interface{}{"subnet": "2001:3984:3989::/64", "gateway": "2001:3984:3989::1"},
+		},
+	}
+	expectedIPAM = network.IPAM{
+		Driver:  "test",
+		Options: map[string]string{"foo": "bar", "baz": "1"},
+		Config: []network.IPAMConfig{
+			{Subnet: "172.28.0.0/16", Gateway: "172.28.5.254", IPRange: "172.28.5.0/24", AuxAddress: map[string]string{"host1": "172.28.1.5"}},
+			{Subnet: "2001:3984:3989::/64", Gateway: "2001:3984:3989::1"},
+		},
+	}
 )
 
 func getNetworkMapping() []verifyMapping {
@@ -132,5 +147,15 @@ func getInvalidNetworkConfig() []invalidMapping {
 		{"driver", "invalid"},
 		{"ipam", map[string]interface{}{"config": []interface{}{map[string]interface{}{"invalid": "test"}}}},
 		{"ipam", map[string]interface{}{"config": []interface{}{map[string]interface{}{"subnet": "invalid"}}}},
+		{"ipam", map[string]interface{}{"config": []interface{}{map[string]interface{}{"gateway": "172.28.0.1"}}}},
+		{"ipam", map[string]interface{}{"config": []interface{}{map[string]interface{}{"subnet": "172.28.0.0/16", "gateway": "invalid"}}}},
+		{"ipam", map[string]interface{}{"config": []interface{}{map[string]interface{}{"subnet": "172.28.0.0/16", "gateway": "172.29.0.1"}}}},
+		{"ipam", map[string]interface{}{"config": []interface{}{map[string]interface{}{"subnet": "172.28.0.0/16", "gateway": "2001:3984:3989::1"}}}},
+		{"ipam", map[string]interface{}{"config": []interface{}{map[string]interface{}{"subnet": "172.28.0.0/16", "ip_range": "172.28.0.0"}}}},
+		{"ipam", map[string]interface{}{"config": []interface{}{map[string]interface{}{"subnet": "172.28.0.0/16", "ip_range": "172.0.0.0/8"}}}},
+		{"ipam", map[string]interface{}{"config": []interface{}{map[string]interface{}{"subnet": "172.28.0.0/16", "ip_range": "10.0.0.0/24"}}}},
+		{"ipam", map[string]interface{}{"config": []interface{}{map[string]interface{}{"subnet": "172.28.0.0/16", "aux_addresses": map[string]interface{}{"host1": "invalid"}}}}},
+		{"ipam", map[string]interface{}{"config": []interface{}{map[string]interface{}{"subnet": "172.28.0.0/16", "aux_addresses": map[string]interface{}{"host1": "10.0.0.1"}}}}},
+		{"ipam", map[string]interface{}{"options": 0}},
 	}
 }
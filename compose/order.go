@@ -0,0 +1,80 @@
+package compose
+
+import (
+	"sort"
+	"strings"
+)
+
+// serviceDependencies returns the services that must be started before the service in sorted order.
+// Links imply a dependency in the same way as depends_on
+func (s Stack) serviceDependencies(name string) []string {
+	service := s.services[name]
+	dependencies := service.GetDependencies()
+	for _, link := range service.GetLinks() {
+		dependencies[strings.SplitN(link, ":", 2)[0]] = DependencyStarted
+	}
+
+	var names []string
+	for _, dependency := range sortedKeys(dependencies) {
+		if _, exists := s.services[dependency]; exists && dependency != name {
+			names = append(names, dependency)
+		}
+	}
+	return names
+}
+
+// serviceLevels groups the services so each service only depends on services in earlier
+// levels. Validation has already rejected dependency cycles
+func (s Stack) serviceLevels(services []string) [][]string {
+	depth := make(map[string]int, len(services))
+	var getDepth func(name string) int
+	getDepth = func(name string) int {
+		if level, found := depth[name]; found {
+			return level
+		}
+		depth[name] = 0
+		for _, dependency := range s.serviceDependencies(name) {
+			if level := getDepth(dependency) + 1; level > depth[name] {
+				depth[name] = level
+			}
+		}
+		return depth[name]
+	}
+
+	byDepth := make(map[int][]string)
+	for _, name := range services {
+		byDepth[getDepth(name)] = append(byDepth[getDepth(name)], name)
+	}
+
+	var depths []int
+	for level := range byDepth {
+		depths = append(depths, level)
+	}
+	sort.Ints(depths)
+
+	levels := make([][]string, 0, len(depths))
+	for _, level := range depths {
+		sort.Strings(byDepth[level])
+		levels = append(levels, byDepth[level])
+	}
+	return levels
+}
+
+// withDependencies returns the services and everything they depend on in sorted order
+func (s Stack) withDependencies(services []string) []string {
+	found := make(map[string]bool)
+	var add func(name string)
+	add = func(name string) {
+		if found[name] {
+			return
+		}
+		found[name] = true
+		for _, dependency := range s.serviceDependencies(name) {
+			add(dependency)
+		}
+	}
+	for _, name := range services {
+		add(name)
+	}
+	return sortedKeys(found)
+}
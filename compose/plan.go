@@ -0,0 +1,339 @@
+package compose
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+)
+
+// Change is what applying a Plan will do to a single resource. Name is the name of the resource on the daemon
+type Change struct {
+	Kind    ResourceKind
+	Name    string
+	Service string
+	Action  Action
+	Reasons []string
+
+	// Used when applying the plan
+	source    string
+	replica   int
+	existing  *types.Container
+	pullImage types.ImagePullOptions
+}
+
+// Plan lists the changes required to bring the daemon in line with the Stack in the order they will be applied
+type Plan struct {
+	Changes []Change
+
+	options UpOptions
+}
+
+// planVerbs describes each action as something that has not happened yet
+var planVerbs = map[Action]string{
+	ActionCreated:   "create",
+	ActionRecreated: "recreate",
+	ActionStarted:   "start",
+	ActionPulled:    "pull",
+	ActionRemoved:   "remove",
+	ActionUnchanged: "none",
+}
+
+// HasChanges returns true if applying the plan would change anything
+func (p Plan) HasChanges() bool {
+	for _, change := range p.Changes {
+		if change.Action != ActionUnchanged {
+			return true
+		}
+	}
+	return false
+}
+
+// String describes every change on a separate line, e.g. "network app_backend will be created"
+func (p Plan) String() string {
+	var lines []string
+	for _, change := range p.Changes {
+		line := fmt.Sprintf("%s %s will be %s", change.Kind, change.Name, change.Action)
+		if change.Action == ActionUnchanged {
+			line = fmt.Sprintf("%s %s is up to date", change.Kind, change.Name)
+		}
+		if len(change.Reasons) > 0 {
+			line += " because " + strings.Join(change.Reasons, " and ")
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		return "no changes"
+	}
+	return strings.Join(lines, "\n")
+}
+
+// MarshalJSON outputs the changes with the action as a verb, e.g. "create"
+func (p Plan) MarshalJSON() ([]byte, error) {
+	type jsonChange struct {
+		Kind    ResourceKind `json:"kind"`
+		Name    string       `json:"name"`
+		Service string       `json:"service,omitempty"`
+		Action  string       `json:"action"`
+		Reasons []string     `json:"reasons,omitempty"`
+	}
+	changes := []jsonChange{}
+	for _, change := range p.Changes {
+		changes = append(changes, jsonChange{change.Kind, change.Name, change.Service, planVerbs[change.Action], change.Reasons})
+	}
+	return json.Marshal(struct {
+		Changes []jsonChange `json:"changes"`
+	}{changes})
+}
+
+// Plan compares the Stack to the daemon and returns the changes Up would make. Nothing is changed on
+// the daemon. An error is returned if Up would fail before changing anything, e.g. an external
+// network is missing
+func (s Stack) Plan(ctx context.Context, client Client, options UpOptions) (Plan, error) {
+	if options.ForceRecreate && options.NoRecreate {
+		return Plan{}, fmt.Errorf("ForceRecreate and NoRecreate cannot both be set")
+	}
+
+	return s.planServices(ctx, client, options, s.NetworkNames(), s.VolumeNames(), s.ServiceNames())
+}
+
+// planServices plans only the networks, volumes and services given so problems with the rest of the
+// Stack do not stop the plan. Dependencies left out are treated as unchanged
+func (s Stack) planServices(ctx context.Context, client Client, options UpOptions, networks, volumes, services []string) (Plan, error) {
+	plan := Plan{options: options}
+	for _, name := range networks {
+		change, err := s.planNetwork(ctx, client, name)
+		if err != nil {
+			return Plan{}, err
+		}
+		plan.Changes = append(plan.Changes, change)
+	}
+
+	for _, name := range volumes {
+		change, err := s.planVolume(ctx, client, name)
+		if err != nil {
+			return Plan{}, err
+		}
+		plan.Changes = append(plan.Changes, change)
+	}
+
+	// imageIDs are the IDs of the images that are present, used to find containers with outdated images
+	imageIDs := make(map[string]string)
+	for _, name := range services {
+		image := s.services[name].GetContainerConfig().Image
+		if image == "" {
+			return Plan{}, fmt.Errorf("service %s does not have an image and building is not supported", name)
+		}
+		image = imageWithTag(image)
+		if _, planned := imageIDs[image]; planned {
+			continue
+		}
+		change, id, err := s.planImage(ctx, client, name, image)
+		if err != nil {
+			return Plan{}, err
+		}
+		imageIDs[image] = id
+		plan.Changes = append(plan.Changes, change)
+	}
+
+	recreated := make(map[string]bool)
+	for _, level := range s.serviceLevels(services) {
+		for _, name := range level {
+			image := imageWithTag(s.services[name].GetContainerConfig().Image)
+			changes, err := s.planService(ctx, client, name, imageIDs[image], options, recreated)
+			if err != nil {
+				return Plan{}, err
+			}
+			plan.Changes = append(plan.Changes, changes...)
+			for _, change := range changes {
+				recreated[name] = recreated[name] || change.Action == ActionCreated || change.Action == ActionRecreated
+			}
+		}
+	}
+	return plan, nil
+}
+
+// serviceResources returns the networks and volumes of the Stack used by the services in sorted order
+func (s Stack) serviceResources(services []string) ([]string, []string) {
+	networks := make(map[string]bool)
+	volumes := make(map[string]bool)
+	for _, name := range services {
+		service := s.services[name]
+		endpoints := service.GetNetworkConfig().EndpointsConfig
+		if len(endpoints) == 0 && service.GetHostConfig().NetworkMode == "" {
+			networks[implicitDefaultNetwork] = true
+		}
+		for network := range endpoints {
+			if _, exists := s.networks[network]; exists {
+				networks[network] = true
+			}
+		}
+		for _, volume := range service.GetVolumes() {
+			if _, exists := s.volumes[volume.Source]; exists && volume.Type == "volume" {
+				volumes[volume.Source] = true
+			}
+		}
+	}
+	return sortedKeys(networks), sortedKeys(volumes)
+}
+
+func (s Stack) planNetwork(ctx context.Context, client Client, name string) (Change, error) {
+	change := Change{Kind: KindNetwork, Name: s.GetNetworkName(name), Action: ActionUnchanged, source: name}
+	exists, err := networkExists(ctx, client, change.Name)
+	if err != nil {
+		return Change{}, fmt.Errorf("network %s: %w", change.Name, err)
+	}
+
+	if _, external := s.networks[name].GetExternalName(); external && !exists {
+		return Change{}, fmt.Errorf("external network %s does not exist", change.Name)
+	} else if !exists {
+		change.Action, change.Reasons = ActionCreated, []string{"it does not exist"}
+	}
+	return change, nil
+}
+
+func (s Stack) planVolume(ctx context.Context, client Client, name string) (Change, error) {
+	change := Change{Kind: KindVolume, Name: s.GetVolumeName(name), Action: ActionUnchanged, source: name}
+	exists, err := volumeExists(ctx, client, change.Name)
+	if err != nil {
+		return Change{}, fmt.Errorf("volume %s: %w", change.Name, err)
+	}
+
+	if _, external := s.volumes[name].GetExternalName(); external && !exists {
+		return Change{}, fmt.Errorf("external volume %s does not exist", change.Name)
+	} else if !exists {
+		change.Action, change.Reasons = ActionCreated, []string{"it does not exist"}
+	}
+	return change, nil
+}
+
+// planImage decides whether the image needs to be pulled and returns its ID, empty if it is not present
+func (s Stack) planImage(ctx context.Context, client Client, service, image string) (Change, string, error) {
+	change := Change{Kind: KindImage, Name: image, Service: service, Action: ActionUnchanged}
+	id, err := imageID(ctx, client, image)
+	if err != nil {
+		return Change{}, "", fmt.Errorf("image %s: %w", image, err)
+	}
+	if id == "" {
+		change.Action, change.Reasons = ActionPulled, []string{"it is not present"}
+		if platform, err := s.GetServicePlatform(service); err == nil && platform.OS != "" {
+			change.pullImage.Platform = FormatPlatform(platform)
+		}
+	}
+	return change, id, nil
+}
+
+// planService plans every replica of the service and removes any replicas above the scale of the service.
+// ImageID is the ID of the image of the service, empty if it is not present
+func (s Stack) planService(ctx context.Context, client Client, service, imageID string, options UpOptions, recreated map[string]bool) ([]Change, error) {
+	if err := s.services[service].verifyScalable(); err != nil {
+		return nil, fmt.Errorf("service %s: %s", service, err.Error())
+	}
+	existing, err := s.findContainers(ctx, client, service)
+	if err != nil {
+		return nil, fmt.Errorf("service %s: %w", service, err)
+	}
+
+	var changes []Change
+	replicas := s.services[service].GetReplicas()
+	for replica := 1; replica <= replicas; replica++ {
+		change, err := s.planContainer(service, replica, existing[replica], imageID, options, recreated)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+
+	for _, replica := range sortedReplicas(existing) {
+		if replica > replicas {
+			changes = append(changes, Change{
+				Kind:     KindContainer,
+				Name:     containerName(*existing[replica]),
+				Service:  service,
+				Action:   ActionRemoved,
+				Reasons:  []string{fmt.Sprintf("service %s is scaled to %d", service, replicas)},
+				existing: existing[replica],
+			})
+		}
+	}
+	return changes, nil
+}
+
+// planContainer decides whether the replica needs to be created, recreated or started. ImageID is the
+// ID of the image of the service, empty if it is not present. Recreated contains the services that will
+// be created or recreated earlier in the plan
+func (s Stack) planContainer(service string, replica int, existing *types.Container, imageID string, options UpOptions, recreated map[string]bool) (Change, error) {
+	change := Change{Kind: KindContainer, Name: s.getContainerName(service, replica), Service: service, Action: ActionUnchanged, source: service, replica: replica}
+	request, err := s.ContainerCreateRequest(service, replica)
+	if err != nil {
+		return Change{}, err
+	}
+
+	if existing == nil {
+		change.Action, change.Reasons = ActionCreated, []string{"it does not exist"}
+		return change, nil
+	}
+	change.existing = existing
+
+	if !options.NoRecreate {
+		if options.ForceRecreate {
+			change.Reasons = append(change.Reasons, "recreate was forced")
+		}
+		if existing.Image != request.Config.Image {
+			change.Reasons = append(change.Reasons, fmt.Sprintf("image changed from %s to %s", existing.Image, request.Config.Image))
+		} else if imageID == "" {
+			change.Reasons = append(change.Reasons, fmt.Sprintf("image %s will be pulled", request.Config.Image))
+		} else if existing.ImageID != imageID {
+			change.Reasons = append(change.Reasons, fmt.Sprintf("image %s has changed", request.Config.Image))
+		}
+		if existing.Labels[ConfigHashLabel] != request.Config.Labels[ConfigHashLabel] {
+			change.Reasons = append(change.Reasons, "configuration changed")
+		}
+		for _, dependency := range s.serviceDependencies(service) {
+			if recreated[dependency] {
+				change.Reasons = append(change.Reasons, fmt.Sprintf("dependency %s will be recreated", dependency))
+			}
+		}
+		if len(change.Reasons) > 0 {
+			change.Action = ActionRecreated
+			return change, nil
+		}
+	}
+
+	if existing.State != "running" {
+		change.Action, change.Reasons = ActionStarted, []string{fmt.Sprintf("it is %s", existing.State)}
+	}
+	return change, nil
+}
+
+// findContainers returns the containers of the service keyed by their replica number
+func (s Stack) findContainers(ctx context.Context, client Client, service string) (map[int]*types.Container, error) {
+	filter := s.projectFilter()
+	filter.Add("label", ServiceLabel+"="+service)
+	filter.Add("label", OneoffLabel+"=False")
+	containers, err := client.ContainerList(ctx, types.ContainerListOptions{All: true, Filters: filter})
+	if err != nil {
+		return nil, err
+	}
+
+	byReplica := make(map[int]*types.Container)
+	for i := range containers {
+		if replica, err := strconv.Atoi(containers[i].Labels[ContainerNumberLabel]); err == nil {
+			byReplica[replica] = &containers[i]
+		}
+	}
+	return byReplica, nil
+}
+
+func sortedReplicas(containers map[int]*types.Container) []int {
+	var replicas []int
+	for replica := range containers {
+		replicas = append(replicas, replica)
+	}
+	sort.Ints(replicas)
+	return replicas
+}
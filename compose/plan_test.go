@@ -0,0 +1,130 @@
+package compose_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/rmasp98/go-compose/compose"
+)
+
+func TestPlanDoesNotChangeDaemon(t *testing.T) {
+	client := newFakeClient()
+	plan, err := newUpStack(t).Plan(context.Background(), client, compose.UpOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(client.Calls()) != 0 {
+		t.Errorf("Plan should not change anything but got %v", client.Calls())
+	}
+	if !plan.HasChanges() {
+		t.Error("Plan should have changes for an empty daemon")
+	}
+
+	expected := `network app_backend will be created because it does not exist
+network app_frontend will be created because it does not exist
+network shared is up to date
+volume app_data will be created because it does not exist
+image example/api:1.0 will be pulled because it is not present
+image postgres:latest is up to date
+image nginx:latest will be pulled because it is not present
+container app-db-1 will be created because it does not exist
+container app-api-1 will be created because it does not exist
+container app-web-1 will be created because it does not exist`
+	if plan.String() != expected {
+		t.Errorf("Plan should be:\n%s\nbut got:\n%s", expected, plan.String())
+	}
+}
+
+func TestPlanDescribesWhyContainersAreRecreated(t *testing.T) {
+	client := newFakeClient()
+	stack := newUpStack(t)
+	if _, err := stack.Up(context.Background(), client, compose.UpOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	updated, _ := stack.WithImageTag("api", "2.0")
+	client.AddImage("example/api:2.0")
+
+	plan, err := updated.Plan(context.Background(), client, compose.UpOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reasons := make(map[string][]string)
+	for _, change := range plan.Changes {
+		if change.Kind == compose.KindContainer {
+			reasons[change.Name] = change.Reasons
+		}
+	}
+	expected := map[string][]string{
+		"app-db-1":  nil,
+		"app-api-1": {"image changed from example/api:1.0 to example/api:2.0", "configuration changed"},
+		"app-web-1": {"dependency api will be recreated"},
+	}
+	if err := verifyValue(expected, reasons); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPlanRecreatesContainersWhenTheImageIDChanges(t *testing.T) {
+	client := newFakeClient()
+	stack := newUpStack(t)
+	if _, err := stack.Up(context.Background(), client, compose.UpOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	client.SetImageID("postgres", "sha256:newer")
+	if _, err := client.ImageRemove(context.Background(), "nginx", types.ImageRemoveOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := stack.Plan(context.Background(), client, compose.UpOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reasons := make(map[string][]string)
+	for _, change := range plan.Changes {
+		if change.Kind == compose.KindContainer {
+			reasons[change.Name] = change.Reasons
+		}
+	}
+	expected := map[string][]string{
+		"app-db-1":  {"image postgres has changed"},
+		"app-api-1": {"dependency db will be recreated"},
+		"app-web-1": {"image nginx will be pulled", "dependency api will be recreated"},
+	}
+	if err := verifyValue(expected, reasons); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPlanCanBeOutputAsJSON(t *testing.T) {
+	client := newFakeClient()
+	stack := newUpStack(t)
+	if _, err := stack.Up(context.Background(), client, compose.UpOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	client.SetContainerState("app-db-1", "exited")
+
+	plan, _ := stack.Plan(context.Background(), client, compose.UpOptions{})
+	if plan.String() == "" || !plan.HasChanges() {
+		t.Fatal("Expected stopped container to be started")
+	}
+	data, err := json.Marshal(plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var output struct {
+		Changes []map[string]interface{} `json:"changes"`
+	}
+	if err := json.Unmarshal(data, &output); err != nil {
+		t.Fatal(err)
+	}
+	db := output.Changes[len(output.Changes)-3]
+	expected := map[string]interface{}{
+		"kind": "container", "name": "app-db-1", "service": "db", "action": "start", "reasons": []interface{}{"it is exited"},
+	}
+	if err := verifyValue(expected, db); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,132 @@
+package compose
+
+import (
+	"fmt"
+	"strings"
+
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+var knownOS = map[string]bool{
+	"aix": true, "darwin": true, "freebsd": true, "illumos": true, "linux": true,
+	"netbsd": true, "openbsd": true, "solaris": true, "windows": true,
+}
+
+// knownArchitectures maps each architecture to the variants it supports
+var knownArchitectures = map[string][]string{
+	"386":      {},
+	"amd64":    {"v2", "v3", "v4"},
+	"arm":      {"v5", "v6", "v7", "v8"},
+	"arm64":    {"v8", "v9"},
+	"loong64":  {},
+	"mips64":   {},
+	"mips64le": {},
+	"ppc64":    {},
+	"ppc64le":  {},
+	"riscv64":  {},
+	"s390x":    {},
+}
+
+// ParsePlatform converts a platform string in the form os[/arch[/variant]] into
+// a v1.Platform. Common aliases (e.g. x86_64, aarch64, armhf) are normalised so
+// the result can be compared against the platforms reported by the daemon
+func ParsePlatform(platform string) (v1.Platform, error) {
+	parts := strings.Split(strings.ToLower(strings.TrimSpace(platform)), "/")
+	if len(parts) > 3 || parts[0] == "" {
+		return v1.Platform{}, fmt.Errorf("%q is not a valid platform", platform)
+	}
+
+	var output v1.Platform
+	output.OS = normaliseOS(parts[0])
+	if !knownOS[output.OS] {
+		return v1.Platform{}, fmt.Errorf("%q is not a known operating system", parts[0])
+	}
+
+	if len(parts) > 1 {
+		var variant string
+		if len(parts) == 3 {
+			variant = parts[2]
+		}
+		output.Architecture, output.Variant = normaliseArchitecture(parts[1], variant)
+		if err := validateArchitecture(output.Architecture, output.Variant); err != nil {
+			return v1.Platform{}, err
+		}
+	}
+
+	return output, nil
+}
+
+// FormatPlatform returns the platform in the form os[/arch[/variant]]. Platforms from
+// ParsePlatform are formatted in their normalised form, e.g. linux/aarch64/v8 becomes
+// linux/arm64, and parse back to the same platform
+func FormatPlatform(platform v1.Platform) string {
+	output := platform.OS
+	if platform.Architecture != "" {
+		output += "/" + platform.Architecture
+		if platform.Variant != "" {
+			output += "/" + platform.Variant
+		}
+	}
+	return output
+}
+
+func convertPlatform(input interface{}) (interface{}, error) {
+	if platform, isStr := input.(string); isStr {
+		return ParsePlatform(platform)
+	}
+	return nil, fmt.Errorf("should be a string")
+}
+
+func normaliseOS(os string) string {
+	if os == "macos" {
+		return "darwin"
+	}
+	return os
+}
+
+func normaliseArchitecture(arch, variant string) (string, string) {
+	if variant != "" && !strings.HasPrefix(variant, "v") {
+		variant = "v" + variant
+	}
+
+	switch arch {
+	case "i386":
+		arch = "386"
+	case "x86_64", "x86-64", "amd64":
+		arch = "amd64"
+		if variant == "v1" {
+			variant = ""
+		}
+	case "aarch64", "arm64":
+		// v8 is the only arm64 variant most images publish so, like containerd, it is left out
+		arch = "arm64"
+		if variant == "v8" {
+			variant = ""
+		}
+	case "armhf":
+		arch, variant = "arm", "v7"
+	case "armel":
+		arch, variant = "arm", "v6"
+	case "arm":
+		if variant == "" {
+			variant = "v7"
+		}
+	}
+	return arch, variant
+}
+
+func validateArchitecture(arch, variant string) error {
+	variants, known := knownArchitectures[arch]
+	if !known {
+		return fmt.Errorf("%q is not a known architecture", arch)
+	}
+	if variant == "" {
+		return nil
+	}
+	for _, validVariant := range variants {
+		if variant == validVariant {
+			return nil
+		}
+	}
+	return fmt.Errorf("%q is not a valid variant for %s", variant, arch)
+}
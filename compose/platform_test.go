@@ -0,0 +1,76 @@
+package compose_test
+
+import (
+	"testing"
+
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/rmasp98/go-compose/compose"
+)
+
+func TestCanParsePlatform(t *testing.T) {
+	platforms := map[string]v1.Platform{
+		"linux":          {OS: "linux"},
+		"linux/amd64":    {OS: "linux", Architecture: "amd64"},
+		"linux/x86_64":   {OS: "linux", Architecture: "amd64"},
+		"linux/amd64/v1": {OS: "linux", Architecture: "amd64"},
+		"linux/amd64/v3": {OS: "linux", Architecture: "amd64", Variant: "v3"},
+		"linux/i386":     {OS: "linux", Architecture: "386"},
+		"linux/arm64":    {OS: "linux", Architecture: "arm64"},
+		"linux/aarch64":  {OS: "linux", Architecture: "arm64"},
+		"linux/arm64/v8": {OS: "linux", Architecture: "arm64"},
+		"linux/arm64/v9": {OS: "linux", Architecture: "arm64", Variant: "v9"},
+		"linux/arm":      {OS: "linux", Architecture: "arm", Variant: "v7"},
+		"linux/arm/6":    {OS: "linux", Architecture: "arm", Variant: "v6"},
+		"linux/armhf":    {OS: "linux", Architecture: "arm", Variant: "v7"},
+		"linux/armel":    {OS: "linux", Architecture: "arm", Variant: "v6"},
+		"Linux/PPC64LE":  {OS: "linux", Architecture: "ppc64le"},
+		"macos/arm64":    {OS: "darwin", Architecture: "arm64"},
+		"windows/amd64":  {OS: "windows", Architecture: "amd64"},
+	}
+	for source, expected := range platforms {
+		platform, err := compose.ParsePlatform(source)
+		if err != nil {
+			t.Errorf("%s: %s", source, err.Error())
+			continue
+		}
+		if err := verifyValue(expected, platform); err != nil {
+			t.Errorf("%s: %s", source, err.Error())
+		}
+	}
+}
+
+func TestReturnsErrorForInvalidPlatform(t *testing.T) {
+	for _, platform := range []string{"", "/amd64", "plan10/amd64", "linux/z80", "linux/amd64/v7", "linux/s390x/v1", "linux/arm/v8/extra"} {
+		if _, err := compose.ParsePlatform(platform); err == nil {
+			t.Errorf("%q should have returned an error but did not", platform)
+		}
+	}
+}
+
+func TestFormatPlatformIsInverseOfParse(t *testing.T) {
+	for _, source := range []string{"linux", "linux/amd64", "linux/arm64", "linux/arm/v7"} {
+		platform, _ := compose.ParsePlatform(source)
+		if formatted := compose.FormatPlatform(platform); formatted != source {
+			t.Errorf("Should have returned %q but got %q", source, formatted)
+		}
+	}
+}
+
+func TestServicePlatformIsParsed(t *testing.T) {
+	service, err := compose.NewService(map[string]interface{}{"platform": "linux/arm64/v8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := v1.Platform{OS: "linux", Architecture: "arm64"}
+	if err := verifyValue(expected, service.GetPlatformConfig()); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReturnsErrorForInvalidServicePlatform(t *testing.T) {
+	for _, platform := range []interface{}{0, "linux/z80"} {
+		if _, err := compose.NewService(map[string]interface{}{"platform": platform}); err == nil {
+			t.Errorf("%v should have returned an error but did not", platform)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package compose
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	networktypes "github.com/docker/docker/api/types/network"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// ContainerNumberEnv is set in each replica to its number unless the service sets it itself
+const ContainerNumberEnv = "COMPOSE_CONTAINER_NUMBER"
+
+// ContainerCreateRequest contains the arguments of ContainerCreate for a single replica of a service
+type ContainerCreateRequest struct {
+	Name             string
+	Config           *container.Config
+	HostConfig       *container.HostConfig
+	NetworkingConfig *networktypes.NetworkingConfig
+	Platform         *v1.Platform
+
+	// ExtraNetworks must be connected with NetworkConnect after the container is
+	// created as the API only accepts a single network in NetworkingConfig
+	ExtraNetworks map[string]*networktypes.EndpointSettings
+}
+
+// ContainerCreateRequest builds the request to create the given replica of the service.
+// Replicas are numbered from 1 and the number is set in the labels and ContainerNumberEnv.
+// All networks and volumes are referenced by their project scoped names and the request
+// does not share any data with the Stack
+func (s Stack) ContainerCreateRequest(name string, replica int) (ContainerCreateRequest, error) {
+	request, err := s.containerCreateRequest(name, replica)
+	if err != nil {
+		return ContainerCreateRequest{}, err
+	}
+	hash, err := s.ConfigHash(name)
+	if err != nil {
+		return ContainerCreateRequest{}, err
+	}
+	request.Config.Labels[ConfigHashLabel] = hash
+	if !isEnvSet(request.Config.Env, ContainerNumberEnv) {
+		request.Config.Env = append(request.Config.Env, ContainerNumberEnv+"="+strconv.Itoa(replica))
+	}
+	return request, nil
+}
+
+// ConfigHash returns a hash of everything used to create the containers of the service except
+// the parts that differ between replicas. Containers with a different hash are out of date
+func (s Stack) ConfigHash(name string) (string, error) {
+	request, err := s.containerCreateRequest(name, 1)
+	if err != nil {
+		return "", err
+	}
+	request.Name = ""
+	delete(request.Config.Labels, ContainerNumberLabel)
+
+	data, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(data)), nil
+}
+
+func (s Stack) containerCreateRequest(name string, replica int) (ContainerCreateRequest, error) {
+	service, err := s.getService(name)
+	if err != nil {
+		return ContainerCreateRequest{}, err
+	}
+	if replica < 1 {
+		return ContainerCreateRequest{}, fmt.Errorf("replica must be 1 or more but was %d", replica)
+	}
+
+	request := ContainerCreateRequest{
+		Name:             s.getContainerName(name, replica),
+		Config:           new(container.Config),
+		HostConfig:       new(container.HostConfig),
+		NetworkingConfig: &networktypes.NetworkingConfig{EndpointsConfig: map[string]*networktypes.EndpointSettings{}},
+		ExtraNetworks:    map[string]*networktypes.EndpointSettings{},
+	}
+
+	hostConfig, err := s.GetServiceHostConfig(name)
+	if err != nil {
+		return ContainerCreateRequest{}, err
+	}
+	if err := deepCopy(service.GetContainerConfig(), request.Config); err != nil {
+		return ContainerCreateRequest{}, err
+	}
+	if err := deepCopy(hostConfig, request.HostConfig); err != nil {
+		return ContainerCreateRequest{}, err
+	}
+
+	request.Config.Labels = mergeLabels(request.Config.Labels, map[string]string{
+		ProjectLabel:         s.projectName,
+		ServiceLabel:         name,
+		ContainerNumberLabel: strconv.Itoa(replica),
+		OneoffLabel:          "False",
+	})
+
+	s.addVolumeMounts(service, &request)
+	if err := s.addNetworkEndpoints(name, service, &request); err != nil {
+		return ContainerCreateRequest{}, err
+	}
+
+	if platform, err := s.GetServicePlatform(name); err != nil {
+		return ContainerCreateRequest{}, err
+	} else if platform.OS != "" {
+		request.Platform = &platform
+	}
+
+	return request, nil
+}
+
+// GetNetworkName returns the name of the network on the daemon
+func (s Stack) GetNetworkName(name string) string {
+	network := s.networks[name]
+	if network.name != "" {
+		return network.name
+	}
+	if network.external {
+		return name
+	}
+	return s.projectName + "_" + name
+}
+
+// GetVolumeName returns the name of the volume on the daemon
+func (s Stack) GetVolumeName(name string) string {
+	volume := s.volumes[name]
+	if volume.data.Name != "" {
+		return volume.data.Name
+	}
+	if volume.external {
+		return name
+	}
+	return s.projectName + "_" + name
+}
+
+// addVolumeMounts moves named volumes out of the anonymous volumes and mounts them by their project scoped name
+func (s Stack) addVolumeMounts(service Service, request *ContainerCreateRequest) {
+	for _, volume := range service.GetVolumes() {
+		if volume.Type != "volume" || volume.Source == "" {
+			continue
+		}
+		delete(request.Config.Volumes, getVolumeString(volume))
+
+		volumeMount := mount.Mount{
+			Type:     mount.TypeVolume,
+			Source:   s.GetVolumeName(volume.Source),
+			Target:   volume.Target,
+			ReadOnly: volume.ReadOnly,
+		}
+		if volume.Volume != nil && volume.Volume.NoCopy {
+			volumeMount.VolumeOptions = &mount.VolumeOptions{NoCopy: true}
+		}
+		request.HostConfig.Mounts = append(request.HostConfig.Mounts, volumeMount)
+	}
+}
+
+// addNetworkEndpoints re-keys the endpoints to the project scoped network names.
+// Services without any networks join the default network of the project
+func (s Stack) addNetworkEndpoints(name string, service Service, request *ContainerCreateRequest) error {
+	if service.GetHostConfig().NetworkMode != "" {
+		return nil
+	}
+
+	endpoints := service.GetNetworkConfig().EndpointsConfig
+	if len(endpoints) == 0 {
+		endpoints = map[string]*networktypes.EndpointSettings{implicitDefaultNetwork: {}}
+	}
+
+	for i, network := range sortedKeys(endpoints) {
+		endpoint := &networktypes.EndpointSettings{}
+		if endpoints[network] != nil {
+			if err := deepCopy(endpoints[network], endpoint); err != nil {
+				return err
+			}
+		}
+		endpoint.Aliases = append(endpoint.Aliases, name)
+		endpoint.Links = s.resolveLinks(endpoint.Links)
+		// Static addresses are only honoured by the daemon when set in the IPAM config
+		if endpoint.IPAddress != "" || endpoint.GlobalIPv6Address != "" {
+			endpoint.IPAMConfig = &networktypes.EndpointIPAMConfig{
+				IPv4Address: endpoint.IPAddress,
+				IPv6Address: endpoint.GlobalIPv6Address,
+			}
+		}
+
+		networkName := s.GetNetworkName(network)
+		if i == 0 {
+			request.HostConfig.NetworkMode = container.NetworkMode(networkName)
+			request.NetworkingConfig.EndpointsConfig[networkName] = endpoint
+		} else {
+			request.ExtraNetworks[networkName] = endpoint
+		}
+	}
+	return nil
+}
+
+// resolveLinks converts links in the form service[:alias] to container:alias
+func (s Stack) resolveLinks(links []string) []string {
+	var resolved []string
+	for _, link := range links {
+		parts := strings.SplitN(link, ":", 2)
+		alias := parts[0]
+		if len(parts) == 2 {
+			alias = parts[1]
+		}
+		resolved = append(resolved, s.getContainerName(parts[0], 1)+":"+alias)
+	}
+	return resolved
+}
+
+// isEnvSet returns whether the variable is in the environment, which is in the form NAME=value or NAME
+func isEnvSet(env []string, name string) bool {
+	for _, variable := range env {
+		if strings.SplitN(variable, "=", 2)[0] == name {
+			return true
+		}
+	}
+	return false
+}
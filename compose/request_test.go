@@ -0,0 +1,252 @@
+package compose_test
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types/mount"
+	"github.com/rmasp98/go-compose/compose"
+)
+
+const requestCompose = `
+name: app
+services:
+  web:
+    image: nginx
+    labels:
+      com.example.team: web
+    links: ["db:database"]
+    networks:
+      frontend:
+        aliases: [www]
+      backend:
+        ipv4_address: 172.28.0.5
+    volumes:
+      - data:/data:ro
+      - /tmp/cache:/cache
+    platform: linux/arm64
+  db:
+    image: postgres
+    container_name: database
+  worker:
+    image: busybox
+    volumes:
+      - external-data:/data
+  sidecar:
+    image: busybox
+    network_mode: service:web
+networks:
+  frontend:
+  backend:
+    ipam:
+      config:
+        - subnet: 172.28.0.0/16
+volumes:
+  data:
+  external-data:
+    external: true
+    name: shared-data
+`
+
+func TestContainerCreateRequestContainsReplicaNameAndLabels(t *testing.T) {
+	stack := newRequestStack(t)
+	request, err := stack.ContainerCreateRequest("web", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if request.Name != "app-web-2" {
+		t.Errorf("Name should be \"app-web-2\" but got \"%s\"", request.Name)
+	}
+	hash, _ := stack.ConfigHash("web")
+	expectedLabels := map[string]string{
+		"com.example.team":           "web",
+		compose.ProjectLabel:         "app",
+		compose.ServiceLabel:         "web",
+		compose.ContainerNumberLabel: "2",
+		compose.OneoffLabel:          "False",
+		compose.ConfigHashLabel:      hash,
+	}
+	if err := verifyValue(expectedLabels, request.Config.Labels); err != nil {
+		t.Error(err)
+	}
+	if err := verifyValue([]string{compose.ContainerNumberEnv + "=2"}, request.Config.Env); err != nil {
+		t.Errorf("env: %s", err.Error())
+	}
+	if request.Platform == nil || compose.FormatPlatform(*request.Platform) != "linux/arm64" {
+		t.Errorf("Platform was not set: %v", request.Platform)
+	}
+}
+
+func TestContainerCreateRequestKeepsContainerNumberSetByService(t *testing.T) {
+	stack, err := compose.NewStack(parseYaml(`
+services:
+  web:
+    image: nginx
+    environment:
+      COMPOSE_CONTAINER_NUMBER: custom
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	request, _ := stack.ContainerCreateRequest("web", 2)
+	if err := verifyValue([]string{compose.ContainerNumberEnv + "=custom"}, request.Config.Env); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestContainerCreateRequestUsesProjectScopedNetworks(t *testing.T) {
+	stack := newRequestStack(t)
+	request, _ := stack.ContainerCreateRequest("web", 1)
+
+	if request.HostConfig.NetworkMode != "app_backend" {
+		t.Errorf("NetworkMode should be \"app_backend\" but got \"%s\"", request.HostConfig.NetworkMode)
+	}
+	backend, isSet := request.NetworkingConfig.EndpointsConfig["app_backend"]
+	if !isSet || len(request.NetworkingConfig.EndpointsConfig) != 1 {
+		t.Fatalf("Should only contain app_backend but got %v", request.NetworkingConfig.EndpointsConfig)
+	}
+	if backend.IPAMConfig == nil || backend.IPAMConfig.IPv4Address != "172.28.0.5" {
+		t.Errorf("Static address was not set in IPAM config: %v", backend.IPAMConfig)
+	}
+	if err := verifyValue([]string{"database:database"}, backend.Links); err != nil {
+		t.Errorf("links: %s", err.Error())
+	}
+
+	frontend, isSet := request.ExtraNetworks["app_frontend"]
+	if !isSet {
+		t.Fatalf("app_frontend should be an extra network but got %v", request.ExtraNetworks)
+	}
+	if err := verifyValue([]string{"www", "web"}, frontend.Aliases); err != nil {
+		t.Errorf("aliases: %s", err.Error())
+	}
+}
+
+func TestContainerCreateRequestJoinsDefaultNetwork(t *testing.T) {
+	stack := newRequestStack(t)
+	request, _ := stack.ContainerCreateRequest("db", 1)
+	if _, isSet := request.NetworkingConfig.EndpointsConfig["app_default"]; !isSet {
+		t.Errorf("Should have joined app_default but got %v", request.NetworkingConfig.EndpointsConfig)
+	}
+	if request.Name != "database" {
+		t.Errorf("Name should be \"database\" but got \"%s\"", request.Name)
+	}
+}
+
+func TestContainerCreateRequestSharingNetworkHasNoEndpoints(t *testing.T) {
+	stack := newRequestStack(t)
+	request, _ := stack.ContainerCreateRequest("sidecar", 1)
+	if request.HostConfig.NetworkMode != "container:app-web-1" {
+		t.Errorf("NetworkMode should be \"container:app-web-1\" but got \"%s\"", request.HostConfig.NetworkMode)
+	}
+	if len(request.NetworkingConfig.EndpointsConfig) != 0 {
+		t.Errorf("Should not have any endpoints but got %v", request.NetworkingConfig.EndpointsConfig)
+	}
+}
+
+func TestContainerCreateRequestMountsNamedVolumes(t *testing.T) {
+	stack := newRequestStack(t)
+	request, _ := stack.ContainerCreateRequest("web", 1)
+	expected := []mount.Mount{{Type: mount.TypeVolume, Source: "app_data", Target: "/data", ReadOnly: true}}
+	if err := verifyValue(expected, request.HostConfig.Mounts); err != nil {
+		t.Error(err)
+	}
+	if len(request.Config.Volumes) != 0 {
+		t.Errorf("Named volume should not be an anonymous volume: %v", request.Config.Volumes)
+	}
+	if err := verifyValue([]string{"/tmp/cache:/cache:rw"}, request.HostConfig.Binds); err != nil {
+		t.Error(err)
+	}
+
+	request, _ = stack.ContainerCreateRequest("worker", 1)
+	if len(request.HostConfig.Mounts) != 1 || request.HostConfig.Mounts[0].Source != "shared-data" {
+		t.Errorf("External volume should use its name: %v", request.HostConfig.Mounts)
+	}
+}
+
+func TestContainerCreateRequestDoesNotShareData(t *testing.T) {
+	stack := newRequestStack(t)
+	request, _ := stack.ContainerCreateRequest("web", 1)
+	request.Config.Labels["com.example.team"] = "changed"
+
+	config, _ := stack.GetServiceContainerConfig("web")
+	if config.Labels["com.example.team"] != "web" {
+		t.Errorf("Modifying the request changed the Stack")
+	}
+	if _, isSet := config.Labels[compose.ProjectLabel]; isSet {
+		t.Errorf("Project labels should not be added to the Stack")
+	}
+}
+
+func TestContainerCreateRequestReturnsErrorForInvalidInput(t *testing.T) {
+	stack := newRequestStack(t)
+	if _, err := stack.ContainerCreateRequest("unknown", 1); err == nil {
+		t.Errorf("Should have returned an error for unknown service")
+	}
+	if _, err := stack.ContainerCreateRequest("web", 0); err == nil {
+		t.Errorf("Should have returned an error for replica 0")
+	}
+}
+
+func newRequestStack(t *testing.T) compose.Stack {
+	stack, err := compose.NewStack(parseYaml(requestCompose))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stack
+}
+
+func TestConfigHashIsStableForMapOptions(t *testing.T) {
+	composeFile := `
+services:
+  web:
+    image: nginx
+    environment:
+      A: "1"
+      B: "2"
+      C: "3"
+      D: "4"
+      E: "5"
+    ulimits:
+      nofile: {soft: 1024, hard: 2048}
+      nproc: 512
+      core: 0
+`
+	var first string
+	for i := 0; i < 20; i++ {
+		stack, err := compose.NewStack(parseYaml(composeFile))
+		if err != nil {
+			t.Fatal(err)
+		}
+		hash, err := stack.ConfigHash("web")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 {
+			first = hash
+		} else if hash != first {
+			t.Fatalf("Hash changed from %s to %s when loading the same file", first, hash)
+		}
+	}
+}
+
+func TestConfigHashOnlyChangesWithConfig(t *testing.T) {
+	stack := newRequestStack(t)
+	first, _ := stack.ContainerCreateRequest("web", 1)
+	second, _ := stack.ContainerCreateRequest("web", 2)
+	if first.Config.Labels[compose.ConfigHashLabel] != second.Config.Labels[compose.ConfigHashLabel] {
+		t.Error("Replicas of the same service should have the same hash")
+	}
+
+	unchanged, _ := stack.WithImage("db", "postgres")
+	changed, _ := stack.WithImage("db", "postgres:13")
+	original, _ := stack.ConfigHash("db")
+	if hash, _ := unchanged.ConfigHash("db"); hash != original {
+		t.Error("Hash should not change when the config is the same")
+	}
+	if hash, _ := changed.ConfigHash("db"); hash == original {
+		t.Error("Hash should change when the image changes")
+	}
+	if _, err := stack.ConfigHash("missing"); err == nil {
+		t.Error("Expected error for unknown service")
+	}
+}
@@ -0,0 +1,329 @@
+package compose
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// RunOptions overrides the config of the service for a one-off container and sets the streams it is attached to
+type RunOptions struct {
+	// Name of the container. Defaults to <project>-<service>-run-<random>
+	Name string
+	// Entrypoint replaces the entrypoint of the service when not nil
+	Entrypoint []string
+	// Env is added to the environment of the service in the form KEY=VALUE
+	Env        []string
+	WorkingDir string
+	User       string
+	Labels     map[string]string
+
+	// Remove deletes the container once it exits
+	Remove bool
+	// ServicePorts publishes the ports of the service. By default no ports are published so
+	// the one-off container does not conflict with the running service
+	ServicePorts bool
+	// NoDeps does not start the services the service depends on
+	NoDeps bool
+	// Detach starts the container and returns without waiting for it to exit
+	Detach bool
+
+	// Tty allocates a pseudo-TTY. The output is not multiplexed so everything is written to Stdout
+	Tty bool
+	// Stdin is attached to the container when set. Stdout and Stderr default to discarding the output
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// RunResult identifies the one-off container and how it exited. ExitCode is only set if Run waited for the container
+type RunResult struct {
+	ID       string
+	Name     string
+	ExitCode int
+}
+
+// Run creates a one-off container from the service with the command, or the command of the service if
+// empty, and waits for it to exit. The services it depends on are created and started first unless
+// NoDeps is set. A non-zero exit code is returned in the result rather than as an error
+func (s Stack) Run(ctx context.Context, client Client, service string, command []string, options RunOptions) (RunResult, error) {
+	if _, err := s.getService(service); err != nil {
+		return RunResult{}, err
+	}
+	if err := s.prepareRun(ctx, client, service, options); err != nil {
+		return RunResult{}, err
+	}
+
+	request, err := s.runRequest(service, command, options)
+	if err != nil {
+		return RunResult{}, err
+	}
+	result := RunResult{Name: request.Name}
+	created, err := client.ContainerCreate(ctx, request.Config, request.HostConfig, request.NetworkingConfig, request.Platform, request.Name)
+	if err != nil {
+		return result, wrapError("container", request.Name, err)
+	}
+	result.ID = created.ID
+	for _, network := range sortedKeys(request.ExtraNetworks) {
+		if err := client.NetworkConnect(ctx, network, created.ID, request.ExtraNetworks[network]); err != nil {
+			return result, wrapError("container", request.Name, err)
+		}
+	}
+
+	if options.Detach {
+		err := client.ContainerStart(ctx, created.ID, types.ContainerStartOptions{})
+		return result, wrapError("container", request.Name, err)
+	}
+
+	// Attach and wait before starting so no output or exit is missed
+	attached, err := client.ContainerAttach(ctx, created.ID, types.ContainerAttachOptions{
+		Stream: true, Stdin: options.Stdin != nil, Stdout: true, Stderr: true,
+	})
+	if err != nil {
+		return result, wrapError("container", request.Name, err)
+	}
+	defer attached.Close()
+	condition := container.WaitConditionNextExit
+	if options.Remove {
+		condition = container.WaitConditionRemoved
+	}
+	exits, waitErrs := client.ContainerWait(ctx, created.ID, condition)
+
+	if err := client.ContainerStart(ctx, created.ID, types.ContainerStartOptions{}); err != nil {
+		return result, wrapError("container", request.Name, err)
+	}
+	if err := copyStreams(attached, options.Tty, options.Stdin, options.Stdout, options.Stderr); err != nil {
+		return result, wrapError("container", request.Name, err)
+	}
+
+	select {
+	case exit := <-exits:
+		if exit.Error != nil {
+			return result, fmt.Errorf("container %s: %s", request.Name, exit.Error.Message)
+		}
+		result.ExitCode = int(exit.StatusCode)
+		return result, nil
+	case err := <-waitErrs:
+		return result, wrapError("container", request.Name, err)
+	}
+}
+
+// prepareRun creates the networks and volumes used by the service, pulls its image and, unless NoDeps
+// is set, brings up the services it depends on and waits for their depends_on conditions. Only the
+// service and its dependencies are planned so problems with unrelated services do not stop the run
+func (s Stack) prepareRun(ctx context.Context, client Client, service string, options RunOptions) error {
+	dependencies := make(map[string]bool)
+	if !options.NoDeps {
+		for _, name := range s.withDependencies(s.serviceDependencies(service)) {
+			dependencies[name] = true
+		}
+	}
+	services := append(sortedKeys(dependencies), service)
+	networks, volumes := s.serviceResources(services)
+
+	plan, err := s.planServices(ctx, client, UpOptions{}, networks, volumes, services)
+	if err != nil {
+		return err
+	}
+	// The service itself is run as a one-off container so its replicas are left alone
+	required := Plan{options: plan.options}
+	for _, change := range plan.Changes {
+		if change.Kind != KindContainer || dependencies[change.Service] {
+			required.Changes = append(required.Changes, change)
+		}
+	}
+	if _, err := s.Apply(ctx, client, required); err != nil {
+		return err
+	}
+
+	if options.NoDeps {
+		return nil
+	}
+	return s.waitForDependencies(ctx, client, service, UpOptions{}, make(map[string]bool))
+}
+
+// runRequest builds the request for a one-off container of the service. The restart policy is
+// removed and the container is labelled as a one-off so it is not treated as a replica
+func (s Stack) runRequest(service string, command []string, options RunOptions) (ContainerCreateRequest, error) {
+	request, err := s.containerCreateRequest(service, 1)
+	if err != nil {
+		return ContainerCreateRequest{}, err
+	}
+
+	request.Name = options.Name
+	if request.Name == "" {
+		suffix := make([]byte, 6)
+		if _, err := rand.Read(suffix); err != nil {
+			return ContainerCreateRequest{}, err
+		}
+		request.Name = fmt.Sprintf("%s-%s-run-%x", s.projectName, service, suffix)
+	}
+
+	config, hostConfig := request.Config, request.HostConfig
+	delete(config.Labels, ContainerNumberLabel)
+	config.Labels = mergeLabels(config.Labels, options.Labels)
+	config.Labels[OneoffLabel] = "True"
+	if len(command) > 0 {
+		config.Cmd = command
+	}
+	if options.Entrypoint != nil {
+		config.Entrypoint = options.Entrypoint
+	}
+	config.Env = overrideEnv(config.Env, options.Env)
+	if options.WorkingDir != "" {
+		config.WorkingDir = options.WorkingDir
+	}
+	if options.User != "" {
+		config.User = options.User
+	}
+	config.Tty = options.Tty
+	config.OpenStdin, config.StdinOnce, config.AttachStdin = options.Stdin != nil, options.Stdin != nil, options.Stdin != nil
+	config.AttachStdout, config.AttachStderr = !options.Detach, !options.Detach
+
+	hostConfig.AutoRemove = options.Remove
+	hostConfig.RestartPolicy = container.RestartPolicy{}
+	if !options.ServicePorts {
+		hostConfig.PortBindings, hostConfig.PublishAllPorts = nil, false
+	}
+	return request, nil
+}
+
+// overrideEnv adds the variables to the environment, replacing any that are already set
+func overrideEnv(env, overrides []string) []string {
+	var merged []string
+	for _, variable := range env {
+		name := strings.SplitN(variable, "=", 2)[0]
+		replaced := false
+		for _, override := range overrides {
+			replaced = replaced || strings.SplitN(override, "=", 2)[0] == name
+		}
+		if !replaced {
+			merged = append(merged, variable)
+		}
+	}
+	return append(merged, overrides...)
+}
+
+// ExecOptions sets how the command is run in the container and the streams it is attached to
+type ExecOptions struct {
+	// Replica is the container of the service to run in. Defaults to 1
+	Replica    int
+	Env        []string
+	WorkingDir string
+	User       string
+	Privileged bool
+	// Detach starts the command and returns without waiting for it to finish
+	Detach bool
+
+	// Tty allocates a pseudo-TTY. The output is not multiplexed so everything is written to Stdout
+	Tty bool
+	// Stdin is attached to the command when set. Stdout and Stderr default to discarding the output
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// Exec runs the command in a running container of the service and returns its exit code. The
+// exit code is 0 if Detach is set
+func (s Stack) Exec(ctx context.Context, client Client, service string, command []string, options ExecOptions) (int, error) {
+	if _, err := s.getService(service); err != nil {
+		return 0, err
+	}
+	if len(command) == 0 {
+		return 0, fmt.Errorf("exec requires a command")
+	}
+	replica := options.Replica
+	if replica == 0 {
+		replica = 1
+	}
+	containers, err := s.findContainers(ctx, client, service)
+	if err != nil {
+		return 0, err
+	}
+	c, exists := containers[replica]
+	if !exists {
+		return 0, fmt.Errorf("service %s does not have a container %d", service, replica)
+	}
+	name := containerName(*c)
+	if c.State != "running" {
+		return 0, fmt.Errorf("container %s is not running", name)
+	}
+
+	exec, err := client.ContainerExecCreate(ctx, c.ID, types.ExecConfig{
+		User:         options.User,
+		Privileged:   options.Privileged,
+		Tty:          options.Tty,
+		AttachStdin:  options.Stdin != nil && !options.Detach,
+		AttachStdout: !options.Detach,
+		AttachStderr: !options.Detach,
+		Detach:       options.Detach,
+		Env:          options.Env,
+		WorkingDir:   options.WorkingDir,
+		Cmd:          command,
+	})
+	if err != nil {
+		return 0, wrapError("container", name, err)
+	}
+	if options.Detach {
+		err := client.ContainerExecStart(ctx, exec.ID, types.ExecStartCheck{Detach: true, Tty: options.Tty})
+		return 0, wrapError("container", name, err)
+	}
+
+	attached, err := client.ContainerExecAttach(ctx, exec.ID, types.ExecStartCheck{Tty: options.Tty})
+	if err != nil {
+		return 0, wrapError("container", name, err)
+	}
+	defer attached.Close()
+	if err := copyStreams(attached, options.Tty, options.Stdin, options.Stdout, options.Stderr); err != nil {
+		return 0, wrapError("container", name, err)
+	}
+
+	// The output can end slightly before the daemon records the exit code
+	for {
+		inspect, err := client.ContainerExecInspect(ctx, exec.ID)
+		if err != nil {
+			return 0, wrapError("container", name, err)
+		}
+		if !inspect.Running {
+			return inspect.ExitCode, nil
+		}
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+// copyStreams sends stdin to the connection and copies the output until the connection ends. Without
+// a TTY the output is multiplexed and split into stdout and stderr
+func copyStreams(attached types.HijackedResponse, tty bool, stdin io.Reader, stdout, stderr io.Writer) error {
+	if stdout == nil {
+		stdout = ioutil.Discard
+	}
+	if stderr == nil {
+		stderr = ioutil.Discard
+	}
+	if stdin != nil {
+		go func() {
+			io.Copy(attached.Conn, stdin)
+			attached.CloseWrite()
+		}()
+	}
+
+	var err error
+	if tty {
+		_, err = io.Copy(stdout, attached.Reader)
+	} else {
+		_, err = stdcopy.StdCopy(stdout, stderr, attached.Reader)
+	}
+	return err
+}
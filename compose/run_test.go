@@ -0,0 +1,238 @@
+package compose_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/go-connections/nat"
+	"github.com/rmasp98/go-compose/compose"
+	"github.com/rmasp98/go-compose/compose/composetest"
+)
+
+// echoProcess writes the command to stdout, stdin in upper case to stdout and a warning to stderr
+func echoProcess(name string, command []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fmt.Fprintln(stdout, strings.Join(command, " "))
+	input, _ := ioutil.ReadAll(stdin)
+	stdout.Write(bytes.ToUpper(input))
+	fmt.Fprintln(stderr, "warning from "+name)
+	return 3
+}
+
+func TestRunStartsDependenciesAndReturnsExitCode(t *testing.T) {
+	client := newFakeClient()
+	client.AddImage("example/api:1.0")
+	client.SetProcess(echoProcess)
+	var stdout, stderr bytes.Buffer
+
+	result, err := newUpStack(t).Run(context.Background(), client, "api", []string{"migrate", "--all"}, compose.RunOptions{
+		Name: "app-api-migrate", Stdout: &stdout, Stderr: &stderr,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ExitCode != 3 || result.Name != "app-api-migrate" {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+	if stdout.String() != "migrate --all\n" || stderr.String() != "warning from app-api-migrate\n" {
+		t.Errorf("Unexpected output %q and %q", stdout.String(), stderr.String())
+	}
+
+	expected := []string{
+		"NetworkCreate app_backend",
+		"VolumeCreate app_data",
+		"ContainerCreate app-db-1",
+		"ContainerStart app-db-1",
+		"ContainerCreate app-api-migrate",
+		"ContainerStart app-api-migrate",
+	}
+	if err := verifyValue(expected, client.Calls()); err != nil {
+		t.Error(err)
+	}
+	c, exists := client.Container("app-api-migrate")
+	if !exists || c.State != "exited" || c.Labels[compose.OneoffLabel] != "True" || c.Labels[compose.ServiceLabel] != "api" {
+		t.Errorf("One-off container should be kept with the one-off label: %+v", c)
+	}
+}
+
+func TestRunCanSkipDependenciesAndRemoveContainer(t *testing.T) {
+	client := newFakeClient()
+	result, err := newUpStack(t).Run(context.Background(), client, "db", nil, compose.RunOptions{NoDeps: true, Remove: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(result.Name, "app-db-run-") {
+		t.Errorf("Unexpected generated name %s", result.Name)
+	}
+	if _, exists := client.Container(result.Name); exists {
+		t.Error("Container should be removed once it exits")
+	}
+	for _, call := range client.Calls() {
+		if strings.HasPrefix(call, "ContainerCreate") && call != "ContainerCreate "+result.Name {
+			t.Errorf("Only the one-off container should be created but got %s", call)
+		}
+	}
+}
+
+func TestRunIgnoresUnrelatedServices(t *testing.T) {
+	stack, err := compose.NewStack(parseYaml(`
+name: app
+services:
+  job:
+    image: busybox
+  web:
+    image: nginx
+    container_name: web
+    scale: 2
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := newFakeClient()
+	client.AddImage("busybox")
+
+	if _, err := stack.Run(context.Background(), client, "job", nil, compose.RunOptions{Name: "app-job-run", Detach: true}); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"NetworkCreate app_default", "ContainerCreate app-job-run", "ContainerStart app-job-run"}
+	if err := verifyValue(expected, client.Calls()); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRunAttachesStdinWithTty(t *testing.T) {
+	client := newFakeClient()
+	client.SetProcess(echoProcess)
+	var stdout bytes.Buffer
+	result, err := newUpStack(t).Run(context.Background(), client, "db", []string{"psql"}, compose.RunOptions{
+		NoDeps: true, Tty: true, Stdin: strings.NewReader("select 1;\n"), Stdout: &stdout,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ExitCode != 3 {
+		t.Errorf("Unexpected exit code %d", result.ExitCode)
+	}
+	if expected := "psql\nSELECT 1;\nwarning from " + result.Name + "\n"; stdout.String() != expected {
+		t.Errorf("TTY output should all be on stdout: %q should be %q", stdout.String(), expected)
+	}
+}
+
+const runCompose = `
+name: app
+services:
+  web:
+    image: nginx
+    entrypoint: ["/docker-entrypoint.sh"]
+    working_dir: /srv
+    restart: always
+    environment:
+      MODE: production
+      DEBUG: "false"
+    ports:
+      - "8080:80"
+`
+
+func TestRunOverridesServiceConfig(t *testing.T) {
+	stack, err := compose.NewStack(parseYaml(runCompose))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testData := map[string]struct {
+		options  compose.RunOptions
+		ports    nat.PortMap
+		validate func(*container.Config) bool
+	}{
+		"defaults": {compose.RunOptions{}, nil, func(config *container.Config) bool {
+			return config.WorkingDir == "/srv" && config.Entrypoint[0] == "/docker-entrypoint.sh"
+		}},
+		"overrides": {compose.RunOptions{Entrypoint: []string{"sh", "-c"}, WorkingDir: "/tmp", User: "root", Env: []string{"DEBUG=true"}}, nil, func(config *container.Config) bool {
+			return config.WorkingDir == "/tmp" && config.Entrypoint[0] == "sh" && config.User == "root" &&
+				verifyValue([]string{"MODE=production", "DEBUG=true"}, config.Env) == nil
+		}},
+		"servicePorts": {compose.RunOptions{ServicePorts: true}, nat.PortMap{"80/tcp": {{HostPort: "8080"}}}, func(config *container.Config) bool {
+			return true
+		}},
+	}
+
+	for name, data := range testData {
+		t.Run(name, func(t *testing.T) {
+			client := composetest.NewFakeClient()
+			data.options.Detach = true
+			result, err := stack.Run(context.Background(), client, "web", []string{"env"}, data.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			inspect, err := client.ContainerInspect(context.Background(), result.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if inspect.State.Status != "running" || inspect.Config.Cmd[0] != "env" || !data.validate(inspect.Config) {
+				t.Errorf("Unexpected config: %+v", inspect.Config)
+			}
+			if inspect.HostConfig.RestartPolicy.Name != "" {
+				t.Errorf("Restart policy should be removed but was %s", inspect.HostConfig.RestartPolicy.Name)
+			}
+			if err := verifyValue(data.ports, inspect.HostConfig.PortBindings); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestExecRunsInServiceContainer(t *testing.T) {
+	stack, client := newRunningStack(t)
+	client.SetProcess(echoProcess)
+	var stdout, stderr bytes.Buffer
+
+	exitCode, err := stack.Exec(context.Background(), client, "api", []string{"ls", "/data"}, compose.ExecOptions{
+		Stdin: strings.NewReader("input"), Stdout: &stdout, Stderr: &stderr,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exitCode != 3 || stdout.String() != "ls /data\nINPUT" || stderr.String() != "warning from app-api-1\n" {
+		t.Errorf("Unexpected exit code %d and output %q and %q", exitCode, stdout.String(), stderr.String())
+	}
+	if err := verifyValue([]string{"ContainerExecCreate app-api-1 ls /data"}, client.Calls()); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestExecCanDetach(t *testing.T) {
+	stack, client := newRunningStack(t)
+	exitCode, err := stack.Exec(context.Background(), client, "db", []string{"vacuumdb"}, compose.ExecOptions{Detach: true})
+	if err != nil || exitCode != 0 {
+		t.Errorf("Unexpected exit code %d and error %v", exitCode, err)
+	}
+}
+
+func TestExecRequiresRunningReplica(t *testing.T) {
+	stack, client := newRunningStack(t)
+	client.SetContainerState("app-db-1", "exited")
+
+	testData := map[string]struct {
+		service string
+		command []string
+		replica int
+	}{
+		"stopped":        {"db", []string{"ls"}, 0},
+		"missingReplica": {"api", []string{"ls"}, 2},
+		"unknownService": {"cache", []string{"ls"}, 0},
+		"noCommand":      {"api", nil, 0},
+	}
+
+	for name, data := range testData {
+		t.Run(name, func(t *testing.T) {
+			if _, err := stack.Exec(context.Background(), client, data.service, data.command, compose.ExecOptions{Replica: data.replica}); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+}
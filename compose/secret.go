@@ -0,0 +1,61 @@
+package compose
+
+import (
+	"fmt"
+)
+
+// Secret contains information from the secrets section of the compose file
+type Secret struct {
+	file        string
+	environment string
+	external    bool
+	name        string
+}
+
+// NewSecret creates a new secret based on an element of the secrets section of the compose file
+func NewSecret(config interface{}) (Secret, error) {
+	secret := Secret{}
+
+	if secretConfig, isMap := config.(map[string]interface{}); isMap {
+		mapping := []setValueMapping{
+			{"file", &secret.file, nil, nil},
+			{"environment", &secret.environment, nil, nil},
+			{"external", &secret.external, nil, nil},
+			{"name", &secret.name, nil, nil},
+		}
+		if err := setValues(mapping, secretConfig); err != nil {
+			return secret, err
+		}
+	} else {
+		return secret, fmt.Errorf("secret should be a map")
+	}
+
+	sources := 0
+	for _, isSet := range []bool{secret.file != "", secret.environment != "", secret.external} {
+		if isSet {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return secret, fmt.Errorf("secret should set exactly one of file, environment or external")
+	}
+
+	return secret, nil
+}
+
+// GetFile returns the path of the file containing the secret, or an empty string if not file based
+func (s Secret) GetFile() string {
+	return s.file
+}
+
+// GetEnvironment returns the environment variable containing the secret, or an empty string
+// if not environment based
+func (s Secret) GetEnvironment() string {
+	return s.environment
+}
+
+// GetExternalName will return name of external secret that has been created seperate to compose
+// If secret not external, it will return empty string and false.
+func (s Secret) GetExternalName() (string, bool) {
+	return s.name, s.external
+}
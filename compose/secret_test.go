@@ -0,0 +1,62 @@
+package compose_test
+
+import (
+	"testing"
+
+	"github.com/rmasp98/go-compose/compose"
+)
+
+func TestSecretConfigNotMapReturnsError(t *testing.T) {
+	for _, config := range []interface{}{nil, "invalid config"} {
+		if _, err := compose.NewSecret(config); err == nil {
+			t.Errorf("%v should return error but returned nothing", config)
+		}
+	}
+}
+
+func TestCanParseSecret(t *testing.T) {
+	secret, err := compose.NewSecret(map[string]interface{}{"file": "./secret.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret.GetFile() != "./secret.txt" {
+		t.Errorf("File should be \"./secret.txt\" but got \"%s\"", secret.GetFile())
+	}
+
+	secret, err = compose.NewSecret(map[string]interface{}{"environment": "TOKEN"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret.GetEnvironment() != "TOKEN" {
+		t.Errorf("Environment should be \"TOKEN\" but got \"%s\"", secret.GetEnvironment())
+	}
+}
+
+func TestReturnsNameForExternalSecret(t *testing.T) {
+	secret, _ := compose.NewSecret(map[string]interface{}{"external": true, "name": "Test"})
+	name, external := secret.GetExternalName()
+	if name != "Test" || !external {
+		t.Errorf("Name was not correct: \"%s\"", name)
+	}
+}
+
+func TestReturnsErrorIfSecretSourceNotSingular(t *testing.T) {
+	configs := []map[string]interface{}{
+		{},
+		{"file": "./secret.txt", "external": true},
+		{"file": "./secret.txt", "environment": "TOKEN"},
+	}
+	for _, config := range configs {
+		if _, err := compose.NewSecret(config); err == nil {
+			t.Errorf("%v should have returned an error but did not", config)
+		}
+	}
+}
+
+func TestReturnsErrorIfNotValidTypeInSecret(t *testing.T) {
+	for _, option := range []string{"file", "environment", "external", "name"} {
+		if _, err := compose.NewSecret(map[string]interface{}{option: 0}); err == nil {
+			t.Errorf("Should have returned an error for \"%s\" but returned nothing", option)
+		}
+	}
+}
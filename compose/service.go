@@ -2,10 +2,12 @@ package compose
 
 import (
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/docker/cli/cli/compose/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/mount"
 	networktypes "github.com/docker/docker/api/types/network"
@@ -15,23 +17,37 @@ import (
 	v1 "github.com/opencontainers/image-spec/specs-go/v1"
 )
 
-//TODO: not handled container_name, depends_on, deploy, env_file, build
+//TODO: not handled deploy, env_file, build
+
+// Conditions that can be used in the long syntax of depends_on
+const (
+	DependencyStarted   = "service_started"
+	DependencyHealthy   = "service_healthy"
+	DependencyCompleted = "service_completed_successfully"
+)
 
 type Service struct {
 	containerConfig container.Config
 	hostConfig      container.HostConfig
 	networkConfig   networktypes.NetworkingConfig
+	platform        v1.Platform
+	containerName   string
+	dependsOn       map[string]string
+	links           []string
+	volumes         []types.ServiceVolumeConfig
+	secrets         []types.ServiceSecretConfig
+	replicas        int
+
+	// unknownOptions are not in the compose specification so are ignored
+	unknownOptions []string
 
 	//build         BuildConfig
 	//configs       []string // This can also be in the long config format below
-	//containerName string   //not used...
 	//credentialSpec map[string]string //Windows specific
-	//dependsOn []string //this is probably used by me
 	// TODO: decide if store deploy info as it is swarm specific
 	//envFile       []string    // This should probably be used by me to populate environment
 	//isolation       string // windows specific
 	//profiles        []string                  //Not used...
-	//secrets         []Secret                  //Not used...
 	//mounts          []mount.Mount
 }
 
@@ -41,6 +57,15 @@ func NewService(yamlData interface{}) (Service, error) {
 	if !isMap {
 		return service, fmt.Errorf("yamlData was not a map[string]interface{}")
 	}
+	if _, hasEmptyOption := config[""]; hasEmptyOption {
+		return service, fmt.Errorf("service options cannot have an empty name")
+	}
+	for _, option := range sortedKeys(config) {
+		// Extension fields can contain anything
+		if !serviceOptions[option] && !strings.HasPrefix(option, "x-") {
+			service.unknownOptions = append(service.unknownOptions, option)
+		}
+	}
 
 	if err := service.parseContainerConfig(config); err != nil {
 		return service, err
@@ -54,6 +79,10 @@ func NewService(yamlData interface{}) (Service, error) {
 		return service, err
 	}
 
+	if err := service.parseServiceConfig(config); err != nil {
+		return service, err
+	}
+
 	return service, nil
 }
 
@@ -80,7 +109,7 @@ func (s *Service) parseContainerConfig(config map[string]interface{}) error {
 		NetworkDisabled: false,
 		OnBuild:         []string{}, // Don't think this is needed
 		Shell:           []string{}, //TODO figure out if this is needed
-		StopTimeout:     new(int),
+		StopTimeout:     nil,        // Daemon default applies unless stop_grace_period is set
 	}
 
 	// TODO: add validation functions
@@ -97,10 +126,10 @@ func (s *Service) parseContainerConfig(config map[string]interface{}) error {
 		{"stdin_open", &s.containerConfig.OpenStdin, nil, nil},
 		{"environment", &s.containerConfig.Env, convertToStringList, nil},
 		{"labels", &s.containerConfig.Labels, convertToStringMap, nil},
-		{"command", &s.containerConfig.Cmd, convertToStringList, nil},
-		{"entrypoint", &s.containerConfig.Entrypoint, convertToStringList, nil},
+		{"command", &s.containerConfig.Cmd, convertToCommand, nil},
+		{"entrypoint", &s.containerConfig.Entrypoint, convertToCommand, nil},
 		{"volumes", &s.containerConfig.Volumes, convertVolumes, nil},
-		{"stop_grace_period", s.containerConfig.StopTimeout, convertStopTimeout, nil},
+		{"stop_grace_period", &s.containerConfig.StopTimeout, convertStopTimeout, nil},
 		{"healthcheck", s.containerConfig.Healthcheck, convertHealthCheck, nil},
 	}
 	if err := setValues(mapping, config); err != nil {
@@ -146,9 +175,9 @@ func (s *Service) parseHostConfig(config map[string]interface{}) error {
 		{"restart", &s.hostConfig.RestartPolicy, convertRestartPolicy, nil},
 		{"cap_add", &s.hostConfig.CapAdd, convertToStringList, nil},
 		{"cap_drop", &s.hostConfig.CapDrop, convertToStringList, nil},
-		{"dns", &s.hostConfig.DNS, convertToStringList, nil},
-		{"dns_search", &s.hostConfig.DNSSearch, convertToStringList, nil},
-		{"extra_hosts", &s.hostConfig.ExtraHosts, convertToStringList, nil},
+		{"dns", &s.hostConfig.DNS, convertToStringOrList, nil},
+		{"dns_search", &s.hostConfig.DNSSearch, convertToStringOrList, nil},
+		{"extra_hosts", &s.hostConfig.ExtraHosts, convertExtraHosts, nil},
 		{"ipc", &s.hostConfig.IpcMode, convertIpc, nil},
 		{"pid", &s.hostConfig.PidMode, convertPid, nil},
 		{"external_links", &s.hostConfig.Links, convertToStringList, nil},
@@ -173,15 +202,18 @@ func (s *Service) parseHostConfig(config map[string]interface{}) error {
 }
 
 func (s *Service) parseNetworkConfig(input map[string]interface{}) error {
-	links, err := parseStringList(input["links"])
-	if err != nil {
-		return nil
+	var links []string
+	if config, isSet := input["links"]; isSet {
+		var err error
+		if links, err = parseStringList(config); err != nil {
+			return fmt.Errorf("links: %s", err.Error())
+		}
 	}
 
 	if config, isSet := input["networks"]; isSet {
-		networks, isMap := config.(map[string]interface{})
-		if !isMap {
-			return fmt.Errorf("networks should be a map")
+		networks, err := convertServiceNetworks(config)
+		if err != nil {
+			return err
 		}
 
 		endpoints := make(map[string]*networktypes.EndpointSettings)
@@ -203,8 +235,8 @@ func (s *Service) parseNetworkConfig(input map[string]interface{}) error {
 				// TODO: add validation functions
 				mapping := []setValueMapping{
 					{"aliases", &endpoint.Aliases, convertToStringList, nil},
-					{"ipv4_address", &endpoint.IPAddress, nil, nil},
-					{"ipv6_address", &endpoint.GlobalIPv6Address, nil, nil},
+					{"ipv4_address", &endpoint.IPAddress, nil, validateIP},
+					{"ipv6_address", &endpoint.GlobalIPv6Address, nil, validateIP},
 				}
 				if err := setValues(mapping, network); err != nil {
 					return err
@@ -221,15 +253,266 @@ func (s *Service) parseNetworkConfig(input map[string]interface{}) error {
 	return nil
 }
 
-// TODO: retrieve this information
+// convertServiceNetworks converts the list form of networks into the map form
+func convertServiceNetworks(input interface{}) (map[string]interface{}, error) {
+	switch input := input.(type) {
+	case map[string]interface{}:
+		return input, nil
+	case []interface{}:
+		names, err := parseStringList(input)
+		if err != nil {
+			return nil, fmt.Errorf("networks: %s", err.Error())
+		}
+		networks := make(map[string]interface{})
+		for _, name := range names {
+			networks[name] = nil
+		}
+		return networks, nil
+	}
+	return nil, fmt.Errorf("networks should be a list or a map")
+}
+
+// GetPlatformConfig returns the platform set by the service. If platform was not
+// set then the OS and Architecture will be empty
 func (s Service) GetPlatformConfig() v1.Platform {
-	return v1.Platform{
-		Architecture: "",
-		OS:           "",
-		OSVersion:    "",
-		OSFeatures:   []string{},
-		Variant:      "",
+	return s.platform
+}
+
+// GetContainerName returns the name set by container_name, or an empty string if not set
+func (s Service) GetContainerName() string {
+	return s.containerName
+}
+
+// GetDependencies returns the services this service depends on mapped to the
+// condition that must be met before this service can be started
+func (s Service) GetDependencies() map[string]string {
+	dependencies := make(map[string]string, len(s.dependsOn))
+	for name, condition := range s.dependsOn {
+		dependencies[name] = condition
 	}
+	return dependencies
+}
+
+// GetLinks returns the links to other services in the form service[:alias]
+func (s Service) GetLinks() []string {
+	return s.links
+}
+
+// GetVolumes returns all the volumes mounted by the service as they were defined in the compose file
+func (s Service) GetVolumes() []types.ServiceVolumeConfig {
+	return s.volumes
+}
+
+// GetSecrets returns the secrets that are granted to the service
+func (s Service) GetSecrets() []types.ServiceSecretConfig {
+	return s.secrets
+}
+
+// GetReplicas returns the number of containers to run for the service
+func (s Service) GetReplicas() int {
+	return s.replicas
+}
+
+// GetNetworkModeService returns the name of the service whose network stack is
+// shared through network_mode: service:<name>
+func (s Service) GetNetworkModeService() (string, bool) {
+	mode := string(s.hostConfig.NetworkMode)
+	if strings.HasPrefix(mode, "service:") {
+		return strings.TrimPrefix(mode, "service:"), true
+	}
+	return "", false
+}
+
+// parseServiceConfig parses options that are used by compose rather than passed to the docker API
+func (s *Service) parseServiceConfig(config map[string]interface{}) error {
+	s.dependsOn = make(map[string]string)
+	s.replicas = 1
+	mapping := []setValueMapping{
+		{"platform", &s.platform, convertPlatform, nil},
+		{"container_name", &s.containerName, nil, nil},
+		{"depends_on", &s.dependsOn, convertDependsOn, nil},
+		{"links", &s.links, convertToStringList, nil},
+		{"volumes", &s.volumes, convertServiceVolumes, nil},
+		{"secrets", &s.secrets, convertServiceSecrets, nil},
+		{"scale", &s.replicas, nil, validateReplicas},
+	}
+	if err := setValues(mapping, config); err != nil {
+		return err
+	}
+	if err := s.parseDeployReplicas(config); err != nil {
+		return err
+	}
+
+	if name, isService := s.GetNetworkModeService(); isService {
+		// Sharing the network stack of another service means these cannot be configured
+		for _, option := range []string{"networks", "ports", "hostname", "dns", "mac_address"} {
+			if _, isSet := config[option]; isSet {
+				return fmt.Errorf("%s cannot be used with network_mode: service:%s", option, name)
+			}
+		}
+		if _, exists := s.dependsOn[name]; !exists {
+			s.dependsOn[name] = DependencyStarted
+		}
+	}
+
+	return nil
+}
+
+// parseDeployReplicas reads deploy.replicas which is an alternative to scale. Only replicas is
+// used from deploy as the rest is for swarm
+func (s *Service) parseDeployReplicas(config map[string]interface{}) error {
+	deploy, isSet := config["deploy"]
+	if !isSet {
+		return nil
+	}
+	deployConfig, isMap := deploy.(map[string]interface{})
+	if !isMap {
+		return fmt.Errorf("deploy should be a map")
+	}
+
+	replicas := -1
+	if err := setValue(&replicas, "replicas", deployConfig, nil, validateReplicas); err != nil {
+		return fmt.Errorf("deploy: %s", err.Error())
+	}
+	if replicas >= 0 {
+		if _, hasScale := config["scale"]; hasScale && replicas != s.replicas {
+			return fmt.Errorf("scale and deploy.replicas are both set but differ")
+		}
+		s.replicas = replicas
+	}
+	return nil
+}
+
+// verifyScalable returns an error if the service has more than one replica but sets
+// something that must be unique to a single container
+func (s Service) verifyScalable() error {
+	if s.replicas <= 1 {
+		return nil
+	}
+	if s.containerName != "" {
+		return fmt.Errorf("%d replicas cannot share container_name %s", s.replicas, s.containerName)
+	}
+	for _, port := range sortedKeys(s.hostConfig.PortBindings) {
+		for _, binding := range s.hostConfig.PortBindings[nat.Port(port)] {
+			if binding.HostPort != "" && !strings.Contains(binding.HostPort, "-") {
+				return fmt.Errorf("%d replicas cannot publish %s on fixed host port %s", s.replicas, port, binding.HostPort)
+			}
+		}
+	}
+	return nil
+}
+
+func validateReplicas(input interface{}) error {
+	if replicas, isInt := input.(int); !isInt || replicas < 0 {
+		return fmt.Errorf("replicas must be an integer of 0 or more")
+	}
+	return nil
+}
+
+// serviceOptions is every service option in the compose specification, even those that are ignored
+var serviceOptions = map[string]bool{
+	"annotations": true, "attach": true, "blkio_config": true, "build": true, "cap_add": true, "cap_drop": true,
+	"cgroup": true, "cgroup_parent": true, "command": true, "configs": true, "container_name": true,
+	"cpu_count": true, "cpu_percent": true, "cpu_period": true, "cpu_quota": true, "cpu_rt_period": true,
+	"cpu_rt_runtime": true, "cpu_shares": true, "cpus": true, "cpuset": true, "credential_spec": true,
+	"depends_on": true, "deploy": true, "develop": true, "device_cgroup_rules": true, "devices": true, "dns": true,
+	"dns_opt": true, "dns_search": true, "domainname": true, "entrypoint": true, "env_file": true,
+	"environment": true, "expose": true, "extends": true, "external_links": true, "extra_hosts": true,
+	"gpus": true, "group_add": true, "healthcheck": true, "hostname": true, "image": true, "init": true,
+	"ipc": true, "isolation": true, "label_file": true, "labels": true, "links": true, "logging": true,
+	"mac_address": true, "mem_limit": true, "mem_reservation": true, "mem_swappiness": true,
+	"memswap_limit": true, "network_mode": true, "networks": true, "oom_kill_disable": true,
+	"oom_score_adj": true, "pid": true, "pids_limit": true, "platform": true, "ports": true, "post_start": true,
+	"pre_stop": true, "privileged": true, "profiles": true, "pull_policy": true, "read_only": true,
+	"restart": true, "runtime": true, "scale": true, "secrets": true, "security_opt": true, "shm_size": true,
+	"stdin_open": true, "stop_grace_period": true, "stop_signal": true, "storage_opt": true, "sysctls": true,
+	"tmpfs": true, "tty": true, "ulimits": true, "user": true, "userns_mode": true, "uts": true,
+	"volumes": true, "volumes_from": true, "working_dir": true,
+}
+
+func convertServiceVolumes(input interface{}) (interface{}, error) {
+	return parseVolumes(input)
+}
+
+func convertServiceSecrets(input interface{}) (interface{}, error) {
+	config, isList := input.([]interface{})
+	if !isList {
+		return nil, fmt.Errorf("should be a list")
+	}
+
+	secrets := []types.ServiceSecretConfig{}
+	for _, element := range config {
+		switch element := element.(type) {
+		case string:
+			secrets = append(secrets, types.ServiceSecretConfig{Source: element})
+		case map[string]interface{}:
+			secret := types.ServiceSecretConfig{}
+			mapping := []setValueMapping{
+				{"source", &secret.Source, nil, nil},
+				{"target", &secret.Target, nil, nil},
+				{"uid", &secret.UID, nil, nil},
+				{"gid", &secret.GID, nil, nil},
+				{"mode", &secret.Mode, convertFileMode, nil},
+			}
+			if err := setValues(mapping, element); err != nil {
+				return nil, err
+			}
+			if secret.Source == "" {
+				return nil, fmt.Errorf("source must be set")
+			}
+			secrets = append(secrets, secret)
+		default:
+			return nil, fmt.Errorf("should be a string or a map")
+		}
+	}
+	return secrets, nil
+}
+
+func convertFileMode(input interface{}) (interface{}, error) {
+	if mode, isInt := input.(int); isInt && mode >= 0 {
+		fileMode := uint32(mode)
+		return &fileMode, nil
+	}
+	return nil, fmt.Errorf("should be a positive integer")
+}
+
+func convertDependsOn(input interface{}) (interface{}, error) {
+	dependencies := make(map[string]string)
+	switch input := input.(type) {
+	case []interface{}:
+		services, err := parseStringList(input)
+		if err != nil {
+			return nil, err
+		}
+		for _, service := range services {
+			dependencies[service] = DependencyStarted
+		}
+	case map[string]interface{}:
+		for service, config := range input {
+			condition := DependencyStarted
+			switch config := config.(type) {
+			case map[string]interface{}:
+				if err := setValue(&condition, "condition", config, nil, validateCondition); err != nil {
+					return nil, fmt.Errorf("%s - %s", service, err.Error())
+				}
+			case nil:
+			default:
+				return nil, fmt.Errorf("%s should be a map", service)
+			}
+			dependencies[service] = condition
+		}
+	default:
+		return nil, fmt.Errorf("should be a list or a map")
+	}
+	return dependencies, nil
+}
+
+func validateCondition(input interface{}) error {
+	switch input {
+	case DependencyStarted, DependencyHealthy, DependencyCompleted:
+		return nil
+	}
+	return fmt.Errorf("%v is not a valid condition", input)
 }
 
 func convertStopTimeout(input interface{}) (interface{}, error) {
@@ -237,9 +520,8 @@ func convertStopTimeout(input interface{}) (interface{}, error) {
 	if err != nil {
 		return nil, err
 	}
-	timeout := duration.(time.Duration)
-	return int(timeout.Seconds()), nil
-
+	seconds := int(duration.(time.Duration).Seconds())
+	return &seconds, nil
 }
 
 func convertVolumes(input interface{}) (interface{}, error) {
@@ -272,22 +554,72 @@ func convertExposed(input interface{}) (interface{}, error) {
 func convertHealthCheck(input interface{}) (interface{}, error) {
 	if hc, isMap := input.(map[string]interface{}); isMap {
 		hcOut := container.HealthConfig{}
-		// TODO: add validation functions
+		var disable bool
+		var startInterval time.Duration
 		mapping := []setValueMapping{
-			{"test", &hcOut.Test, convertToStringList, nil},
+			{"test", &hcOut.Test, convertHealthCheckTest, nil},
 			{"interval", &hcOut.Interval, convertDuration, nil},
 			{"timeout", &hcOut.Timeout, convertDuration, nil},
 			{"start_period", &hcOut.StartPeriod, convertDuration, nil},
-			{"retries", &hcOut.Retries, nil, nil},
+			// start_interval is not supported by this API version so is only type checked
+			{"start_interval", &startInterval, convertDuration, nil},
+			{"retries", &hcOut.Retries, nil, validateRetries},
+			{"disable", &disable, nil, nil},
 		}
 		if err := setValues(mapping, hc); err != nil {
 			return nil, err
 		}
+
+		if disable {
+			if len(hcOut.Test) > 0 && hcOut.Test[0] != "NONE" {
+				return nil, fmt.Errorf("test cannot be set when disable is true")
+			}
+			hcOut.Test = []string{"NONE"}
+		}
 		return hcOut, nil
 	}
 	return nil, nil
 }
 
+// convertHealthCheckTest converts the string form into a CMD-SHELL test and
+// ensures the list form starts with a valid test type
+func convertHealthCheckTest(input interface{}) (interface{}, error) {
+	if test, isStr := input.(string); isStr {
+		if strings.TrimSpace(test) == "" {
+			return nil, fmt.Errorf("should not be empty")
+		}
+		return []string{"CMD-SHELL", test}, nil
+	}
+
+	test, err := parseStringList(input)
+	if err != nil {
+		return nil, err
+	}
+	if len(test) == 0 {
+		return nil, fmt.Errorf("should not be empty")
+	}
+	switch test[0] {
+	case "NONE":
+		if len(test) > 1 {
+			return nil, fmt.Errorf("NONE does not take any arguments")
+		}
+	case "CMD", "CMD-SHELL":
+		if len(test) == 1 {
+			return nil, fmt.Errorf("%s requires a command", test[0])
+		}
+	default:
+		return nil, fmt.Errorf("should start with CMD, CMD-SHELL or NONE")
+	}
+	return test, nil
+}
+
+func validateRetries(input interface{}) error {
+	if retries, isInt := input.(int); !isInt || retries < 0 {
+		return fmt.Errorf("retries should be a non-negative integer")
+	}
+	return nil
+}
+
 func convertDevices(input interface{}) (interface{}, error) {
 	config, err := parseStringList(input)
 	if err != nil {
@@ -309,9 +641,26 @@ func convertDevices(input interface{}) (interface{}, error) {
 }
 
 func convertPortBindings(input interface{}) (interface{}, error) {
-	config, err := parseStringList(input)
-	if err != nil {
-		return nil, err
+	ports, isList := input.([]interface{})
+	if !isList {
+		return nil, fmt.Errorf("should be a list")
+	}
+
+	config := []string{}
+	for _, port := range ports {
+		if port, isMap := port.(map[string]interface{}); isMap {
+			spec, err := parseLongPortSyntax(port)
+			if err != nil {
+				return nil, err
+			}
+			config = append(config, spec)
+			continue
+		}
+		spec, err := getString(port)
+		if err != nil {
+			return nil, err
+		}
+		config = append(config, spec)
 	}
 
 	_, bindings, err := nat.ParsePortSpecs(config)
@@ -321,6 +670,37 @@ func convertPortBindings(input interface{}) (interface{}, error) {
 	return bindings, nil
 }
 
+// parseLongPortSyntax converts the long syntax into the short syntax understood by nat.ParsePortSpecs
+func parseLongPortSyntax(config map[string]interface{}) (string, error) {
+	var target, published, hostIP, protocol string
+	mapping := []setValueMapping{
+		{"target", &target, convertToString, nil},
+		{"published", &published, convertToString, nil},
+		{"host_ip", &hostIP, nil, validateIP},
+		{"protocol", &protocol, nil, nil},
+		// mode is only used by swarm
+		{"mode", new(string), nil, nil},
+	}
+	if err := setValues(mapping, config); err != nil {
+		return "", err
+	}
+	if target == "" {
+		return "", fmt.Errorf("port target must be set")
+	}
+
+	spec := target
+	if published != "" || hostIP != "" {
+		spec = published + ":" + spec
+	}
+	if hostIP != "" {
+		spec = hostIP + ":" + spec
+	}
+	if protocol != "" {
+		spec += "/" + protocol
+	}
+	return spec, nil
+}
+
 func convertShmSize(input interface{}) (interface{}, error) {
 	if size, isStr := input.(string); isStr {
 		return units.FromHumanSize(size)
@@ -336,21 +716,39 @@ func convertUserNs(input interface{}) (interface{}, error) {
 }
 
 func convertTmpfs(input interface{}) (interface{}, error) {
-	config, err := parseStringList(input)
+	config, err := parseStringOrList(input)
 	if err != nil {
 		return nil, err
 	}
 
 	tmpfs := make(map[string]string)
 	for _, mount := range config {
-		options := strings.Split(mount, ":")
+		options := strings.SplitN(mount, ":", 2)
 		if len(options) == 2 {
 			tmpfs[options[0]] = options[1]
+		} else {
+			tmpfs[options[0]] = ""
 		}
 	}
 	return tmpfs, nil
 }
 
+func convertExtraHosts(input interface{}) (interface{}, error) {
+	if config, isMap := input.(map[string]interface{}); isMap {
+		hosts := []string{}
+		for host, address := range config {
+			address, err := getString(address)
+			if err != nil {
+				return nil, fmt.Errorf("%s - %s", host, err.Error())
+			}
+			hosts = append(hosts, host+":"+address)
+		}
+		sort.Strings(hosts)
+		return hosts, nil
+	}
+	return parseStringList(input)
+}
+
 func convertIpc(input interface{}) (interface{}, error) {
 	if mode, isStr := input.(string); isStr {
 		return container.IpcMode(mode), nil
@@ -383,6 +781,9 @@ func convertRestartPolicy(input interface{}) (interface{}, error) {
 
 func convertNetworkMode(input interface{}) (interface{}, error) {
 	if mode, isStr := input.(string); isStr {
+		if mode == "service:" {
+			return nil, fmt.Errorf("service name missing from %s", mode)
+		}
 		return container.NetworkMode(mode), nil
 	}
 	return nil, fmt.Errorf("should have been string")
@@ -421,8 +822,8 @@ func convertBinds(input interface{}) (interface{}, error) {
 func convertUlimits(input interface{}) (interface{}, error) {
 	if config, isMap := input.(map[string]interface{}); isMap {
 		ulimits := []*units.Ulimit{}
-		for name, limits := range config {
-			switch limits := limits.(type) {
+		for _, name := range sortedKeys(config) {
+			switch limits := config[name].(type) {
 			case int:
 				ulimits = append(ulimits, &units.Ulimit{Name: name, Hard: int64(limits), Soft: int64(limits)})
 			case map[string]interface{}:
@@ -88,6 +88,214 @@ func TestCanParseNetworkConfig(t *testing.T) {
 	}
 }
 
+func TestCanParseHealthCheckTestForms(t *testing.T) {
+	tests := []verifyMapping{
+		{"test", "echo \"hello world\"", []string{"CMD-SHELL", "echo \"hello world\""}},
+		{"test", []interface{}{"CMD", "curl", "-f", "http://localhost"}, []string{"CMD", "curl", "-f", "http://localhost"}},
+		{"test", []interface{}{"CMD-SHELL", "curl -f http://localhost"}, []string{"CMD-SHELL", "curl -f http://localhost"}},
+		{"test", []interface{}{"NONE"}, []string{"NONE"}},
+		{"disable", true, []string{"NONE"}},
+	}
+	for _, mapping := range tests {
+		service, err := compose.NewService(map[string]interface{}{
+			"healthcheck": map[string]interface{}{mapping.name: mapping.source},
+		})
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if err := verifyValue(mapping.expected, service.GetContainerConfig().Healthcheck.Test); err != nil {
+			t.Errorf("%v: %s", mapping.source, err.Error())
+		}
+	}
+}
+
+func TestReturnsErrorForInvalidHealthCheck(t *testing.T) {
+	healthchecks := []map[string]interface{}{
+		{"test": []interface{}{"curl", "-f"}},
+		{"test": []interface{}{}},
+		{"test": ""},
+		{"test": "  "},
+		{"test": []interface{}{"CMD"}},
+		{"test": []interface{}{"NONE", "curl"}},
+		{"test": []interface{}{"CMD", "curl"}, "disable": true},
+		{"retries": "3"},
+		{"retries": -1},
+		{"start_interval": 5},
+		{"start_interval": "invalid"},
+	}
+	for _, healthcheck := range healthchecks {
+		if _, err := compose.NewService(map[string]interface{}{"healthcheck": healthcheck}); err == nil {
+			t.Errorf("%v should have returned an error but did not", healthcheck)
+		}
+	}
+}
+
+func TestCommandIsSplitUsingShellRules(t *testing.T) {
+	tests := []verifyMapping{
+		{"command", "sh -c \"echo hello && sleep 5\"", strslice.StrSlice{"sh", "-c", "echo hello && sleep 5"}},
+		{"command", "echo 'single quoted' escaped\\ space", strslice.StrSlice{"echo", "single quoted", "escaped space"}},
+		{"command", "", strslice.StrSlice{}},
+		{"command", []interface{}{}, strslice.StrSlice{}},
+		{"command", "echo a # b", strslice.StrSlice{"echo", "a", "#", "b"}},
+		{"command", "echo \"a \\\"b\\\" \\c\" '\\d' '' x\\\ny", strslice.StrSlice{"echo", "a \"b\" \\c", "\\d", "", "xy"}},
+		{"entrypoint", "/entrypoint.sh --name \"my app\"", strslice.StrSlice{"/entrypoint.sh", "--name", "my app"}},
+		{"entrypoint", "", strslice.StrSlice{}},
+	}
+	for _, mapping := range tests {
+		service, err := compose.NewService(map[string]interface{}{mapping.name: mapping.source})
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if err := verifyContainerConfig(mapping.name, mapping.expected, service.GetContainerConfig()); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestReturnsErrorForUnterminatedQuotesInCommand(t *testing.T) {
+	for _, command := range []string{"echo 'a", "echo \"a", "echo \"a\\\""} {
+		if _, err := compose.NewService(map[string]interface{}{"command": command}); err == nil {
+			t.Errorf("%q should have returned an error but did not", command)
+		}
+	}
+}
+
+func TestCanParseBareStringForStringOrListOptions(t *testing.T) {
+	tests := []verifyMapping{
+		{"dns", "8.8.8.8", []string{"8.8.8.8"}},
+		{"dns_search", "example.com", []string{"example.com"}},
+		{"tmpfs", "/run", map[string]string{"/run": ""}},
+		{"extra_hosts", map[string]interface{}{"somehost": "162.242.195.82"}, []string{"somehost:162.242.195.82"}},
+	}
+	for _, mapping := range tests {
+		service, err := compose.NewService(map[string]interface{}{mapping.name: mapping.source})
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if err := verifyHostConfig(mapping.name, mapping.expected, service.GetHostConfig()); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestReturnsErrorForBareStringInListOnlyOptions(t *testing.T) {
+	for _, option := range []string{"cap_add", "cap_drop", "security_opt", "external_links", "expose", "ports", "devices"} {
+		if _, err := compose.NewService(map[string]interface{}{option: "some value"}); err == nil {
+			t.Errorf("%s should have returned an error but did not", option)
+		}
+	}
+}
+
+func TestReturnsErrorForUnterminatedQuoteInCommand(t *testing.T) {
+	if _, err := compose.NewService(map[string]interface{}{"command": "echo \"hello"}); err == nil {
+		t.Errorf("Should have returned an error but did not")
+	}
+}
+
+func TestCanParseDependsOn(t *testing.T) {
+	tests := []verifyMapping{
+		{"depends_on", []interface{}{"db", "redis"}, map[string]string{"db": compose.DependencyStarted, "redis": compose.DependencyStarted}},
+		{"depends_on", map[string]interface{}{"db": map[string]interface{}{"condition": "service_healthy"}, "redis": nil},
+			map[string]string{"db": compose.DependencyHealthy, "redis": compose.DependencyStarted}},
+	}
+	for _, mapping := range tests {
+		service, err := compose.NewService(map[string]interface{}{mapping.name: mapping.source})
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if err := verifyValue(mapping.expected, service.GetDependencies()); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestReturnsErrorForInvalidDependsOn(t *testing.T) {
+	for _, dependsOn := range []interface{}{"db", map[string]interface{}{"db": map[string]interface{}{"condition": "invalid"}}, map[string]interface{}{"db": 0}} {
+		if _, err := compose.NewService(map[string]interface{}{"depends_on": dependsOn}); err == nil {
+			t.Errorf("%v should have returned an error but did not", dependsOn)
+		}
+	}
+}
+
+func TestNetworkModeServiceAddsDependency(t *testing.T) {
+	service, err := compose.NewService(map[string]interface{}{"network_mode": "service:db"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyValue(map[string]string{"db": compose.DependencyStarted}, service.GetDependencies()); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReturnsErrorForOptionsConflictingWithNetworkModeService(t *testing.T) {
+	for _, option := range []string{"networks", "ports", "hostname", "dns", "mac_address"} {
+		if _, err := compose.NewService(map[string]interface{}{"network_mode": "service:db", option: nil}); err == nil {
+			t.Errorf("%s should have returned an error but did not", option)
+		}
+	}
+}
+
+func TestCanParseNetworksAsList(t *testing.T) {
+	service, err := compose.NewService(map[string]interface{}{"networks": []interface{}{"frontend", "backend"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	endpoints := service.GetNetworkConfig().EndpointsConfig
+	if _, isSet := endpoints["frontend"]; !isSet || len(endpoints) != 2 {
+		t.Errorf("Networks were not parsed: %v", endpoints)
+	}
+}
+
+func TestCanParseLongSyntaxVolumes(t *testing.T) {
+	service, err := compose.NewService(map[string]interface{}{"volumes": []interface{}{
+		"./static:/var/www",
+		map[string]interface{}{"type": "volume", "source": "data", "target": "/data", "volume": map[string]interface{}{"nocopy": true}},
+		map[string]interface{}{"type": "bind", "source": "/opt", "target": "/opt", "bind": map[string]interface{}{"propagation": "rshared"}},
+		map[string]interface{}{"type": "tmpfs", "target": "/tmp", "tmpfs": map[string]interface{}{"size": "1m"}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	volumes := service.GetVolumes()
+	if len(volumes) != 4 {
+		t.Fatalf("Should have parsed 4 volumes but got %d", len(volumes))
+	}
+	if volumes[1].Source != "data" || !volumes[1].Volume.NoCopy {
+		t.Errorf("Volume was not parsed correctly: %v", volumes[1])
+	}
+	if volumes[2].Bind.Propagation != "rshared" {
+		t.Errorf("Bind propagation should be rshared but got %s", volumes[2].Bind.Propagation)
+	}
+	if volumes[3].Tmpfs.Size != 1048576 {
+		t.Errorf("Tmpfs size should be 1048576 but got %d", volumes[3].Tmpfs.Size)
+	}
+}
+
+func TestCanParseServiceSecrets(t *testing.T) {
+	service, err := compose.NewService(map[string]interface{}{"secrets": []interface{}{
+		"token", map[string]interface{}{"source": "password", "target": "db_password", "mode": 0440},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	secrets := service.GetSecrets()
+	if len(secrets) != 2 || secrets[0].Source != "token" || secrets[1].Target != "db_password" || *secrets[1].Mode != 0440 {
+		t.Errorf("Secrets were not parsed correctly: %v", secrets)
+	}
+}
+
+func TestReturnsErrorForInvalidServiceSecrets(t *testing.T) {
+	for _, secrets := range []interface{}{"token", []interface{}{0}, []interface{}{map[string]interface{}{"target": "x"}}} {
+		if _, err := compose.NewService(map[string]interface{}{"secrets": secrets}); err == nil {
+			t.Errorf("%v should have returned an error but did not", secrets)
+		}
+	}
+}
+
 // TODO: figure out if we still need this test
 //func TestReturnsErrorForInvalidTypeInNetworkConfig(t *testing.T) {
 //	for name := range getNetworkMapping() {
@@ -360,3 +568,36 @@ func verifyValue(expected, actual interface{}) error {
 	}
 	return nil
 }
+
+func TestCanParseReplicas(t *testing.T) {
+	tests := []verifyMapping{
+		{"default", map[string]interface{}{}, 1},
+		{"scale", map[string]interface{}{"scale": 3}, 3},
+		{"deploy", map[string]interface{}{"deploy": map[string]interface{}{"mode": "replicated", "replicas": 0}}, 0},
+		{"both", map[string]interface{}{"scale": 2, "deploy": map[string]interface{}{"replicas": 2}}, 2},
+	}
+	for _, mapping := range tests {
+		service, err := compose.NewService(mapping.source)
+		if err != nil {
+			t.Errorf("%s: %s", mapping.name, err.Error())
+			continue
+		}
+		if err := verifyValue(mapping.expected, service.GetReplicas()); err != nil {
+			t.Errorf("%s: %s", mapping.name, err.Error())
+		}
+	}
+}
+
+func TestReturnsErrorForInvalidReplicas(t *testing.T) {
+	for _, config := range []map[string]interface{}{
+		{"scale": -1},
+		{"scale": "2"},
+		{"deploy": map[string]interface{}{"replicas": "two"}},
+		{"deploy": "replicated"},
+		{"scale": 1, "deploy": map[string]interface{}{"replicas": 2}},
+	} {
+		if _, err := compose.NewService(config); err == nil {
+			t.Errorf("%v should have returned an error but did not", config)
+		}
+	}
+}
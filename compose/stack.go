@@ -2,27 +2,90 @@ package compose
 
 import (
 	"fmt"
+	"regexp"
 	"strconv"
+	"strings"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
+	networktypes "github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/api/types/volume"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
 )
 
-// TODO: Services, configs
+// TODO: configs
 
 type Stack struct {
 	services map[string]Service
 	networks map[string]Network
 	volumes  map[string]Volume
+	secrets  map[string]Secret
+	warnings []ValidationIssue
+
+	// source and options are kept so the Stack can be rebuilt by a Builder
+	source  map[string]interface{}
+	options []StackOption
+
+	projectName     string
+	defaultPlatform v1.Platform
+	scale           map[string]int
+}
+
+const defaultProjectName = "default"
+
+// StackOption configures settings of the Stack that are not part of the compose file
+type StackOption func(*Stack) error
+
+// WithProjectName sets the project name, overriding the name set in the compose file
+func WithProjectName(name string) StackOption {
+	return func(s *Stack) error {
+		var err error
+		s.projectName, err = normaliseProjectName(name)
+		return err
+	}
+}
+
+// WithDefaultPlatform sets the platform used by services that do not specify one
+func WithDefaultPlatform(platform string) StackOption {
+	return func(s *Stack) error {
+		var err error
+		if s.defaultPlatform, err = ParsePlatform(platform); err != nil {
+			return fmt.Errorf("default platform: %s", err.Error())
+		}
+		return nil
+	}
 }
 
-func NewStack(composeData interface{}) (Stack, error) {
-	config := composeData.(map[string]interface{})
+// WithScale overrides the number of replicas of the service set by scale or deploy.replicas
+func WithScale(service string, replicas int) StackOption {
+	return func(s *Stack) error {
+		if replicas < 0 {
+			return fmt.Errorf("scale of %s must be 0 or more", service)
+		}
+		if s.scale == nil {
+			s.scale = make(map[string]int)
+		}
+		s.scale[service] = replicas
+		return nil
+	}
+}
+
+func NewStack(composeData interface{}, options ...StackOption) (Stack, error) {
+	config, isMap := composeData.(map[string]interface{})
+	if !isMap {
+		return Stack{}, fmt.Errorf("compose file should be a map")
+	}
 	if err := verifyVersion(config); err != nil {
 		return Stack{}, err
 	}
 
+	stack := Stack{source: copyTree(config).(map[string]interface{}), options: options}
+	for _, option := range options {
+		if err := option(&stack); err != nil {
+			return Stack{}, err
+		}
+	}
+
 	services := make(map[string]Service)
 	if err := parseConfig("services", config, func(name string, config interface{}) error {
 		var err error
@@ -50,23 +113,254 @@ func NewStack(composeData interface{}) (Stack, error) {
 		return Stack{}, err
 	}
 
-	return Stack{services, networks, volumes}, nil
+	secrets := make(map[string]Secret)
+	if err := parseConfig("secrets", config, func(name string, config interface{}) error {
+		var err error
+		secrets[name], err = NewSecret(config)
+		return err
+	}); err != nil {
+		return Stack{}, err
+	}
+
+	if stack.projectName == "" {
+		if err := setValue(&stack.projectName, "name", config, convertProjectName, nil); err != nil {
+			return Stack{}, err
+		}
+		if stack.projectName == "" {
+			stack.projectName = defaultProjectName
+		}
+	}
+
+	for _, name := range sortedKeys(stack.scale) {
+		service, exists := services[name]
+		if !exists {
+			return Stack{}, fmt.Errorf("cannot scale service %s as it does not exist", name)
+		}
+		service.replicas = stack.scale[name]
+		services[name] = service
+	}
+
+	stack.services, stack.networks, stack.volumes, stack.secrets = services, networks, volumes, secrets
+	validationErr := ValidationError{}
+	for _, issue := range stack.validate() {
+		if issue.Severity == SeverityError {
+			validationErr.Issues = append(validationErr.Issues, issue)
+		} else {
+			stack.warnings = append(stack.warnings, issue)
+		}
+	}
+	if len(validationErr.Issues) > 0 {
+		validationErr.Warnings = stack.warnings
+		return Stack{}, validationErr
+	}
+
+	stack.addImplicitDefaultNetwork()
+	return stack, nil
+}
+
+// addImplicitDefaultNetwork creates the default network if it is used by a service but not defined
+func (s *Stack) addImplicitDefaultNetwork() {
+	if _, exists := s.networks[implicitDefaultNetwork]; exists {
+		return
+	}
+	for _, service := range s.services {
+		endpoints := service.GetNetworkConfig().EndpointsConfig
+		_, usesDefault := endpoints[implicitDefaultNetwork]
+		if usesDefault || (len(endpoints) == 0 && service.GetHostConfig().NetworkMode == "") {
+			s.networks[implicitDefaultNetwork], _ = NewNetwork(nil)
+			return
+		}
+	}
+}
+
+// Warnings returns the issues found during validation that did not prevent the Stack from being created
+func (s Stack) Warnings() []ValidationIssue {
+	return s.warnings
+}
+
+// GetProjectName returns the name used to scope all the resources of the Stack
+func (s Stack) GetProjectName() string {
+	return s.projectName
+}
+
+// ServiceNames returns the names of all services in sorted order
+func (s Stack) ServiceNames() []string {
+	return sortedKeys(s.services)
+}
+
+// NetworkNames returns the names of all networks in sorted order
+func (s Stack) NetworkNames() []string {
+	return sortedKeys(s.networks)
+}
+
+// VolumeNames returns the names of all volumes in sorted order
+func (s Stack) VolumeNames() []string {
+	return sortedKeys(s.volumes)
+}
+
+// SecretNames returns the names of all secrets in sorted order
+func (s Stack) SecretNames() []string {
+	return sortedKeys(s.secrets)
+}
+
+// RangeServices calls f for each service in name order. If f returns false, range stops the iteration
+func (s Stack) RangeServices(f func(name string, service Service) bool) {
+	for _, name := range s.ServiceNames() {
+		if !f(name, s.services[name]) {
+			return
+		}
+	}
+}
+
+// RangeNetworks calls f for each network in name order. If f returns false, range stops the iteration
+func (s Stack) RangeNetworks(f func(name string, network Network) bool) {
+	for _, name := range s.NetworkNames() {
+		if !f(name, s.networks[name]) {
+			return
+		}
+	}
 }
 
-// TODO: probably need a list for each of below
+// RangeVolumes calls f for each volume in name order. If f returns false, range stops the iteration
+func (s Stack) RangeVolumes(f func(name string, volume Volume) bool) {
+	for _, name := range s.VolumeNames() {
+		if !f(name, s.volumes[name]) {
+			return
+		}
+	}
+}
+
+// GetService returns the service and whether it exists in the Stack
+func (s Stack) GetService(name string) (Service, bool) {
+	service, exists := s.services[name]
+	return service, exists
+}
+
+// GetNetwork returns the network and whether it exists in the Stack
+func (s Stack) GetNetwork(name string) (Network, bool) {
+	network, exists := s.networks[name]
+	return network, exists
+}
+
+// GetVolume returns the volume and whether it exists in the Stack
+func (s Stack) GetVolume(name string) (Volume, bool) {
+	volume, exists := s.volumes[name]
+	return volume, exists
+}
 
+// GetSecret returns the secret and whether it exists in the Stack
+func (s Stack) GetSecret(name string) (Secret, bool) {
+	secret, exists := s.secrets[name]
+	return secret, exists
+}
+
+// GetNetworkCreate returns the config to create the network. The config is empty if the network does not exist
 func (s Stack) GetNetworkCreate(name string) types.NetworkCreate {
 	return s.networks[name].GetCreateConfig()
 }
 
+// GetVolumeCreate returns the config to create the volume. The config is empty if the volume does not exist
 func (s Stack) GetVolumeCreate(name string) volume.VolumeCreateBody {
 	return s.volumes[name].GetCreateConfig()
 }
 
+// GetServiceContainerCreate returns the container config of the service. The config is empty if the
+// service does not exist, use GetServiceContainerConfig to distinguish an unknown service
 func (s Stack) GetServiceContainerCreate(name string) container.Config {
 	return s.services[name].GetContainerConfig()
 }
 
+// GetServiceReplicas returns the number of containers to run for the service
+func (s Stack) GetServiceReplicas(name string) (int, error) {
+	service, err := s.getService(name)
+	if err != nil {
+		return 0, err
+	}
+	return service.GetReplicas(), nil
+}
+
+// GetServiceContainerConfig returns the container config of the service
+func (s Stack) GetServiceContainerConfig(name string) (container.Config, error) {
+	service, err := s.getService(name)
+	if err != nil {
+		return container.Config{}, err
+	}
+	return service.GetContainerConfig(), nil
+}
+
+// GetServiceNetworkConfig returns the network endpoints of the service keyed by
+// the network names used in the compose file
+func (s Stack) GetServiceNetworkConfig(name string) (networktypes.NetworkingConfig, error) {
+	service, err := s.getService(name)
+	if err != nil {
+		return networktypes.NetworkingConfig{}, err
+	}
+	return service.GetNetworkConfig(), nil
+}
+
+// GetServicePlatform returns the platform for the service falling back to the
+// default platform of the Stack if the service does not set one
+func (s Stack) GetServicePlatform(name string) (v1.Platform, error) {
+	service, err := s.getService(name)
+	if err != nil {
+		return v1.Platform{}, err
+	}
+	if platform := service.GetPlatformConfig(); platform.OS != "" {
+		return platform, nil
+	}
+	return s.defaultPlatform, nil
+}
+
+// GetServiceHostConfig returns the host config for the service with any
+// references to other services resolved to their container names
+func (s Stack) GetServiceHostConfig(name string) (container.HostConfig, error) {
+	service, err := s.getService(name)
+	if err != nil {
+		return container.HostConfig{}, err
+	}
+
+	hostConfig := service.GetHostConfig()
+	if target, isService := service.GetNetworkModeService(); isService {
+		hostConfig.NetworkMode = container.NetworkMode("container:" + s.getContainerName(target, 1))
+	}
+	return hostConfig, nil
+}
+
+func (s Stack) getService(name string) (Service, error) {
+	service, exists := s.services[name]
+	if !exists {
+		return Service{}, fmt.Errorf("service %s does not exist", name)
+	}
+	return service, nil
+}
+
+// getContainerName returns the name for the given replica of the service. Numbering starts at 1
+func (s Stack) getContainerName(service string, number int) string {
+	if name := s.services[service].GetContainerName(); name != "" {
+		return name
+	}
+	return fmt.Sprintf("%s-%s-%d", s.projectName, service, number)
+}
+
+func convertProjectName(input interface{}) (interface{}, error) {
+	if name, isStr := input.(string); isStr {
+		return normaliseProjectName(name)
+	}
+	return nil, fmt.Errorf("should be a string")
+}
+
+// normaliseProjectName lowercases the name and ensures it only contains
+// characters that are valid in container, network and volume names
+func normaliseProjectName(name string) (string, error) {
+	name = strings.ToLower(name)
+	if !projectNameRegex.MatchString(name) {
+		return "", fmt.Errorf("project name %q must contain only lowercase letters, digits, dashes and underscores, and start with a letter or digit", name)
+	}
+	return name, nil
+}
+
+var projectNameRegex = regexp.MustCompile("^[a-z0-9][a-z0-9_-]*$")
+
 func verifyVersion(config map[string]interface{}) error {
 	if version, hasVersion := config["version"]; hasVersion {
 		versionNum, err := strconv.ParseFloat(version.(string), 32)
@@ -85,11 +379,11 @@ func parseConfig(thing string, mainConfig map[string]interface{}, parser func(st
 		if config, isMap := iface.(map[string]interface{}); isMap {
 			for name, data := range config {
 				if err := parser(name, data); err != nil {
-					return err
+					return withPath(err, thing, name)
 				}
 			}
 		} else {
-			return fmt.Errorf("%s should be a map", thing)
+			return withPath(fmt.Errorf("should be a map"), thing)
 		}
 	}
 	return nil
@@ -99,3 +99,229 @@ func parseYaml(data string) interface{} {
 	}
 	return yamlOut
 }
+
+func TestServicePlatformFallsBackToStackDefault(t *testing.T) {
+	yamlData := parseYaml("services:\n  default:\n    image: test\n  custom:\n    image: test\n    platform: linux/amd64")
+	stack, err := compose.NewStack(yamlData, compose.WithDefaultPlatform("linux/aarch64"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{"default": "linux/arm64", "custom": "linux/amd64"}
+	for service, platform := range expected {
+		actual, err := stack.GetServicePlatform(service)
+		if err != nil {
+			t.Error(err)
+		} else if compose.FormatPlatform(actual) != platform {
+			t.Errorf("%s: should be %s but got %s", service, platform, compose.FormatPlatform(actual))
+		}
+	}
+}
+
+func TestErrorOnInvalidDefaultPlatform(t *testing.T) {
+	if _, err := compose.NewStack(parseYaml("services: {}"), compose.WithDefaultPlatform("linux/z80")); err == nil {
+		t.Errorf("Should have returned an error but returned nothing")
+	}
+}
+
+func TestErrorOnPlatformForUnknownService(t *testing.T) {
+	stack, _ := compose.NewStack(parseYaml("services: {}"))
+	if _, err := stack.GetServicePlatform("unknown"); err == nil {
+		t.Errorf("Should have returned an error but returned nothing")
+	}
+}
+
+func TestNetworkModeServiceResolvesToContainerName(t *testing.T) {
+	yamlData := parseYaml(`
+name: MyApp
+services:
+  db:
+    image: postgres
+  web:
+    image: nginx
+    network_mode: service:db
+  named:
+    image: redis
+    container_name: redis
+  proxy:
+    image: nginx
+    network_mode: service:named
+`)
+	stack, err := compose.NewStack(yamlData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{"web": "container:myapp-db-1", "proxy": "container:redis"}
+	for service, networkMode := range expected {
+		hostConfig, err := stack.GetServiceHostConfig(service)
+		if err != nil {
+			t.Error(err)
+		} else if string(hostConfig.NetworkMode) != networkMode {
+			t.Errorf("%s: should be %s but got %s", service, networkMode, hostConfig.NetworkMode)
+		}
+	}
+}
+
+func TestProjectNameOptionOverridesFile(t *testing.T) {
+	stack, err := compose.NewStack(parseYaml("name: fromfile"), compose.WithProjectName("FromOption"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stack.GetProjectName() != "fromoption" {
+		t.Errorf("Should be \"fromoption\" but got \"%s\"", stack.GetProjectName())
+	}
+}
+
+func TestErrorOnInvalidProjectName(t *testing.T) {
+	if _, err := compose.NewStack(parseYaml("name: \"my project\"")); err == nil {
+		t.Errorf("Should have returned an error but returned nothing")
+	}
+	if _, err := compose.NewStack(parseYaml("services: {}"), compose.WithProjectName("_invalid")); err == nil {
+		t.Errorf("Should have returned an error but returned nothing")
+	}
+}
+
+func TestErrorOnNetworkModeForUndefinedService(t *testing.T) {
+	for _, composeFile := range []string{
+		"services:\n  web:\n    network_mode: service:db",
+		"services:\n  web:\n    network_mode: service:web",
+	} {
+		if _, err := compose.NewStack(parseYaml(composeFile)); err == nil {
+			t.Errorf("Should have returned an error for:\n%s", composeFile)
+		}
+	}
+}
+
+const enumerationCompose = `
+services:
+  web:
+    image: nginx
+    networks: [frontend, backend]
+  db:
+    image: postgres
+    networks: [backend]
+    volumes: ["data:/var/lib/postgresql/data"]
+  cache:
+    image: redis
+networks:
+  frontend:
+  backend:
+volumes:
+  data:
+`
+
+func TestNamesAreSorted(t *testing.T) {
+	stack, err := compose.NewStack(parseYaml(enumerationCompose))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyValue([]string{"cache", "db", "web"}, stack.ServiceNames()); err != nil {
+		t.Errorf("services: %s", err.Error())
+	}
+	if err := verifyValue([]string{"backend", "default", "frontend"}, stack.NetworkNames()); err != nil {
+		t.Errorf("networks: %s", err.Error())
+	}
+	if err := verifyValue([]string{"data"}, stack.VolumeNames()); err != nil {
+		t.Errorf("volumes: %s", err.Error())
+	}
+}
+
+func TestRangeServicesIsOrderedAndCanStop(t *testing.T) {
+	stack, _ := compose.NewStack(parseYaml(enumerationCompose))
+	var visited []string
+	stack.RangeServices(func(name string, service compose.Service) bool {
+		visited = append(visited, name+"="+service.GetContainerConfig().Image)
+		return name != "db"
+	})
+	if err := verifyValue([]string{"cache=redis", "db=postgres"}, visited); err != nil {
+		t.Error(err)
+	}
+
+	var networks []string
+	stack.RangeNetworks(func(name string, network compose.Network) bool {
+		networks = append(networks, name)
+		return true
+	})
+	if err := verifyValue([]string{"backend", "default", "frontend"}, networks); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLookupsReportUnknownNames(t *testing.T) {
+	stack, _ := compose.NewStack(parseYaml(enumerationCompose))
+	if _, exists := stack.GetService("web"); !exists {
+		t.Errorf("web should exist")
+	}
+	if _, exists := stack.GetService("unknown"); exists {
+		t.Errorf("unknown service should not exist")
+	}
+	if _, exists := stack.GetNetwork("unknown"); exists {
+		t.Errorf("unknown network should not exist")
+	}
+	if _, exists := stack.GetVolume("unknown"); exists {
+		t.Errorf("unknown volume should not exist")
+	}
+	if _, err := stack.GetServiceContainerConfig("unknown"); err == nil {
+		t.Errorf("Should have returned an error for container config")
+	}
+	if _, err := stack.GetServiceHostConfig("unknown"); err == nil {
+		t.Errorf("Should have returned an error for host config")
+	}
+	if _, err := stack.GetServiceNetworkConfig("unknown"); err == nil {
+		t.Errorf("Should have returned an error for network config")
+	}
+}
+
+func TestCanGetServiceNetworkConfig(t *testing.T) {
+	stack, _ := compose.NewStack(parseYaml(enumerationCompose))
+	networkConfig, err := stack.GetServiceNetworkConfig("web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(networkConfig.EndpointsConfig) != 2 {
+		t.Errorf("Should have 2 endpoints but got %d", len(networkConfig.EndpointsConfig))
+	}
+}
+
+func TestScaleOptionOverridesReplicas(t *testing.T) {
+	yamlData := parseYaml("services:\n  web:\n    image: nginx\n    scale: 2\n  db:\n    image: postgres")
+	stack, err := compose.NewStack(yamlData, compose.WithScale("web", 4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replicas, _ := stack.GetServiceReplicas("web"); replicas != 4 {
+		t.Errorf("web should have 4 replicas but got %d", replicas)
+	}
+	if replicas, _ := stack.GetServiceReplicas("db"); replicas != 1 {
+		t.Errorf("db should have 1 replica but got %d", replicas)
+	}
+
+	if _, err := compose.NewStack(yamlData, compose.WithScale("missing", 2)); err == nil {
+		t.Error("Expected error when scaling an unknown service")
+	}
+	if _, err := compose.NewStack(yamlData, compose.WithScale("web", -1)); err == nil {
+		t.Error("Expected error for negative scale")
+	}
+}
+
+func TestScalingServicesWithFixedNamesOrPortsWarns(t *testing.T) {
+	testData := map[string]string{
+		"containerName": "services:\n  web:\n    image: nginx\n    scale: 2\n    container_name: web",
+		"hostPort":      "services:\n  web:\n    image: nginx\n    scale: 2\n    ports: [\"8080:80\"]",
+	}
+	for name, data := range testData {
+		stack, err := compose.NewStack(parseYaml(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if warnings := stack.Warnings(); len(warnings) != 1 || warnings[0].Rule != compose.RuleScaleConflict {
+			t.Errorf("%s: expected scale conflict warning but got %v", name, warnings)
+		}
+	}
+
+	stack, _ := compose.NewStack(parseYaml("services:\n  web:\n    image: nginx\n    scale: 2\n    ports: [\"8080-8081:80\", \"80\"]"))
+	if warnings := stack.Warnings(); len(warnings) != 0 {
+		t.Errorf("Port ranges and random ports should allow scaling but got %v", warnings)
+	}
+}
@@ -0,0 +1,254 @@
+package compose
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/go-connections/nat"
+)
+
+// StateNotCreated is the state of a service that does not have any containers
+const StateNotCreated = "not created"
+
+// ContainerStatus is the state of a single container of the project. Services without any
+// containers have a single status with the StateNotCreated state and no container details
+type ContainerStatus struct {
+	Service string
+	// Replica is 0 for one-off containers and services without containers
+	Replica int
+	Name    string
+	ID      string
+	Image   string
+	// State is the state reported by the daemon, e.g. running or exited
+	State string
+	// Health is empty if the container does not have a healthcheck
+	Health string
+	// ExitCode is only meaningful once the container has exited
+	ExitCode int
+	// Uptime is how long the container has been running. It is 0 if it is not running
+	Uptime time.Duration
+	Ports  []PortStatus
+	// Orphan is set for containers of services that are no longer in the Stack
+	Orphan bool
+	OneOff bool
+}
+
+// PortStatus is a port of the container published on the host
+type PortStatus struct {
+	HostIP        string
+	HostPort      string
+	ContainerPort string
+	Protocol      string
+}
+
+// String formats the port as shown by docker, e.g. 0.0.0.0:8080->80/tcp
+func (p PortStatus) String() string {
+	return fmt.Sprintf("%s:%s->%s/%s", p.HostIP, p.HostPort, p.ContainerPort, p.Protocol)
+}
+
+// Status lists the state of every container of the project, sorted by service and replica
+type Status struct {
+	Containers []ContainerStatus
+}
+
+// StatusOptions controls which containers are included in the Status
+type StatusOptions struct {
+	// Services limits the status to the services. All services and orphans are included when empty
+	Services []string
+	// OneOff includes the containers created by Run
+	OneOff bool
+}
+
+// Status inspects the containers of the project and maps them back to the services of the Stack.
+// Every service is included even if it does not have any containers
+func (s Stack) Status(ctx context.Context, client Client, options StatusOptions) (Status, error) {
+	included := make(map[string]bool)
+	for _, service := range options.Services {
+		if _, err := s.getService(service); err != nil {
+			return Status{}, err
+		}
+		included[service] = true
+	}
+
+	containers, err := client.ContainerList(ctx, types.ContainerListOptions{All: true, Filters: s.projectFilter()})
+	if err != nil {
+		return Status{}, fmt.Errorf("listing containers: %w", err)
+	}
+
+	status := Status{}
+	found := make(map[string]bool)
+	now := time.Now()
+	for _, c := range containers {
+		service := c.Labels[ServiceLabel]
+		oneOff := c.Labels[OneoffLabel] == "True"
+		if (len(included) > 0 && !included[service]) || (oneOff && !options.OneOff) {
+			continue
+		}
+		inspect, err := client.ContainerInspect(ctx, c.ID)
+		if isNotFound(err) {
+			// Removed since it was listed so it no longer counts towards the service
+			continue
+		} else if err != nil {
+			return Status{}, wrapError("container", containerName(c), err)
+		}
+
+		containerStatus := newContainerStatus(inspect, now)
+		containerStatus.Service, containerStatus.OneOff = service, oneOff
+		if !oneOff {
+			containerStatus.Replica, _ = strconv.Atoi(c.Labels[ContainerNumberLabel])
+			found[service] = true
+		}
+		_, inStack := s.services[service]
+		containerStatus.Orphan = !inStack
+		status.Containers = append(status.Containers, containerStatus)
+	}
+
+	for _, service := range s.ServiceNames() {
+		if !found[service] && (len(included) == 0 || included[service]) {
+			status.Containers = append(status.Containers, ContainerStatus{Service: service, State: StateNotCreated})
+		}
+	}
+
+	sort.Slice(status.Containers, func(i, j int) bool {
+		first, second := status.Containers[i], status.Containers[j]
+		if first.Service != second.Service {
+			return first.Service < second.Service
+		}
+		if first.OneOff != second.OneOff {
+			return second.OneOff
+		}
+		if first.Replica != second.Replica {
+			return first.Replica < second.Replica
+		}
+		return first.Name < second.Name
+	})
+	return status, nil
+}
+
+// newContainerStatus reads the state of the container from the inspect data
+func newContainerStatus(inspect types.ContainerJSON, now time.Time) ContainerStatus {
+	status := ContainerStatus{
+		Name:  strings.TrimPrefix(inspect.Name, "/"),
+		ID:    inspect.ID,
+		State: inspect.State.Status,
+	}
+	if inspect.Config != nil {
+		status.Image = inspect.Config.Image
+	}
+	if inspect.State.Health != nil {
+		status.Health = inspect.State.Health.Status
+	}
+	if inspect.State.Status == "exited" || inspect.State.Status == "dead" {
+		status.ExitCode = inspect.State.ExitCode
+	}
+	if inspect.State.Running {
+		if started, err := time.Parse(time.RFC3339Nano, inspect.State.StartedAt); err == nil && !started.IsZero() {
+			status.Uptime = now.Sub(started)
+		}
+	}
+	if inspect.NetworkSettings != nil {
+		status.Ports = publishedPorts(inspect.NetworkSettings.Ports)
+	}
+	return status
+}
+
+// publishedPorts lists the ports bound on the host in container port order
+func publishedPorts(ports nat.PortMap) []PortStatus {
+	var published []PortStatus
+	for _, port := range sortedPorts(ports) {
+		for _, binding := range ports[port] {
+			if binding.HostPort == "" {
+				continue
+			}
+			published = append(published, PortStatus{
+				HostIP:        binding.HostIP,
+				HostPort:      binding.HostPort,
+				ContainerPort: port.Port(),
+				Protocol:      port.Proto(),
+			})
+		}
+	}
+	return published
+}
+
+// String renders the status as a table with a row for each container
+func (s Status) String() string {
+	var builder strings.Builder
+	table := tabwriter.NewWriter(&builder, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(table, "NAME\tSERVICE\tREPLICA\tSTATE\tHEALTH\tEXIT CODE\tUPTIME\tPORTS")
+	for _, c := range s.Containers {
+		name, replica, exitCode, uptime := c.Name, strconv.Itoa(c.Replica), "-", "-"
+		if name == "" {
+			name = "-"
+		}
+		if c.OneOff || c.Replica == 0 {
+			replica = "-"
+		}
+		if c.State == "exited" || c.State == "dead" {
+			exitCode = strconv.Itoa(c.ExitCode)
+		}
+		if c.Uptime > 0 {
+			uptime = c.Uptime.Round(time.Second).String()
+		}
+		health := c.Health
+		if health == "" {
+			health = "-"
+		}
+		state := c.State
+		if c.Orphan {
+			state += " (orphan)"
+		}
+		var ports []string
+		for _, port := range c.Ports {
+			ports = append(ports, port.String())
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", name, c.Service, replica, state, health, exitCode, uptime, strings.Join(ports, ", "))
+	}
+	table.Flush()
+	return builder.String()
+}
+
+// MarshalJSON outputs the containers with the uptime in whole seconds
+func (s Status) MarshalJSON() ([]byte, error) {
+	type jsonPort struct {
+		HostIP        string `json:"hostIP"`
+		HostPort      string `json:"hostPort"`
+		ContainerPort string `json:"containerPort"`
+		Protocol      string `json:"protocol"`
+	}
+	type jsonContainer struct {
+		Service  string     `json:"service"`
+		Replica  int        `json:"replica,omitempty"`
+		Name     string     `json:"name,omitempty"`
+		ID       string     `json:"id,omitempty"`
+		Image    string     `json:"image,omitempty"`
+		State    string     `json:"state"`
+		Health   string     `json:"health,omitempty"`
+		ExitCode int        `json:"exitCode"`
+		Uptime   int64      `json:"uptime"`
+		Ports    []jsonPort `json:"ports"`
+		Orphan   bool       `json:"orphan,omitempty"`
+		OneOff   bool       `json:"oneOff,omitempty"`
+	}
+	containers := []jsonContainer{}
+	for _, c := range s.Containers {
+		ports := []jsonPort{}
+		for _, port := range c.Ports {
+			ports = append(ports, jsonPort(port))
+		}
+		containers = append(containers, jsonContainer{
+			c.Service, c.Replica, c.Name, c.ID, c.Image, c.State, c.Health, c.ExitCode,
+			int64(c.Uptime / time.Second), ports, c.Orphan, c.OneOff,
+		})
+	}
+	return json.Marshal(struct {
+		Containers []jsonContainer `json:"containers"`
+	}{containers})
+}
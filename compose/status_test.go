@@ -0,0 +1,155 @@
+package compose_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/rmasp98/go-compose/compose"
+	"github.com/rmasp98/go-compose/compose/composetest"
+)
+
+const statusCompose = `
+name: app
+services:
+  web:
+    image: nginx
+    ports:
+      - "8080:80"
+  worker:
+    image: busybox
+    scale: 2
+  db:
+    image: postgres
+    healthcheck:
+      test: ["CMD", "pg_isready"]
+  cache:
+    image: redis
+    scale: 0
+`
+
+func newStatusStack(t *testing.T) (compose.Stack, *composetest.FakeClient) {
+	stack, err := compose.NewStack(parseYaml(statusCompose))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := newFakeClient()
+	if _, err := stack.Up(context.Background(), client, compose.UpOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	client.SetExitCode("app-worker-2", 2)
+	client.AddContainer("app-old-1", "busybox", "running", map[string]string{
+		compose.ProjectLabel: "app", compose.ServiceLabel: "old", compose.ContainerNumberLabel: "1", compose.OneoffLabel: "False",
+	})
+	if _, err := stack.Run(context.Background(), client, "worker", nil, compose.RunOptions{Name: "app-worker-run", Detach: true}); err != nil {
+		t.Fatal(err)
+	}
+	return stack, client
+}
+
+func TestStatusReportsEveryService(t *testing.T) {
+	stack, client := newStatusStack(t)
+	status, err := stack.Status(context.Background(), client, compose.StatusOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rows []string
+	for _, c := range status.Containers {
+		rows = append(rows, c.Service+" "+c.Name+" "+c.State)
+	}
+	expected := []string{
+		"cache  not created",
+		"db app-db-1 running",
+		"old app-old-1 running",
+		"web app-web-1 running",
+		"worker app-worker-1 running",
+		"worker app-worker-2 exited",
+	}
+	if err := verifyValue(expected, rows); err != nil {
+		t.Fatal(err)
+	}
+
+	db, old, web, worker := status.Containers[1], status.Containers[2], status.Containers[3], status.Containers[5]
+	if db.Health != "healthy" || db.Uptime <= 0 || db.Replica != 1 || db.Image != "postgres" {
+		t.Errorf("Unexpected db status: %+v", db)
+	}
+	if !old.Orphan || db.Orphan {
+		t.Error("Only containers of services not in the Stack should be orphans")
+	}
+	if len(web.Ports) != 1 || web.Ports[0].String() != "0.0.0.0:8080->80/tcp" {
+		t.Errorf("Unexpected ports: %v", web.Ports)
+	}
+	if worker.ExitCode != 2 || worker.Uptime != 0 || worker.Replica != 2 {
+		t.Errorf("Unexpected exited status: %+v", worker)
+	}
+}
+
+func TestStatusCanFilterServicesAndIncludeOneOffs(t *testing.T) {
+	stack, client := newStatusStack(t)
+	status, err := stack.Status(context.Background(), client, compose.StatusOptions{Services: []string{"worker", "cache"}, OneOff: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, c := range status.Containers {
+		names = append(names, c.Service+"/"+c.Name)
+	}
+	if err := verifyValue([]string{"cache/", "worker/app-worker-1", "worker/app-worker-2", "worker/app-worker-run"}, names); err != nil {
+		t.Error(err)
+	}
+	if !status.Containers[3].OneOff || status.Containers[3].Replica != 0 {
+		t.Errorf("Run container should be a one-off: %+v", status.Containers[3])
+	}
+
+	if _, err := stack.Status(context.Background(), client, compose.StatusOptions{Services: []string{"missing"}}); err == nil {
+		t.Error("Expected error for unknown service")
+	}
+}
+
+func TestStatusSkipsContainersRemovedWhileListing(t *testing.T) {
+	stack, fake := newStatusStack(t)
+	client := &removedContainerClient{FakeClient: fake, inspected: make(chan struct{})}
+	status, err := stack.Status(context.Background(), client, compose.StatusOptions{Services: []string{"web"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(status.Containers) != 1 || status.Containers[0].State != compose.StateNotCreated {
+		t.Errorf("Removed container should leave the service not created: %+v", status.Containers)
+	}
+}
+
+func TestStatusRendersTableAndJSON(t *testing.T) {
+	stack, client := newStatusStack(t)
+	status, err := stack.Status(context.Background(), client, compose.StatusOptions{Services: []string{"cache", "worker"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(status.String(), "\n"), "\n")
+	expected := [][]string{
+		{"NAME", "SERVICE", "REPLICA", "STATE", "HEALTH", "EXIT", "CODE", "UPTIME", "PORTS"},
+		{"-", "cache", "-", "not", "created", "-", "-", "-"},
+		{"app-worker-2", "worker", "2", "exited", "-", "2", "-"},
+	}
+	for i, line := range []string{lines[0], lines[1], lines[3]} {
+		if err := verifyValue(expected[i], strings.Fields(line)); err != nil {
+			t.Error(err)
+		}
+	}
+
+	output, err := json.Marshal(status)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded struct {
+		Containers []map[string]interface{} `json:"containers"`
+	}
+	if err := json.Unmarshal(output, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.Containers) != 3 || decoded.Containers[0]["state"] != "not created" || decoded.Containers[2]["exitCode"] != 2.0 {
+		t.Errorf("Unexpected JSON: %s", output)
+	}
+}
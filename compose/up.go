@@ -0,0 +1,214 @@
+package compose
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+)
+
+// ResourceKind is the type of resource a Result refers to
+type ResourceKind string
+
+const (
+	KindNetwork   ResourceKind = "network"
+	KindVolume    ResourceKind = "volume"
+	KindImage     ResourceKind = "image"
+	KindContainer ResourceKind = "container"
+)
+
+// Action is what was done to a resource
+type Action string
+
+const (
+	ActionCreated   Action = "created"
+	ActionStarted   Action = "started"
+	ActionPulled    Action = "pulled"
+	ActionRecreated Action = "recreated"
+	ActionUnchanged Action = "unchanged"
+	ActionFailed    Action = "failed"
+)
+
+// Result is the outcome for a single resource. Name is the name of the resource on the daemon
+type Result struct {
+	Kind    ResourceKind
+	Name    string
+	Service string
+	Action  Action
+	Err     error
+}
+
+// Report contains a Result for every resource processed, in the order they were processed
+type Report struct {
+	Results []Result
+}
+
+// Failed returns the results that contain an error
+func (r Report) Failed() []Result {
+	var failed []Result
+	for _, result := range r.Results {
+		if result.Err != nil {
+			failed = append(failed, result)
+		}
+	}
+	return failed
+}
+
+// record adds the result and returns the error so failures can be recorded and returned together
+func (r *Report) record(result Result, action Action, err error) error {
+	result.Action = action
+	if err != nil {
+		result.Action, result.Err = ActionFailed, err
+	}
+	r.Results = append(r.Results, result)
+	return err
+}
+
+// UpOptions controls how Up treats containers that already exist and waits for dependencies
+type UpOptions struct {
+	// ForceRecreate recreates containers even if their config has not changed
+	ForceRecreate bool
+	// NoRecreate never recreates existing containers even if their config has changed
+	NoRecreate bool
+
+	// WaitTimeouts overrides how long to wait for a dependency to become healthy or complete,
+	// keyed by the name of the dependency. By default service_healthy waits long enough for
+	// every retry of the healthcheck and service_completed_successfully waits indefinitely
+	WaitTimeouts map[string]time.Duration
+	// PollInterval is how often the state of a dependency is checked. Defaults to 500ms
+	PollInterval time.Duration
+	// Progress is called whenever the status of a dependency being waited for changes
+	Progress func(WaitEvent)
+}
+
+// Up creates the networks, volumes and containers of the Stack that do not already exist and
+// starts the containers in dependency order. External networks and volumes must already exist.
+// Containers whose config hash differs from the Stack are recreated along with every service
+// that depends on them. Up stops at the first failure and the report contains everything
+// processed up to that point
+func (s Stack) Up(ctx context.Context, client Client, options UpOptions) (Report, error) {
+	plan, err := s.Plan(ctx, client, options)
+	if err != nil {
+		return Report{}, err
+	}
+	return s.Apply(ctx, client, plan)
+}
+
+// Apply makes the changes in the plan. The plan should come from the same Stack and the
+// daemon should not have changed since it was created. Before a container is started, Apply waits
+// for its dependencies to meet their depends_on condition
+func (s Stack) Apply(ctx context.Context, client Client, plan Plan) (Report, error) {
+	report := Report{}
+	waited := make(map[string]bool)
+	for _, change := range plan.Changes {
+		result := Result{Kind: change.Kind, Name: change.Name, Service: change.Service}
+		err := s.applyChange(ctx, client, change, plan.options, waited)
+		if err := report.record(result, change.Action, err); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+func (s Stack) applyChange(ctx context.Context, client Client, change Change, options UpOptions, waited map[string]bool) error {
+	if change.Action == ActionUnchanged {
+		return nil
+	}
+
+	switch change.Kind {
+	case KindNetwork:
+		config := s.GetNetworkCreate(change.source)
+		config.CheckDuplicate = true
+		config.Labels = mergeLabels(config.Labels, map[string]string{ProjectLabel: s.projectName, NetworkLabel: change.source})
+		_, err := client.NetworkCreate(ctx, change.Name, config)
+		return wrapError("network", change.Name, err)
+	case KindVolume:
+		config := s.GetVolumeCreate(change.source)
+		config.Name = change.Name
+		config.Labels = mergeLabels(config.Labels, map[string]string{ProjectLabel: s.projectName, VolumeLabel: change.source})
+		_, err := client.VolumeCreate(ctx, config)
+		return wrapError("volume", change.Name, err)
+	case KindImage:
+		return wrapError("image", change.Name, pullImage(ctx, client, change.Name, change.pullImage))
+	case KindContainer:
+		if change.Action != ActionRemoved {
+			if err := s.waitForDependencies(ctx, client, change.Service, options, waited); err != nil {
+				return err
+			}
+		}
+		return s.applyContainerChange(ctx, client, change)
+	}
+	return fmt.Errorf("unknown resource kind %s", change.Kind)
+}
+
+func (s Stack) applyContainerChange(ctx context.Context, client Client, change Change) error {
+	switch change.Action {
+	case ActionStarted:
+		err := client.ContainerStart(ctx, change.existing.ID, types.ContainerStartOptions{})
+		return wrapError("container", change.Name, err)
+	case ActionRemoved:
+		return s.removeContainer(ctx, client, *change.existing, DownOptions{})
+	}
+
+	request, err := s.ContainerCreateRequest(change.source, change.replica)
+	if err != nil {
+		return err
+	}
+	if change.Action == ActionRecreated {
+		if err := s.removeContainer(ctx, client, *change.existing, DownOptions{}); err != nil {
+			return err
+		}
+	}
+	return wrapError("container", change.Name, createContainer(ctx, client, request))
+}
+
+// pullImage pulls the image and waits for the pull to complete. Errors during the pull are
+// only reported in the progress stream so it must be read until the end
+func pullImage(ctx context.Context, client Client, image string, options types.ImagePullOptions) error {
+	stream, err := client.ImagePull(ctx, image, options)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	decoder := json.NewDecoder(stream)
+	for {
+		var message struct {
+			Error string `json:"error"`
+		}
+		if err := decoder.Decode(&message); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if message.Error != "" {
+			return fmt.Errorf("%s", message.Error)
+		}
+	}
+}
+
+// createContainer creates the container, connects the additional networks and starts it
+func createContainer(ctx context.Context, client Client, request ContainerCreateRequest) error {
+	created, err := client.ContainerCreate(ctx, request.Config, request.HostConfig, request.NetworkingConfig, request.Platform, request.Name)
+	if err != nil {
+		return err
+	}
+	for _, network := range sortedKeys(request.ExtraNetworks) {
+		if err := client.NetworkConnect(ctx, network, created.ID, request.ExtraNetworks[network]); err != nil {
+			return err
+		}
+	}
+	return client.ContainerStart(ctx, created.ID, types.ContainerStartOptions{})
+}
+
+// imageWithTag adds the latest tag if the image does not have a tag or digest
+func imageWithTag(image string) string {
+	if strings.Contains(image, "@") || strings.LastIndex(image, ":") > strings.LastIndex(image, "/") {
+		return image
+	}
+	return image + ":latest"
+}
@@ -0,0 +1,227 @@
+package compose_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/rmasp98/go-compose/compose"
+	"github.com/rmasp98/go-compose/compose/composetest"
+)
+
+const upCompose = `
+name: app
+services:
+  web:
+    image: nginx
+    depends_on: [api]
+    networks: [frontend, backend]
+    stop_grace_period: 30s
+  api:
+    image: example/api:1.0
+    depends_on: [db]
+    networks: [backend]
+    volumes:
+      - data:/data
+  db:
+    image: postgres
+    networks: [backend]
+networks:
+  frontend:
+  backend:
+  shared:
+    external: true
+volumes:
+  data:
+`
+
+func newFakeClient() *composetest.FakeClient {
+	client := composetest.NewFakeClient()
+	client.AddNetwork("shared", nil)
+	client.AddImage("postgres")
+	return client
+}
+
+func newUpStack(t *testing.T) compose.Stack {
+	stack, err := compose.NewStack(parseYaml(upCompose))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stack
+}
+
+func TestUpCreatesResourcesInDependencyOrder(t *testing.T) {
+	client := newFakeClient()
+	report, err := newUpStack(t).Up(context.Background(), client, compose.UpOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"NetworkCreate app_backend",
+		"NetworkCreate app_frontend",
+		"VolumeCreate app_data",
+		"ImagePull example/api:1.0",
+		"ImagePull nginx:latest",
+		"ContainerCreate app-db-1",
+		"ContainerStart app-db-1",
+		"ContainerCreate app-api-1",
+		"ContainerStart app-api-1",
+		"ContainerCreate app-web-1",
+		"NetworkConnect app_frontend app-web-1",
+		"ContainerStart app-web-1",
+	}
+	if err := verifyValue(expected, client.Calls()); err != nil {
+		t.Error(err)
+	}
+	if len(report.Results) != 10 || len(report.Failed()) != 0 {
+		t.Errorf("Unexpected report: %v", report.Results)
+	}
+	if network, _ := client.Network("app_backend"); network.Labels[compose.ProjectLabel] != "app" || network.Labels[compose.NetworkLabel] != "backend" {
+		t.Errorf("Network labels not set: %v", network.Labels)
+	}
+	if volume, _ := client.Volume("app_data"); volume.Labels[compose.ProjectLabel] != "app" || volume.Labels[compose.VolumeLabel] != "data" {
+		t.Errorf("Volume labels not set: %v", volume.Labels)
+	}
+}
+
+func TestUpOnlyStartsExistingResources(t *testing.T) {
+	client := newFakeClient()
+	stack := newUpStack(t)
+	if _, err := stack.Up(context.Background(), client, compose.UpOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	client.SetContainerState("app-db-1", "exited")
+	client.ClearCalls()
+
+	report, err := stack.Up(context.Background(), client, compose.UpOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyValue([]string{"ContainerStart app-db-1"}, client.Calls()); err != nil {
+		t.Error(err)
+	}
+	for _, result := range report.Results {
+		expected := compose.ActionUnchanged
+		if result.Name == "app-db-1" {
+			expected = compose.ActionStarted
+		}
+		if result.Action != expected {
+			t.Errorf("%s should be %s but was %s", result.Name, expected, result.Action)
+		}
+	}
+}
+
+func TestUpFailsIfExternalNetworkMissing(t *testing.T) {
+	client := composetest.NewFakeClient()
+	_, err := newUpStack(t).Up(context.Background(), client, compose.UpOptions{})
+	if err == nil || !strings.Contains(err.Error(), "external network shared does not exist") {
+		t.Fatalf("Expected error for missing external network but got %v", err)
+	}
+	if len(client.Calls()) != 0 {
+		t.Errorf("Nothing should be changed when the plan fails but got %v", client.Calls())
+	}
+}
+
+func TestUpReportsPullErrorsFromStream(t *testing.T) {
+	client := newFakeClient()
+	client.SetPullError("nginx", "manifest unknown")
+	_, err := newUpStack(t).Up(context.Background(), client, compose.UpOptions{})
+	if err == nil || !strings.Contains(err.Error(), "manifest unknown") {
+		t.Errorf("Expected pull error but got %v", err)
+	}
+}
+
+func TestUpRecreatesChangedServicesAndDependents(t *testing.T) {
+	stack := newUpStack(t)
+	updated, err := stack.WithImageTag("api", "2.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testData := map[string]struct {
+		options  compose.UpOptions
+		expected map[string]compose.Action
+	}{
+		"changed": {compose.UpOptions{}, map[string]compose.Action{
+			"app-db-1": compose.ActionUnchanged, "app-api-1": compose.ActionRecreated, "app-web-1": compose.ActionRecreated}},
+		"noRecreate": {compose.UpOptions{NoRecreate: true}, map[string]compose.Action{
+			"app-db-1": compose.ActionUnchanged, "app-api-1": compose.ActionUnchanged, "app-web-1": compose.ActionUnchanged}},
+		"forceRecreate": {compose.UpOptions{ForceRecreate: true}, map[string]compose.Action{
+			"app-db-1": compose.ActionRecreated, "app-api-1": compose.ActionRecreated, "app-web-1": compose.ActionRecreated}},
+	}
+
+	for name, data := range testData {
+		t.Run(name, func(t *testing.T) {
+			current := newFakeClient()
+			current.AddImage("example/api:1.0")
+			current.AddImage("example/api:2.0")
+			current.AddImage("nginx")
+			if _, err := stack.Up(context.Background(), current, compose.UpOptions{}); err != nil {
+				t.Fatal(err)
+			}
+
+			report, err := updated.Up(context.Background(), current, data.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			actions := make(map[string]compose.Action)
+			for _, result := range report.Results {
+				if result.Kind == compose.KindContainer {
+					actions[result.Name] = result.Action
+				}
+			}
+			if err := verifyValue(data.expected, actions); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestUpRejectsConflictingRecreateOptions(t *testing.T) {
+	options := compose.UpOptions{ForceRecreate: true, NoRecreate: true}
+	if _, err := newUpStack(t).Up(context.Background(), newFakeClient(), options); err == nil {
+		t.Error("Expected error when both recreate options are set")
+	}
+}
+
+func TestUpScalesServicesUpAndDown(t *testing.T) {
+	client := newFakeClient()
+	stack := newUpStack(t)
+	scaled, err := stack.Builder().Build(compose.WithScale("db", 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := scaled.Up(context.Background(), client, compose.UpOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"app-db-1", "app-db-2", "app-db-3"} {
+		if c, exists := client.Container(name); !exists || c.State != "running" {
+			t.Errorf("%s should be running", name)
+		}
+	}
+
+	client.ClearCalls()
+	reduced, _ := stack.Builder().Build(compose.WithScale("db", 1))
+	if _, err := reduced.Up(context.Background(), client, compose.UpOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"ContainerStop app-db-2", "ContainerRemove app-db-2", "ContainerStop app-db-3", "ContainerRemove app-db-3"}
+	if err := verifyValue(expected, client.Calls()); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUpRejectsScalingFixedContainerName(t *testing.T) {
+	stack, err := compose.NewStack(parseYaml("services:\n  web:\n    image: nginx\n    container_name: web\n    scale: 2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := newFakeClient()
+	if _, err := stack.Up(context.Background(), client, compose.UpOptions{}); err == nil {
+		t.Error("Expected error when scaling a service with container_name")
+	}
+	if len(client.Calls()) != 0 {
+		t.Errorf("Nothing should be changed but got %v", client.Calls())
+	}
+}
@@ -1,14 +1,17 @@
 package compose
 
 import (
+	"encoding/json"
 	"fmt"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/docker/cli/cli/compose/loader"
 	"github.com/docker/cli/cli/compose/types"
+	units "github.com/docker/go-units"
 )
 
 type setValueMapping struct {
@@ -27,18 +30,37 @@ func setValues(values []setValueMapping, config map[string]interface{}) error {
 	return nil
 }
 
+// deepCopy copies source into target so they do not share any maps, slices or pointers
+func deepCopy(source, target interface{}) error {
+	data, err := json.Marshal(source)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, target)
+}
+
+// sortedKeys returns the keys of a map with string keys in sorted order
+func sortedKeys(input interface{}) []string {
+	keys := []string{}
+	for _, key := range reflect.ValueOf(input).MapKeys() {
+		keys = append(keys, key.String())
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 func setValue(target interface{}, name string, config map[string]interface{}, convert func(interface{}) (interface{}, error), validate func(interface{}) error) error {
 	if iface, isSet := config[name]; isSet {
 		if convert != nil {
 			var err error
 			if iface, err = convert(iface); err != nil {
-				return fmt.Errorf("%s: %s", name, err.Error())
+				return withPath(err, name)
 			}
 		}
 
 		if validate != nil {
 			if err := validate(iface); err != nil {
-				return err
+				return withPath(err, name)
 			}
 		}
 
@@ -46,7 +68,7 @@ func setValue(target interface{}, name string, config map[string]interface{}, co
 		if !set(target, iface) {
 			// TODO: Must be a better way to do this
 			t := reflect.TypeOf(target).String()
-			return fmt.Errorf("%s should be type %s", name, t[1:])
+			return withPath(fmt.Errorf("should be type %s", t[1:]), name)
 		}
 	}
 	return nil
@@ -82,12 +104,17 @@ func parseVolumes(input interface{}) ([]types.ServiceVolumeConfig, error) {
 	var volumes []types.ServiceVolumeConfig
 	switch input := input.(type) {
 	case []interface{}:
-		list, err := convertToStringList(input)
-		if err != nil {
-			return nil, err
-		}
-		for _, config := range list.([]string) {
-			vol, err := loader.ParseVolume(config)
+		for _, config := range input {
+			var vol types.ServiceVolumeConfig
+			var err error
+			switch config := config.(type) {
+			case string:
+				vol, err = loader.ParseVolume(config)
+			case map[string]interface{}:
+				vol, err = parseVolumeMap(config)
+			default:
+				err = fmt.Errorf("volume must be a string or a map")
+			}
 			if err != nil {
 				return nil, err
 			}
@@ -129,7 +156,7 @@ func parseVolumeMap(config map[string]interface{}) (types.ServiceVolumeConfig, e
 		{"read_only", &volume.ReadOnly, nil, nil},
 		{"bind", &volume.Bind.Propagation, convertPropagation, nil},
 		{"volume", &volume.Volume.NoCopy, convertVolNoCopy, nil},
-		{"tmpfs", &volume.Tmpfs.Size, nil, nil},
+		{"tmpfs", &volume.Tmpfs.Size, convertTmpfsSize, nil},
 	}
 	if err := setValues(mapping, config); err != nil {
 		return volume, err
@@ -138,27 +165,53 @@ func parseVolumeMap(config map[string]interface{}) (types.ServiceVolumeConfig, e
 }
 
 func convertPropagation(input interface{}) (interface{}, error) {
-	if config, isMap := input.(map[string]bool); isMap {
+	if config, isMap := input.(map[string]interface{}); isMap {
 		propagation, isSet := config["propagation"]
 		if !isSet {
-			return nil, fmt.Errorf("volume bind missing proporation")
+			return nil, fmt.Errorf("volume bind missing propagation")
+		}
+		if propagation, isStr := propagation.(string); isStr {
+			return propagation, nil
 		}
-		return propagation, nil
+		return nil, fmt.Errorf("propagation should be a string")
 	}
-	return nil, nil
+	return nil, fmt.Errorf("bind should be a map")
 }
 
 func convertVolNoCopy(input interface{}) (interface{}, error) {
-	if config, isMap := input.(map[string]bool); isMap {
+	switch config := input.(type) {
+	case map[string]bool:
 		noCopy, isSet := config["nocopy"]
 		if !isSet {
 			return nil, fmt.Errorf("nocopy was not set in the volume definition")
 		}
 		return noCopy, nil
+	case map[string]interface{}:
+		noCopy, isSet := config["nocopy"]
+		if !isSet {
+			return nil, fmt.Errorf("nocopy was not set in the volume definition")
+		}
+		if noCopy, isBool := noCopy.(bool); isBool {
+			return noCopy, nil
+		}
+		return nil, fmt.Errorf("nocopy should be a bool")
 	}
 	return nil, fmt.Errorf("volume should be a map[string]bool")
 }
 
+func convertTmpfsSize(input interface{}) (interface{}, error) {
+	if config, isMap := input.(map[string]interface{}); isMap {
+		switch size := config["size"].(type) {
+		case int:
+			return int64(size), nil
+		case string:
+			return units.RAMInBytes(size)
+		}
+		return nil, fmt.Errorf("tmpfs size should be a number or a string")
+	}
+	return nil, fmt.Errorf("tmpfs should be a map")
+}
+
 func getVolumeString(config types.ServiceVolumeConfig) string {
 	var volume string
 	if config.Source != "" {
@@ -233,22 +286,120 @@ func parseStringList(input interface{}) ([]string, error) {
 		}
 		return output, nil
 	case map[string]interface{}:
+		// Sorted so the result, and the config hash, do not depend on map order
 		var output []string
-		for key, value := range input {
-			value, err := getString(value)
+		for _, key := range sortedKeys(input) {
+			value, err := getString(input[key])
 			if err != nil {
 				return nil, err
 			}
 			output = append(output, key+"="+value)
 		}
 		return output, nil
-	case string:
-		return strings.Split(input, " "), nil
 	}
 
 	return nil, fmt.Errorf("Cannot convert to []string")
 }
 
+func convertToStringOrList(input interface{}) (interface{}, error) {
+	return parseStringOrList(input)
+}
+
+// parseStringOrList is for options that accept a single value as a bare string.
+// The string is never split so values containing spaces are kept intact
+func parseStringOrList(input interface{}) ([]string, error) {
+	if value, isStr := input.(string); isStr {
+		return []string{value}, nil
+	}
+	return parseStringList(input)
+}
+
+// convertToCommand splits a string using POSIX shell rules (quotes and escapes)
+// so it matches how docker run would interpret it. An empty string or list
+// results in an empty, non-nil list which clears the default set by the image
+func convertToCommand(input interface{}) (interface{}, error) {
+	var command []string
+	switch input := input.(type) {
+	case string:
+		var err error
+		if command, err = SplitCommand(input); err != nil {
+			return nil, err
+		}
+	case []interface{}:
+		var err error
+		if command, err = parseStringList(input); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("should be a string or a list")
+	}
+
+	if command == nil {
+		command = []string{}
+	}
+	return command, nil
+}
+
+// SplitCommand splits the string form of command and entrypoint into words using POSIX shell
+// quoting. Unlike a shell, # does not start a comment and variables are not expanded
+func SplitCommand(command string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	for i := 0; i < len(command); i++ {
+		switch char := command[i]; char {
+		case ' ', '\t', '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		case '\\':
+			// An escaped newline joins the lines
+			if i++; i < len(command) && command[i] != '\n' {
+				inWord = true
+				word.WriteByte(command[i])
+			}
+		case '\'':
+			inWord = true
+			end := strings.IndexByte(command[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated single quote in %q", command)
+			}
+			word.WriteString(command[i+1 : i+1+end])
+			i += end + 1
+		case '"':
+			inWord = true
+			for i++; ; i++ {
+				if i >= len(command) {
+					return nil, fmt.Errorf("unterminated double quote in %q", command)
+				}
+				if command[i] == '"' {
+					break
+				}
+				// Inside double quotes a backslash only escapes characters that are special there
+				if command[i] == '\\' && i+1 < len(command) && strings.IndexByte("$`\"\\\n", command[i+1]) >= 0 {
+					if i++; command[i] == '\n' {
+						continue
+					}
+				}
+				word.WriteByte(command[i])
+			}
+		default:
+			inWord = true
+			word.WriteByte(char)
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+func convertToString(input interface{}) (interface{}, error) {
+	return getString(input)
+}
+
 func getString(source interface{}) (string, error) {
 	switch source := source.(type) {
 	case string:
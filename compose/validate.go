@@ -0,0 +1,327 @@
+package compose
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+// Severity of a ValidationIssue
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// ValidationIssue is a problem found while resolving the references between the
+// sections of the compose file
+type ValidationIssue struct {
+	Severity Severity
+	// Rule is a short identifier for the check that raised the issue
+	Rule string
+	// Path is the location of the issue in the compose file, e.g. services.web.networks.backend
+	Path    []string
+	Message string
+
+	// File, Line and Column are the position of the issue in the compose files. They are only set
+	// by Validate and Line and Column are 0 if the issue could not be located
+	File   string
+	Line   int
+	Column int
+}
+
+func (i ValidationIssue) String() string {
+	return fmt.Sprintf("%s: %s", strings.Join(i.Path, "."), i.Message)
+}
+
+// ValidationError is returned by NewStack when validation found issues with a severity of error
+type ValidationError struct {
+	Issues []ValidationIssue
+	// Warnings are the issues with a severity of warning. They are not part of the error message
+	Warnings []ValidationIssue
+}
+
+func (e ValidationError) Error() string {
+	messages := []string{}
+	for _, issue := range e.Issues {
+		messages = append(messages, issue.String())
+	}
+	return strings.Join(messages, "\n")
+}
+
+// ConfigError is returned by NewStack when a value in the compose file cannot be parsed
+type ConfigError struct {
+	// Path is the location of the value in the compose file, e.g. services.web.healthcheck.interval
+	Path []string
+	Err  error
+}
+
+func (e ConfigError) Error() string {
+	return fmt.Sprintf("%s: %s", strings.Join(e.Path, "."), e.Err.Error())
+}
+
+func (e ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// withPath prefixes the path of a ConfigError with the path, or wraps any other error in a ConfigError
+func withPath(err error, path ...string) error {
+	if configErr, isConfigErr := err.(ConfigError); isConfigErr {
+		return ConfigError{append(copyPath(path), configErr.Path...), configErr.Err}
+	}
+	return ConfigError{copyPath(path), err}
+}
+
+// Rules used by the validation pass
+const (
+	RuleUndefinedService = "undefined-service"
+	RuleUndefinedNetwork = "undefined-network"
+	RuleUndefinedVolume  = "undefined-volume"
+	RuleUndefinedSecret  = "undefined-secret"
+	RuleUnusedNetwork    = "unused-network"
+	RuleUnusedVolume     = "unused-volume"
+	RuleUnusedSecret     = "unused-secret"
+	RuleDependencyCycle  = "dependency-cycle"
+	RuleStaticAddress    = "static-address"
+	RuleExclusiveNetwork = "exclusive-network"
+	RuleSelfReference    = "self-reference"
+	RuleUnknownOption    = "unknown-option"
+	RuleScaleConflict    = "scale-conflict"
+	// Rules for problems that stop the compose files being loaded, used by Validate
+	RuleYAMLSyntax    = "yaml-syntax"
+	RuleInterpolation = "interpolation"
+	RuleInvalidConfig = "invalid-config"
+)
+
+// implicitDefaultNetwork is the network services join when they do not set any networks
+const implicitDefaultNetwork = "default"
+
+// validate checks every reference between services, networks, volumes and secrets
+func (s Stack) validate() []ValidationIssue {
+	var issues []ValidationIssue
+	usedNetworks := make(map[string]bool)
+	usedVolumes := make(map[string]bool)
+	usedSecrets := make(map[string]bool)
+
+	for _, name := range sortedKeys(s.services) {
+		service := s.services[name]
+		path := []string{"services", name}
+
+		issues = append(issues, validateServiceOptions(name, service)...)
+		issues = append(issues, s.validateServiceReferences(name, service)...)
+
+		endpoints := service.GetNetworkConfig().EndpointsConfig
+		if len(endpoints) == 0 && service.GetHostConfig().NetworkMode == "" {
+			usedNetworks[implicitDefaultNetwork] = true
+		}
+		for _, network := range sortedKeys(endpoints) {
+			endpoint := endpoints[network]
+			usedNetworks[network] = true
+			networkPath := append(path, "networks", network)
+			if _, exists := s.networks[network]; !exists && network != implicitDefaultNetwork {
+				issues = append(issues, newError(RuleUndefinedNetwork, networkPath, "network %s is not defined", network))
+				continue
+			}
+			issues = append(issues, s.validateEndpoint(network, endpoint.IPAddress, endpoint.GlobalIPv6Address, networkPath)...)
+		}
+		issues = append(issues, s.validateExclusiveNetworks(service, path)...)
+		if err := service.verifyScalable(); err != nil {
+			issues = append(issues, newWarning(RuleScaleConflict, path, "%s", err.Error()))
+		}
+
+		for _, volume := range service.GetVolumes() {
+			if volume.Type != "volume" || volume.Source == "" {
+				continue
+			}
+			usedVolumes[volume.Source] = true
+			if _, exists := s.volumes[volume.Source]; !exists {
+				issues = append(issues, newError(RuleUndefinedVolume, append(path, "volumes"), "volume %s is not defined", volume.Source))
+			}
+		}
+
+		for _, secret := range service.GetSecrets() {
+			usedSecrets[secret.Source] = true
+			if _, exists := s.secrets[secret.Source]; !exists {
+				issues = append(issues, newError(RuleUndefinedSecret, append(path, "secrets"), "secret %s is not defined", secret.Source))
+			}
+		}
+	}
+
+	issues = append(issues, s.validateDependencyCycles()...)
+
+	for _, name := range sortedKeys(s.networks) {
+		if !usedNetworks[name] {
+			issues = append(issues, newWarning(RuleUnusedNetwork, []string{"networks", name}, "network %s is not used by any service", name))
+		}
+	}
+	for _, name := range sortedKeys(s.volumes) {
+		if !usedVolumes[name] {
+			issues = append(issues, newWarning(RuleUnusedVolume, []string{"volumes", name}, "volume %s is not used by any service", name))
+		}
+	}
+	for _, name := range sortedKeys(s.secrets) {
+		if !usedSecrets[name] {
+			issues = append(issues, newWarning(RuleUnusedSecret, []string{"secrets", name}, "secret %s is not used by any service", name))
+		}
+	}
+
+	return issues
+}
+
+// validateServiceOptions warns about options that are not in the compose specification as they are ignored
+func validateServiceOptions(name string, service Service) []ValidationIssue {
+	var issues []ValidationIssue
+	for _, option := range service.unknownOptions {
+		issues = append(issues, newWarning(RuleUnknownOption, []string{"services", name, option}, "%s is not a service option and is ignored", option))
+	}
+	return issues
+}
+
+func (s Stack) validateServiceReferences(name string, service Service) []ValidationIssue {
+	var issues []ValidationIssue
+	path := []string{"services", name}
+
+	if target, isService := service.GetNetworkModeService(); isService {
+		if target == name {
+			issues = append(issues, newError(RuleSelfReference, append(path, "network_mode"), "network_mode cannot reference itself"))
+		} else if _, exists := s.services[target]; !exists {
+			issues = append(issues, newError(RuleUndefinedService, append(path, "network_mode"), "network_mode references undefined service %s", target))
+		}
+	}
+
+	for _, dependency := range sortedKeys(service.GetDependencies()) {
+		if dependency == name {
+			issues = append(issues, newError(RuleSelfReference, append(path, "depends_on"), "service cannot depend on itself"))
+		} else if _, exists := s.services[dependency]; !exists {
+			issues = append(issues, newError(RuleUndefinedService, append(path, "depends_on"), "depends on undefined service %s", dependency))
+		}
+	}
+
+	linked := make(map[string]bool)
+	for _, link := range service.GetLinks() {
+		target := strings.SplitN(link, ":", 2)[0]
+		if linked[target] {
+			continue
+		}
+		linked[target] = true
+		if _, exists := s.services[target]; !exists {
+			issues = append(issues, newError(RuleUndefinedService, append(path, "links"), "links to undefined service %s", target))
+		}
+	}
+
+	return issues
+}
+
+// validateEndpoint ensures static addresses can be assigned by the network
+func (s Stack) validateEndpoint(name, ipv4, ipv6 string, path []string) []ValidationIssue {
+	network, exists := s.networks[name]
+	if !exists || network.external || (ipv4 == "" && ipv6 == "") {
+		return nil
+	}
+
+	var issues []ValidationIssue
+	config := network.GetCreateConfig()
+	if ipv6 != "" && !config.EnableIPv6 {
+		issues = append(issues, newError(RuleStaticAddress, append(path, "ipv6_address"), "network %s does not have enable_ipv6 set", name))
+	}
+	for option, address := range map[string]string{"ipv4_address": ipv4, "ipv6_address": ipv6} {
+		if address == "" {
+			continue
+		}
+		if config.IPAM == nil || len(config.IPAM.Config) == 0 {
+			issues = append(issues, newError(RuleStaticAddress, append(path, option), "network %s must have an ipam config to use a static address", name))
+			continue
+		}
+		if !ipamContains(network, net.ParseIP(address)) {
+			issues = append(issues, newError(RuleStaticAddress, append(path, option), "%s is not in any subnet of network %s", address, name))
+		}
+	}
+	sortIssues(issues)
+	return issues
+}
+
+// validateExclusiveNetworks ensures host and none networks are not combined with other networks
+func (s Stack) validateExclusiveNetworks(service Service, path []string) []ValidationIssue {
+	endpoints := service.GetNetworkConfig().EndpointsConfig
+	if len(endpoints) < 2 {
+		return nil
+	}
+	for _, name := range sortedKeys(endpoints) {
+		if network, exists := s.networks[name]; exists {
+			if driver := network.GetCreateConfig().Driver; driver == "host" || driver == "none" {
+				return []ValidationIssue{newError(RuleExclusiveNetwork, append(path, "networks", name),
+					"network %s uses the %s driver so cannot be combined with other networks", name, driver)}
+			}
+		}
+	}
+	return nil
+}
+
+// validateDependencyCycles finds cycles in depends_on and links, as both decide the order services start in
+func (s Stack) validateDependencyCycles() []ValidationIssue {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int)
+	var issues []ValidationIssue
+
+	var visit func(name string, chain []string)
+	visit = func(name string, chain []string) {
+		state[name] = visiting
+		chain = append(chain, name)
+		dependsOn := s.services[name].GetDependencies()
+		for _, dependency := range s.serviceDependencies(name) {
+			switch state[dependency] {
+			case visiting:
+				option := "depends_on"
+				if _, isDependsOn := dependsOn[dependency]; !isDependsOn {
+					option = "links"
+				}
+				issues = append(issues, newError(RuleDependencyCycle, []string{"services", name, option},
+					"dependency cycle: %s -> %s", strings.Join(chain, " -> "), dependency))
+			case unvisited:
+				visit(dependency, chain)
+			}
+		}
+		state[name] = visited
+	}
+
+	for _, name := range sortedKeys(s.services) {
+		if state[name] == unvisited {
+			visit(name, nil)
+		}
+	}
+	return issues
+}
+
+func ipamContains(network Network, ip net.IP) bool {
+	for _, pool := range network.GetCreateConfig().IPAM.Config {
+		if _, subnet, err := net.ParseCIDR(pool.Subnet); err == nil && subnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func newError(rule string, path []string, format string, args ...interface{}) ValidationIssue {
+	return ValidationIssue{Severity: SeverityError, Rule: rule, Path: copyPath(path), Message: fmt.Sprintf(format, args...)}
+}
+
+func newWarning(rule string, path []string, format string, args ...interface{}) ValidationIssue {
+	return ValidationIssue{Severity: SeverityWarning, Rule: rule, Path: copyPath(path), Message: fmt.Sprintf(format, args...)}
+}
+
+// copyPath stops paths built with append from sharing a backing array
+func copyPath(path []string) []string {
+	return append([]string{}, path...)
+}
+
+func sortIssues(issues []ValidationIssue) {
+	sort.SliceStable(issues, func(i, j int) bool {
+		return strings.Join(issues[i].Path, ".") < strings.Join(issues[j].Path, ".")
+	})
+}
@@ -0,0 +1,166 @@
+package compose_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/rmasp98/go-compose/compose"
+)
+
+func TestValidationReturnsErrorForUndefinedReferences(t *testing.T) {
+	tests := map[string]string{
+		compose.RuleUndefinedNetwork: "services:\n  web:\n    networks: [backend]",
+		compose.RuleUndefinedVolume:  "services:\n  web:\n    volumes: [\"data:/data\"]",
+		compose.RuleUndefinedSecret:  "services:\n  web:\n    secrets: [token]",
+		compose.RuleUndefinedService: "services:\n  web:\n    depends_on: [db]",
+		compose.RuleSelfReference:    "services:\n  web:\n    depends_on: [web]",
+		compose.RuleDependencyCycle:  "services:\n  a:\n    depends_on: [b]\n  b:\n    depends_on: [c]\n  c:\n    depends_on: [a]",
+	}
+	for rule, composeFile := range tests {
+		if err := verifyValidationRule(composeFile, rule); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestValidationReturnsErrorForCyclesThroughLinks(t *testing.T) {
+	composeFile := "services:\n  web:\n    links: [db]\n  db:\n    depends_on: [web]"
+	_, err := compose.NewStack(parseYaml(composeFile))
+	var validationErr compose.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Should have returned a ValidationError but got %v", err)
+	}
+	expected := compose.ValidationIssue{
+		Severity: compose.SeverityError,
+		Rule:     compose.RuleDependencyCycle,
+		Path:     []string{"services", "web", "links"},
+		Message:  "dependency cycle: db -> web -> db",
+	}
+	if err := verifyValue([]compose.ValidationIssue{expected}, validationErr.Issues); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestValidationReturnsErrorForLinksToUndefinedService(t *testing.T) {
+	if err := verifyValidationRule("services:\n  web:\n    links: [\"db:database\"]", compose.RuleUndefinedService); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestValidationReturnsErrorForUndefinedLongSyntaxVolume(t *testing.T) {
+	composeFile := "services:\n  web:\n    volumes:\n      - type: volume\n        source: data\n        target: /data"
+	if err := verifyValidationRule(composeFile, compose.RuleUndefinedVolume); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestValidationReturnsErrorForInvalidStaticAddresses(t *testing.T) {
+	tests := []string{
+		"services:\n  web:\n    networks:\n      backend:\n        ipv4_address: 172.28.0.5\nnetworks:\n  backend:",
+		"services:\n  web:\n    networks:\n      backend:\n        ipv4_address: 10.0.0.5\nnetworks:\n  backend:\n    ipam:\n      config:\n        - subnet: 172.28.0.0/16",
+		"services:\n  web:\n    networks:\n      backend:\n        ipv6_address: 2001:3984:3989::10\nnetworks:\n  backend:\n    ipam:\n      config:\n        - subnet: 2001:3984:3989::/64",
+	}
+	for _, composeFile := range tests {
+		if err := verifyValidationRule(composeFile, compose.RuleStaticAddress); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestValidationAllowsStaticAddressInSubnet(t *testing.T) {
+	composeFile := `
+services:
+  web:
+    networks:
+      backend:
+        ipv4_address: 172.28.0.5
+        ipv6_address: 2001:3984:3989::10
+networks:
+  backend:
+    enable_ipv6: true
+    ipam:
+      config:
+        - subnet: 172.28.0.0/16
+        - subnet: 2001:3984:3989::/64`
+	if _, err := compose.NewStack(parseYaml(composeFile)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestValidationReturnsErrorWhenHostNetworkCombined(t *testing.T) {
+	composeFile := "services:\n  web:\n    networks: [hostnet, backend]\nnetworks:\n  hostnet:\n    driver: host\n  backend:"
+	if err := verifyValidationRule(composeFile, compose.RuleExclusiveNetwork); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestValidationAllowsImplicitDefaultNetwork(t *testing.T) {
+	if _, err := compose.NewStack(parseYaml("services:\n  web:\n    networks: [default]")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestValidationCountsImplicitMembersOfDefaultNetwork(t *testing.T) {
+	stack, err := compose.NewStack(parseYaml("services:\n  web:\n    image: nginx\nnetworks:\n  default:\n    driver: bridge"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if warnings := stack.Warnings(); len(warnings) != 0 {
+		t.Errorf("Default network is used by web so should not be reported but got %v", warnings)
+	}
+
+	stack, err = compose.NewStack(parseYaml("services:\n  web:\n    network_mode: host\nnetworks:\n  default:\n    driver: bridge"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if warnings := stack.Warnings(); len(warnings) != 1 || warnings[0].Rule != compose.RuleUnusedNetwork {
+		t.Errorf("Default network is not used with network_mode so should be reported but got %v", warnings)
+	}
+}
+
+func TestValidationWarnsForUnusedDefinitions(t *testing.T) {
+	composeFile := "services:\n  web:\n    image: nginx\nnetworks:\n  backend:\nvolumes:\n  data:\nsecrets:\n  token:\n    file: ./token"
+	stack, err := compose.NewStack(parseYaml(composeFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{compose.RuleUnusedNetwork, compose.RuleUnusedVolume, compose.RuleUnusedSecret}
+	warnings := stack.Warnings()
+	if len(warnings) != len(expected) {
+		t.Fatalf("Should have returned %d warnings but got %v", len(expected), warnings)
+	}
+	for i, warning := range warnings {
+		if warning.Rule != expected[i] || warning.Severity != compose.SeverityWarning {
+			t.Errorf("Should have returned %s warning but got %v", expected[i], warning)
+		}
+	}
+}
+
+func TestValidationWarnsForUnknownServiceOptions(t *testing.T) {
+	composeFile := "services:\n  web:\n    imagee: nginx\n    mem_limit: 512m\n    cpus: 0.5\n    extends: base\n    x-custom: anything"
+	stack, err := compose.NewStack(parseYaml(composeFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	warnings := stack.Warnings()
+	if len(warnings) != 1 || warnings[0].Rule != compose.RuleUnknownOption || warnings[0].String() != "services.web.imagee: imagee is not a service option and is ignored" {
+		t.Errorf("Should have only warned about imagee but got %v", warnings)
+	}
+}
+
+func verifyValidationRule(composeFile, rule string) error {
+	_, err := compose.NewStack(parseYaml(composeFile))
+	var validationErr compose.ValidationError
+	if !errors.As(err, &validationErr) {
+		return fmt.Errorf("%s: should have returned a ValidationError but got %v", rule, err)
+	}
+	for _, issue := range validationErr.Issues {
+		if issue.Rule == rule && issue.Severity == compose.SeverityError {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s: was not in the returned issues %v", rule, validationErr.Issues)
+}
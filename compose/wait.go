@@ -0,0 +1,159 @@
+package compose
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/docker/docker/api/types"
+)
+
+// Defaults used by the daemon when the healthcheck does not set them
+const (
+	defaultHealthInterval = 30 * time.Second
+	defaultHealthTimeout  = 30 * time.Second
+	defaultHealthRetries  = 3
+	defaultPollInterval   = 500 * time.Millisecond
+)
+
+// WaitEvent reports a change in the state of a dependency that a service is waiting for
+type WaitEvent struct {
+	// Service is the service waiting and Dependency is the service being waited for
+	Service    string
+	Dependency string
+	Container  string
+	Condition  string
+	// Status is the health status for service_healthy or the container state for service_completed_successfully
+	Status string
+}
+
+// waitForDependencies blocks until every dependency of the service with a service_healthy or
+// service_completed_successfully condition meets it. Waited contains the dependencies that have
+// already met their condition so each is only waited for once
+func (s Stack) waitForDependencies(ctx context.Context, client Client, service string, options UpOptions, waited map[string]bool) error {
+	dependencies := s.services[service].GetDependencies()
+	for _, dependency := range sortedKeys(dependencies) {
+		condition := dependencies[dependency]
+		if condition == DependencyStarted || waited[dependency] {
+			continue
+		}
+		if _, exists := s.services[dependency]; !exists {
+			continue
+		}
+
+		containers, err := s.findContainers(ctx, client, dependency)
+		if err != nil {
+			return fmt.Errorf("waiting for %s: %w", dependency, err)
+		}
+		if len(containers) == 0 {
+			return fmt.Errorf("waiting for %s: service has no containers", dependency)
+		}
+
+		timeout := s.waitTimeout(dependency, condition, options)
+		for _, replica := range sortedReplicas(containers) {
+			event := WaitEvent{Service: service, Dependency: dependency, Container: containerName(*containers[replica]), Condition: condition}
+			if err := waitForContainer(ctx, client, containers[replica].ID, event, timeout, options); err != nil {
+				return fmt.Errorf("waiting for %s: %w", event.Container, err)
+			}
+		}
+		waited[dependency] = true
+	}
+	return nil
+}
+
+// waitForContainer polls the container until it meets the condition, fails or the timeout is reached.
+// A timeout of zero waits until the context is cancelled
+func waitForContainer(ctx context.Context, client Client, id string, event WaitEvent, timeout time.Duration, options UpOptions) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	pollInterval := options.PollInterval
+	if pollInterval <= 0 {
+		pollInterval = defaultPollInterval
+	}
+
+	for {
+		inspect, err := client.ContainerInspect(ctx, id)
+		if err != nil {
+			return err
+		}
+		status, done, err := checkCondition(inspect, event.Condition)
+		if status != event.Status {
+			event.Status = status
+			if options.Progress != nil {
+				options.Progress(event)
+			}
+		}
+		if done || err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			if ctx.Err() == context.DeadlineExceeded {
+				return fmt.Errorf("%s not met after %s, last status was %s", event.Condition, timeout, status)
+			}
+			return ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// checkCondition returns the status of the container and whether the condition has been met. An
+// error is returned if the condition can no longer be met
+func checkCondition(inspect types.ContainerJSON, condition string) (string, bool, error) {
+	if inspect.ContainerJSONBase == nil || inspect.State == nil {
+		return "", false, fmt.Errorf("container state is not available")
+	}
+	state := inspect.State
+
+	if condition == DependencyCompleted {
+		switch {
+		case state.Status != "exited" && state.Status != "dead":
+			return state.Status, false, nil
+		case state.ExitCode != 0:
+			return state.Status, false, fmt.Errorf("exited with code %d", state.ExitCode)
+		}
+		return state.Status, true, nil
+	}
+
+	if state.Health == nil {
+		return state.Status, false, fmt.Errorf("container does not have a healthcheck")
+	}
+	switch {
+	case state.Status == "exited" || state.Status == "dead":
+		return state.Status, false, fmt.Errorf("exited with code %d before becoming healthy", state.ExitCode)
+	case state.Health.Status == types.Unhealthy:
+		return state.Health.Status, false, fmt.Errorf("container is unhealthy")
+	}
+	return state.Health.Status, state.Health.Status == types.Healthy, nil
+}
+
+// waitTimeout returns the timeout set in the options for the dependency. Otherwise for service_healthy
+// it is long enough for every retry of the healthcheck to run after the start period
+func (s Stack) waitTimeout(dependency, condition string, options UpOptions) time.Duration {
+	if timeout, isSet := options.WaitTimeouts[dependency]; isSet {
+		return timeout
+	}
+	if condition != DependencyHealthy {
+		return 0
+	}
+
+	interval, timeout, retries := defaultHealthInterval, defaultHealthTimeout, defaultHealthRetries
+	var startPeriod time.Duration
+	if healthcheck := s.services[dependency].GetContainerConfig().Healthcheck; healthcheck != nil {
+		if healthcheck.Interval > 0 {
+			interval = healthcheck.Interval
+		}
+		if healthcheck.Timeout > 0 {
+			timeout = healthcheck.Timeout
+		}
+		if healthcheck.Retries > 0 {
+			retries = healthcheck.Retries
+		}
+		startPeriod = healthcheck.StartPeriod
+	}
+	return startPeriod + time.Duration(retries+1)*(interval+timeout)
+}
@@ -0,0 +1,144 @@
+package compose_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/rmasp98/go-compose/compose"
+	"github.com/rmasp98/go-compose/compose/composetest"
+)
+
+const waitCompose = `
+name: app
+services:
+  db:
+    image: postgres
+    healthcheck:
+      test: pg_isready
+      interval: 1s
+  migrate:
+    image: postgres
+    depends_on:
+      db:
+        condition: service_healthy
+  web:
+    image: postgres
+    depends_on:
+      db:
+        condition: service_healthy
+      migrate:
+        condition: service_completed_successfully
+`
+
+func upWithWaits(t *testing.T, client *composetest.FakeClient, options compose.UpOptions) (compose.Report, error) {
+	stack, err := compose.NewStack(parseYaml(waitCompose))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.AddImage("postgres")
+	options.PollInterval = time.Millisecond
+	return stack.Up(context.Background(), client, options)
+}
+
+func TestUpWaitsForHealthyAndCompletedDependencies(t *testing.T) {
+	client := composetest.NewFakeClient()
+	client.ScriptHealth("app-db-1", types.Starting, types.Starting, types.Healthy)
+	client.ScriptState("app-migrate-1", "running", "running", "exited")
+	client.SetExitCode("app-migrate-1", 0)
+
+	var events []string
+	options := compose.UpOptions{Progress: func(event compose.WaitEvent) {
+		events = append(events, event.Service+" "+event.Container+" "+event.Status)
+	}}
+	if _, err := upWithWaits(t, client, options); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"migrate app-db-1 starting",
+		"migrate app-db-1 healthy",
+		"web app-migrate-1 running",
+		"web app-migrate-1 exited",
+	}
+	if err := verifyValue(expected, events); err != nil {
+		t.Error(err)
+	}
+	if c, _ := client.Container("app-web-1"); c.State != "running" {
+		t.Errorf("web should be running but was %s", c.State)
+	}
+}
+
+func TestUpFailsFastWhenDependencyCannotMeetCondition(t *testing.T) {
+	testData := map[string]struct {
+		setup    func(client *composetest.FakeClient)
+		expected string
+	}{
+		"unhealthy": {func(client *composetest.FakeClient) {
+			client.ScriptHealth("app-db-1", types.Starting, types.Unhealthy)
+		}, "app-db-1: container is unhealthy"},
+		"exitedBeforeHealthy": {func(client *composetest.FakeClient) {
+			client.ScriptHealth("app-db-1", types.Starting, "exited")
+			client.SetExitCode("app-db-1", 137)
+		}, "app-db-1: exited with code 137 before becoming healthy"},
+		"failedToComplete": {func(client *composetest.FakeClient) {
+			client.ScriptState("app-migrate-1", "running", "exited")
+			client.SetExitCode("app-migrate-1", 1)
+		}, "app-migrate-1: exited with code 1"},
+	}
+
+	for name, data := range testData {
+		t.Run(name, func(t *testing.T) {
+			client := composetest.NewFakeClient()
+			data.setup(client)
+			report, err := upWithWaits(t, client, compose.UpOptions{})
+			if err == nil || !strings.Contains(err.Error(), data.expected) {
+				t.Fatalf("Expected error containing %q but got %v", data.expected, err)
+			}
+			if failed := report.Failed(); len(failed) != 1 || failed[0].Kind != compose.KindContainer {
+				t.Errorf("Expected a single failed container but got %v", failed)
+			}
+			if _, exists := client.Container("app-web-1"); exists {
+				t.Error("web should not be created when a dependency fails")
+			}
+		})
+	}
+}
+
+func TestUpWaitTimeoutCanBeSetPerService(t *testing.T) {
+	client := composetest.NewFakeClient()
+	client.ScriptHealth("app-db-1", types.Starting)
+
+	options := compose.UpOptions{WaitTimeouts: map[string]time.Duration{"db": 20 * time.Millisecond}}
+	start := time.Now()
+	_, err := upWithWaits(t, client, options)
+	if err == nil || !strings.Contains(err.Error(), "service_healthy not met after 20ms") {
+		t.Errorf("Expected timeout error but got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Timeout should have been used but waited %s", elapsed)
+	}
+}
+
+func TestUpFailsWaitingForDependencyWithoutHealthcheck(t *testing.T) {
+	stack, err := compose.NewStack(parseYaml(`
+services:
+  db:
+    image: postgres
+  web:
+    image: postgres
+    depends_on:
+      db:
+        condition: service_healthy
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := composetest.NewFakeClient()
+	client.AddImage("postgres")
+	if _, err := stack.Up(context.Background(), client, compose.UpOptions{}); err == nil || !strings.Contains(err.Error(), "does not have a healthcheck") {
+		t.Errorf("Expected missing healthcheck error but got %v", err)
+	}
+}